@@ -0,0 +1,224 @@
+package device
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AudioCodec 为音频数据的编码
+type AudioCodec string
+
+const (
+	// AudioPCM 为小端有符号整数 PCM，多声道交错排列
+	AudioPCM AudioCodec = "pcm"
+	// AudioAAC 为带 ADTS 头的 AAC
+	AudioAAC AudioCodec = "aac"
+)
+
+// AudioFormat 为音频的采样格式
+type AudioFormat struct {
+	Codec         AudioCodec
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+}
+
+func (f AudioFormat) String() string {
+	if f.Codec == AudioPCM {
+		return fmt.Sprintf("pcm s%dle %dHz %dch", f.BitsPerSample, f.SampleRate, f.Channels)
+	}
+	return fmt.Sprintf("%s %dHz %dch", f.Codec, f.SampleRate, f.Channels)
+}
+
+// validate 检查流配置中的格式：AAC 的参数从 ADTS 头读取，PCM 必须给出采样率、声道数和位深
+func (f AudioFormat) validate() error {
+	switch f.Codec {
+	case AudioAAC:
+		return nil
+	case AudioPCM, "":
+		if f.SampleRate <= 0 || f.Channels <= 0 || f.BitsPerSample <= 0 || f.BitsPerSample%8 != 0 {
+			return fmt.Errorf("incomplete pcm format %+v (需要指定PCM的采样率、声道数和位深)", f)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown audio codec %q (未知的音频编码)", f.Codec)
+}
+
+// BytesPerSecond 返回 PCM 每秒的字节数，其他编码返回0
+func (f AudioFormat) BytesPerSecond() int {
+	if f.Codec != AudioPCM {
+		return 0
+	}
+	return f.SampleRate * f.Channels * f.BitsPerSample / 8
+}
+
+// AudioChunk 是音频流中的一段数据
+type AudioChunk struct {
+	// Seq 为原生库推送的序号，从0开始，被丢弃的数据也占用序号
+	Seq uint64
+	// Time 为收到数据的时间，PTS 为相对推流开始的时间
+	Time   time.Time
+	PTS    time.Duration
+	Format AudioFormat
+	Data   []byte
+	// Dropped 为截至该段累计丢弃的段数
+	Dropped uint64
+}
+
+// Duration 返回这段数据的播放时长，AAC 按每帧 1024 个采样计算
+func (c AudioChunk) Duration() time.Duration {
+	if c.Format.SampleRate <= 0 {
+		return 0
+	}
+	var samples int
+	switch c.Format.Codec {
+	case AudioPCM:
+		if n := c.Format.Channels * c.Format.BitsPerSample / 8; n > 0 {
+			samples = len(c.Data) / n
+		}
+	case AudioAAC:
+		samples = 1024 * countADTS(c.Data)
+	}
+	return time.Duration(samples) * time.Second / time.Duration(c.Format.SampleRate)
+}
+
+// Peak 返回 16 位 PCM 的峰值电平（0-1），可用于判断是否有声音；其他格式返回0
+func (c AudioChunk) Peak() float64 {
+	if c.Format.Codec != AudioPCM || c.Format.BitsPerSample != 16 {
+		return 0
+	}
+	peak := 0
+	for i := 0; i+1 < len(c.Data); i += 2 {
+		v := int(int16(binary.LittleEndian.Uint16(c.Data[i:])))
+		peak = max(peak, v, -v)
+	}
+	return float64(peak) / 32768
+}
+
+// AudioStreamOptions 为音频流配置
+type AudioStreamOptions struct {
+	// Format 为设备推送的音频格式，必须指定。原生库的回调只有数据，不提供格式信息，
+	// 各设备的采样格式也不相同，因此不做假定：推送 PCM 时须给出 SampleRate、Channels 和 BitsPerSample
+	// （Codec 为空表示 PCM），推送 AAC 时 Codec 设为 AudioAAC 即可，采样率和声道数从 ADTS 头读取。
+	// 无论 Codec 为何，带 ADTS 头的数据都按 AAC 处理
+	Format AudioFormat
+	// Buffer 为通道缓冲的段数，为0时使用 DefaultAudioBuffer。缓冲区已满时丢弃最旧的数据
+	Buffer int
+}
+
+// DefaultAudioBuffer 为音频通道默认缓冲的段数
+const DefaultAudioBuffer = 100
+
+// StartAudioStream 开始推流并只接收音频，opts.Format 不完整时返回错误。原生库只能通过 startVideoStream 获取音频，
+// 因此视频仍会编码，视频数据被丢弃；同时需要视频时使用 StartMediaStream。
+// 音频通过 Stream.Audio 接收，停止方式同 StartVideoStream
+func (d *Device) StartAudioStream(ctx context.Context, opts AudioStreamOptions) (*Stream, error) {
+	s, _, a, err := d.startStream(ctx, VideoStreamOptions{Buffer: 1}, &opts)
+	if err != nil {
+		return nil, err
+	}
+	s.Audio = a.chunks
+	return s, nil
+}
+
+// audioStream 把原生音频回调转换为带缓冲的通道
+type audioStream struct {
+	format AudioFormat
+	start  time.Time
+
+	mu      sync.Mutex
+	chunks  chan AudioChunk
+	closed  bool
+	seq     uint64
+	dropped uint64
+}
+
+func newAudioStream(opts AudioStreamOptions, start time.Time) *audioStream {
+	if opts.Format.Codec == "" {
+		opts.Format.Codec = AudioPCM
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultAudioBuffer
+	}
+	return &audioStream{
+		format: opts.Format,
+		start:  start,
+		chunks: make(chan AudioChunk, opts.Buffer),
+	}
+}
+
+func (s *audioStream) onAudio(data []byte) {
+	now := time.Now()
+	format := s.format
+	if f, ok := parseADTS(data); ok {
+		format = f
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	c := AudioChunk{
+		Seq:    s.seq,
+		Time:   now,
+		PTS:    now.Sub(s.start),
+		Format: format,
+		Data:   data,
+	}
+	s.seq++
+	for {
+		c.Dropped = s.dropped
+		select {
+		case s.chunks <- c:
+			return
+		default:
+		}
+		select {
+		case <-s.chunks:
+			s.dropped++
+		default:
+		}
+	}
+}
+
+func (s *audioStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.chunks)
+	}
+}
+
+var adtsSampleRates = [...]int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// parseADTS 识别 ADTS 头并读取采样率和声道数
+func parseADTS(data []byte) (AudioFormat, bool) {
+	if len(data) < 7 || data[0] != 0xff || data[1]&0xf6 != 0xf0 {
+		return AudioFormat{}, false
+	}
+	idx := int(data[2]>>2) & 0x0f
+	if idx >= len(adtsSampleRates) {
+		return AudioFormat{}, false
+	}
+	channels := int(data[2]&1)<<2 | int(data[3]>>6)
+	return AudioFormat{Codec: AudioAAC, SampleRate: adtsSampleRates[idx], Channels: channels, BitsPerSample: 16}, true
+}
+
+// countADTS 返回数据中完整的 ADTS 帧数
+func countADTS(data []byte) int {
+	n := 0
+	for len(data) >= 7 && data[0] == 0xff && data[1]&0xf6 == 0xf0 {
+		size := int(data[3]&0x03)<<11 | int(data[4])<<3 | int(data[5]>>5)
+		if size < 7 || size > len(data) {
+			break
+		}
+		n++
+		data = data[size:]
+	}
+	return n
+}
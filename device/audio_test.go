@@ -0,0 +1,152 @@
+package device
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"mytrpc/rpc"
+	"mytrpc/sim"
+)
+
+// adtsFrame 生成 AAC-LC 的 ADTS 帧，rateIndex 为采样率序号，帧长包含7字节头
+func adtsFrame(rateIndex, channels, payload int) []byte {
+	size := 7 + payload
+	b := []byte{
+		0xff, 0xf1,
+		1<<6 | byte(rateIndex)<<2 | byte(channels>>2),
+		byte(channels&3)<<6 | byte(size>>11)&0x03,
+		byte(size >> 3),
+		byte(size&0x07)<<5 | 0x1f,
+		0xfc,
+	}
+	return append(b, make([]byte, payload)...)
+}
+
+func TestParseADTS(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want AudioFormat
+		ok   bool
+	}{
+		{name: "48k stereo", data: adtsFrame(3, 2, 10), want: AudioFormat{Codec: AudioAAC, SampleRate: 48000, Channels: 2, BitsPerSample: 16}, ok: true},
+		{name: "44.1k mono", data: adtsFrame(4, 1, 10), want: AudioFormat{Codec: AudioAAC, SampleRate: 44100, Channels: 1, BitsPerSample: 16}, ok: true},
+		{name: "8k 5.1", data: adtsFrame(11, 6, 0), want: AudioFormat{Codec: AudioAAC, SampleRate: 8000, Channels: 6, BitsPerSample: 16}, ok: true},
+		{name: "mpeg-2 with crc", data: append([]byte{0xff, 0xf8}, adtsFrame(3, 2, 10)[2:]...), want: AudioFormat{Codec: AudioAAC, SampleRate: 48000, Channels: 2, BitsPerSample: 16}, ok: true},
+		{name: "reserved rate", data: adtsFrame(13, 2, 10)},
+		{name: "layer not zero", data: append([]byte{0xff, 0xf3}, adtsFrame(3, 2, 10)[2:]...)},
+		{name: "pcm", data: bytes.Repeat([]byte{0x12, 0x34}, 16)},
+		{name: "short", data: adtsFrame(3, 2, 0)[:6]},
+		{name: "empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseADTS(tt.data)
+			if ok != tt.ok || got != tt.want {
+				t.Fatalf("parseADTS = %+v, %v, want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCountADTS(t *testing.T) {
+	frame := adtsFrame(3, 2, 100)
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{name: "one", data: frame, want: 1},
+		{name: "three", data: bytes.Repeat(frame, 3), want: 3},
+		{name: "different sizes", data: append(append(adtsFrame(3, 2, 5), adtsFrame(3, 2, 300)...), adtsFrame(3, 2, 0)...), want: 3},
+		{name: "truncated last", data: append(bytes.Repeat(frame, 2), frame[:50]...), want: 2},
+		{name: "garbage after", data: append(bytes.Repeat(frame, 2), 0, 1, 2, 3, 4, 5, 6, 7), want: 2},
+		// 帧长小于头部长度时停止，避免死循环
+		{name: "zero length", data: func() []byte { f := adtsFrame(3, 2, 0); f[3], f[4], f[5] = f[3]&^3, 0, 0x1f; return f }(), want: 0},
+		{name: "not adts", data: bytes.Repeat([]byte{0}, 64), want: 0},
+		{name: "empty", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countADTS(tt.data); got != tt.want {
+				t.Fatalf("countADTS = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// 每帧 1024 个采样
+	c := AudioChunk{Format: AudioFormat{Codec: AudioAAC, SampleRate: 48000}, Data: bytes.Repeat(frame, 3)}
+	if got, want := c.Duration(), 3*1024*time.Second/48000; got != want {
+		t.Fatalf("Duration = %v, want %v", got, want)
+	}
+}
+
+func TestAudioFormatRequired(t *testing.T) {
+	fake := sim.New()
+	if err := fake.AddScreenFile("home", "../nodes_ex.xml"); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.NewClientWithBackend(fake)
+	if err := client.Connect("sim", 0); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	dev := NewDevice(client)
+	pcm := AudioFormat{Codec: AudioPCM, SampleRate: 16000, Channels: 1, BitsPerSample: 16}
+
+	tests := []struct {
+		name    string
+		format  AudioFormat
+		wantErr string
+	}{
+		{name: "zero", wantErr: "incomplete pcm format"},
+		{name: "no channels", format: AudioFormat{SampleRate: 48000, BitsPerSample: 16}, wantErr: "incomplete pcm format"},
+		{name: "odd bits", format: AudioFormat{SampleRate: 48000, Channels: 2, BitsPerSample: 12}, wantErr: "incomplete pcm format"},
+		{name: "unknown codec", format: AudioFormat{Codec: "opus", SampleRate: 48000, Channels: 2}, wantErr: "unknown audio codec"},
+		{name: "pcm", format: pcm},
+		{name: "codec omitted", format: AudioFormat{SampleRate: 16000, Channels: 1, BitsPerSample: 16}},
+		{name: "aac", format: AudioFormat{Codec: AudioAAC}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := dev.StartAudioStream(context.Background(), AudioStreamOptions{Format: tt.format})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				if _, _, _, ok := fake.Streaming(); ok {
+					t.Fatal("stream started with an invalid format")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				stream.Close()
+				<-stream.Done()
+			}()
+
+			// PCM 按指定的格式标注，ADTS 数据从头部读取格式
+			fake.PushAudio(make([]byte, 3200))
+			fake.PushAudio(adtsFrame(3, 2, 20))
+			first, second := <-stream.Audio, <-stream.Audio
+			want := tt.format
+			if want.Codec == "" {
+				want.Codec = AudioPCM
+			}
+			if first.Format != want {
+				t.Errorf("chunk format = %v, want %v", first.Format, want)
+			}
+			if want.Codec == AudioPCM && first.Duration() != 100*time.Millisecond {
+				t.Errorf("pcm chunk lasts %v, want 100ms", first.Duration())
+			}
+			if second.Format.Codec != AudioAAC || second.Format.SampleRate != 48000 || second.Format.Channels != 2 {
+				t.Errorf("adts chunk format = %v, want aac 48000Hz 2ch", second.Format)
+			}
+		})
+	}
+}
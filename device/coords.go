@@ -0,0 +1,112 @@
+package device
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"sync"
+	"time"
+)
+
+// NormScale 为归一化坐标的精度，Normalized 视图中 [0, NormScale] 对应屏幕的整个宽度或高度
+const NormScale = 10000
+
+// DefaultDisplayRefresh 为坐标视图重新获取屏幕方向的默认间隔
+const DefaultDisplayRefresh = 2 * time.Second
+
+// ViewOptions 为 Scaled、Normalized 坐标视图的配置
+type ViewOptions struct {
+	// RefreshInterval 为重新获取屏幕方向的间隔，尺寸和密度只在首次使用时获取。
+	// 为0时使用 DefaultDisplayRefresh，为负数时每次调用都重新获取
+	RefreshInterval time.Duration
+}
+
+func (o ViewOptions) refresh() time.Duration {
+	if o.RefreshInterval == 0 {
+		return DefaultDisplayRefresh
+	}
+	return o.RefreshInterval
+}
+
+// viewOptions 返回第一个配置，没有时使用默认值
+func viewOptions(opts []ViewOptions) ViewOptions {
+	if len(opts) == 0 {
+		return ViewOptions{}
+	}
+	return opts[0]
+}
+
+// Norm 把 [0,1] 的归一化坐标转换为 Normalized 视图使用的坐标
+//
+//	n := dev.Normalized()
+//	n.Gesture(device.Norm(0.5, 0.8)).MoveTo(device.Norm(0.5, 0.2), 300*time.Millisecond).Perform(ctx)
+func Norm(x, y float64) image.Point {
+	return ptf(x*NormScale, y*NormScale)
+}
+
+// Scaled 返回使用参考分辨率坐标的 Device：坐标按 ref（当前方向下画面的宽高，如 720x1280）给出，
+// 每次调用时按实际屏幕的逻辑尺寸缩放，再按屏幕方向转换为触摸坐标。
+// 触摸、滑动、长按和手势都会转换，同一脚本可以在不同分辨率的设备上执行。
+// 转换已包含屏幕方向，不需要再调用 Logical。opts 只使用第一个，省略时屏幕方向每隔 DefaultDisplayRefresh 重新获取。
+func (d *Device) Scaled(ref image.Point, opts ...ViewOptions) *Device {
+	refresh := viewOptions(opts).refresh()
+	return d.withMapper(func(ctx context.Context, p image.Point) (image.Point, error) {
+		info, err := d.display.get(ctx, d, refresh)
+		if err != nil {
+			return p, err
+		}
+		return info.ToPhysical(scalePoint(p, ref, info.Size())), nil
+	})
+}
+
+// Normalized 返回使用归一化坐标的 Device，坐标范围为 [0, NormScale]，通常用 Norm 生成，opts 同 Scaled
+func (d *Device) Normalized(opts ...ViewOptions) *Device {
+	return d.Scaled(image.Pt(NormScale, NormScale), opts...)
+}
+
+// scalePoint 把 ref 尺寸下的坐标缩放到 size 尺寸，结果限制在画面内
+func scalePoint(p, ref, size image.Point) image.Point {
+	if ref.X <= 0 || ref.Y <= 0 {
+		return p
+	}
+	x := math.Round(float64(p.X) * float64(size.X) / float64(ref.X))
+	y := math.Round(float64(p.Y) * float64(size.Y) / float64(ref.Y))
+	return image.Pt(
+		min(max(int(x), 0), max(size.X-1, 0)),
+		min(max(int(y), 0), max(size.Y-1, 0)),
+	)
+}
+
+// displayCache 缓存屏幕信息，尺寸和密度首次获取后保持不变，方向超过视图的刷新间隔后重新获取。
+// 同一 Device 派生的视图共享缓存，任一视图刷新后其他视图也使用新的方向
+type displayCache struct {
+	mu      sync.Mutex
+	info    DisplayInfo
+	valid   bool
+	fetched time.Time
+}
+
+func (c *displayCache) get(ctx context.Context, d *Device, refresh time.Duration) (DisplayInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.valid && time.Since(c.fetched) < refresh {
+		return c.info, nil
+	}
+	if !c.valid {
+		info, err := d.DisplayInfoCtx(ctx)
+		if err != nil {
+			return info, err
+		}
+		c.info, c.valid = info, true
+	} else {
+		rotation, err := d.client.Backend().GetDisplayRotate(d.client.GetHandle())
+		if err != nil {
+			return c.info, fmt.Errorf("get display rotation failed (获取屏幕方向失败): %w", err)
+		}
+		c.info.Rotation = Rotation(rotation).normalize()
+	}
+	c.fetched = time.Now()
+	return c.info, nil
+}
@@ -0,0 +1,163 @@
+package device
+
+import (
+	"image"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mytrpc/rpc"
+	"mytrpc/sim"
+)
+
+func TestScalePoint(t *testing.T) {
+	tests := []struct {
+		name         string
+		p, ref, size image.Point
+		want         image.Point
+	}{
+		{name: "same size", p: image.Pt(100, 200), ref: image.Pt(720, 1280), size: image.Pt(720, 1280), want: image.Pt(100, 200)},
+		{name: "up", p: image.Pt(660, 1200), ref: image.Pt(720, 1280), size: image.Pt(1080, 1920), want: image.Pt(990, 1800)},
+		{name: "down", p: image.Pt(990, 1800), ref: image.Pt(1080, 1920), size: image.Pt(720, 1280), want: image.Pt(660, 1200)},
+		{name: "round", p: image.Pt(1, 1), ref: image.Pt(2, 3), size: image.Pt(3, 5), want: image.Pt(2, 2)},
+		{name: "normalized", p: image.Pt(5000, 2500), ref: image.Pt(NormScale, NormScale), size: image.Pt(720, 1280), want: image.Pt(360, 320)},
+		{name: "clamp max", p: image.Pt(NormScale, NormScale), ref: image.Pt(NormScale, NormScale), size: image.Pt(720, 1280), want: image.Pt(719, 1279)},
+		{name: "clamp min", p: image.Pt(-50, -1), ref: image.Pt(720, 1280), size: image.Pt(720, 1280), want: image.Pt(0, 0)},
+		{name: "zero ref", p: image.Pt(30, 40), ref: image.Pt(0, 1280), size: image.Pt(720, 1280), want: image.Pt(30, 40)},
+		{name: "empty size", p: image.Pt(30, 40), ref: image.Pt(720, 1280), size: image.Pt(0, 0), want: image.Pt(0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scalePoint(tt.p, tt.ref, tt.size); got != tt.want {
+				t.Fatalf("scalePoint(%v, %v, %v) = %v, want %v", tt.p, tt.ref, tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+// displayCounter 统计获取屏幕方向和执行命令的次数
+type displayCounter struct {
+	*sim.Device
+	rotations, cmds atomic.Int32
+}
+
+func (d *displayCounter) GetDisplayRotate(handle uintptr) (int, error) {
+	d.rotations.Add(1)
+	return d.Device.GetDisplayRotate(handle)
+}
+
+func (d *displayCounter) ExecCmd(handle uintptr, wait bool, cmd string) (string, error) {
+	d.cmds.Add(1)
+	return d.Device.ExecCmd(handle, wait, cmd)
+}
+
+// rotatingDevice 返回有竖屏 portrait 和横屏 landscape 两个画面的模拟设备，初始为竖屏
+func rotatingDevice(t *testing.T) (*Device, *displayCounter) {
+	t.Helper()
+	xml, err := os.ReadFile("../nodes_ex.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &displayCounter{Device: sim.New()}
+	if err := fake.AddScreen("portrait", xml); err != nil {
+		t.Fatal(err)
+	}
+	landscape := strings.Replace(string(xml), `rotation="0"`, `rotation="1"`, 1)
+	if err := fake.AddScreen("landscape", []byte(landscape)); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.NewClientWithBackend(fake)
+	if err := client.Connect("sim", 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return NewDevice(client), fake
+}
+
+// lastDown 返回最后一次按下的位置
+func lastDown(t *testing.T, fake *displayCounter) image.Point {
+	t.Helper()
+	events := fake.Events()
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Kind == sim.EventTouchDown {
+			return image.Pt(events[i].X, events[i].Y)
+		}
+	}
+	t.Fatal("no touch down")
+	return image.Point{}
+}
+
+func TestScaled(t *testing.T) {
+	dev, fake := rotatingDevice(t)
+	tests := []struct {
+		name string
+		view *Device
+		p    image.Point
+		want image.Point
+	}{
+		{name: "half", view: dev.Scaled(image.Pt(360, 640)), p: image.Pt(180, 320), want: image.Pt(360, 640)},
+		{name: "double", view: dev.Scaled(image.Pt(1440, 2560)), p: image.Pt(1438, 2), want: image.Pt(719, 1)},
+		{name: "normalized", view: dev.Normalized(), p: Norm(0.25, 0.75), want: image.Pt(180, 960)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.view.TouchDown(tt.p.X, tt.p.Y, 1); err != nil {
+				t.Fatal(err)
+			}
+			if got := lastDown(t, fake); got != tt.want {
+				t.Fatalf("touch at %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisplayCacheRefresh(t *testing.T) {
+	dev, fake := rotatingDevice(t)
+	cached := dev.Normalized(ViewOptions{RefreshInterval: time.Hour})
+	fresh := dev.Normalized(ViewOptions{RefreshInterval: -1})
+	// 右上角：竖屏时为物理坐标右上角，横屏（90°）时为物理坐标右下角
+	topRight := Norm(1, 0)
+	portrait, landscape := image.Pt(719, 0), image.Pt(719, 1279)
+
+	touch := func(view *Device, want image.Point) {
+		t.Helper()
+		if err := view.TouchDown(topRight.X, topRight.Y, 1); err != nil {
+			t.Fatal(err)
+		}
+		if got := lastDown(t, fake); got != want {
+			t.Fatalf("touch at %v, want %v", got, want)
+		}
+	}
+
+	touch(cached, portrait)
+	cmds, rotations := fake.cmds.Load(), fake.rotations.Load()
+	if cmds == 0 || rotations != 1 {
+		t.Fatalf("first use ran %d commands and %d rotation queries", cmds, rotations)
+	}
+	touch(cached, portrait)
+	if fake.rotations.Load() != rotations {
+		t.Fatal("rotation queried again inside the refresh interval")
+	}
+
+	if err := fake.SetScreen("landscape"); err != nil {
+		t.Fatal(err)
+	}
+	// 刷新间隔内仍使用缓存的方向
+	touch(cached, portrait)
+	// 只重新获取方向，尺寸和密度不再获取
+	touch(fresh, landscape)
+	if got := fake.rotations.Load(); got != rotations+1 {
+		t.Fatalf("rotation queried %d times, want %d", got, rotations+1)
+	}
+	if got := fake.cmds.Load(); got != cmds {
+		t.Fatalf("ran %d commands after the first use, want none", got-cmds)
+	}
+	// 缓存由同一 Device 的视图共享
+	touch(cached, landscape)
+
+	if got := (ViewOptions{}).refresh(); got != DefaultDisplayRefresh {
+		t.Fatalf("default refresh = %v, want %v", got, DefaultDisplayRefresh)
+	}
+}
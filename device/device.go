@@ -1,29 +1,37 @@
 package device
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"image"
 	"mytrpc/rpc"
 	"os"
-	"syscall"
 	"time"
-	"unsafe"
 )
 
 type Device struct {
 	client *rpc.Client
+
+	// mapPoint 把调用方传入的坐标转换为触摸坐标，nil 表示不转换
+	mapPoint func(ctx context.Context, p image.Point) (image.Point, error)
+	// display 缓存屏幕信息，由同一 Device 派生的坐标视图共享
+	display *displayCache
 }
 
 func NewDevice(client *rpc.Client) *Device {
 	return &Device{
-		client: client,
+		client:  client,
+		display: &displayCache{},
 	}
 }
 
 type ScreenshotOptions struct {
+	// Format 为图片格式，默认PNG
+	Format ImageFormat
+	// Quality 为JPEG质量（1-100），为0时使用 DefaultJPEGQuality，PNG忽略该值
 	Quality int
-	Region  image.Rectangle
+	// Region 为截图区域，为空时截取整个屏幕
+	Region image.Rectangle
 }
 
 type KeyCode int
@@ -43,58 +51,55 @@ type SwipeOptions struct {
 }
 
 func (d *Device) SetRPAMode(mode int) error {
-	proc, err := d.client.GetDLL().FindProc("useNewNodeMode")
-	if err != nil {
-		return fmt.Errorf("查找useNewNodeMode函数失败: %v", err)
-	}
+	return d.SetRPAModeCtx(context.Background(), mode)
+}
 
-	ret, _, err := proc.Call(
-		uintptr(d.client.GetHandle()),
-		uintptr(mode),
-	)
+// SetRPAModeCtx 同 SetRPAMode，调用前检查 ctx 是否已取消
+func (d *Device) SetRPAModeCtx(ctx context.Context, mode int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	if ret == 0 {
-		return fmt.Errorf("设置RPA模式失败")
+	if err := d.client.Backend().UseNewNodeMode(d.client.GetHandle(), mode); err != nil {
+		return fmt.Errorf("set RPA mode failed (设置RPA模式失败): %w", err)
 	}
 
 	return nil
 }
 
 func (d *Device) TakeScreenshot(opts ScreenshotOptions) ([]byte, error) {
-	proc, err := d.client.GetDLL().FindProc("takeCaptrueCompress")
-	if err != nil {
-		return nil, fmt.Errorf("查找takeCaptrueCompress函数失败: %v", err)
-	}
-
-	var dataLen int32
-	ret, _, err := proc.Call(
-		uintptr(d.client.GetHandle()),
-		uintptr(0), // type: 0 for PNG
-		uintptr(opts.Quality),
-		uintptr(unsafe.Pointer(&dataLen)),
-	)
+	return d.TakeScreenshotCtx(context.Background(), opts)
+}
 
-	if ret == 0 {
-		return nil, errors.New("截图失败")
+// TakeScreenshotCtx 同 TakeScreenshot，调用前检查 ctx 是否已取消
+func (d *Device) TakeScreenshotCtx(ctx context.Context, opts ScreenshotOptions) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	// 安全地复制数据
-	data := make([]byte, dataLen)
-	if dataLen > 0 {
-		dataPtr := (*[1 << 30]byte)(unsafe.Pointer(ret))
-		copy(data, dataPtr[:dataLen])
+	var (
+		data []byte
+		err  error
+	)
+	if opts.Region.Empty() {
+		data, err = d.client.Backend().TakeCaptrueCompress(d.client.GetHandle(), opts.Format.native(), opts.quality())
+	} else {
+		data, err = d.takeRegion(ctx, opts)
 	}
-
-	// 释放DLL分配的内存
-	if freeProc, err := d.client.GetDLL().FindProc("freeRpcPtr"); err == nil {
-		freeProc.Call(ret)
+	if err != nil {
+		return nil, fmt.Errorf("screenshot failed (截图失败): %w", err)
 	}
 
 	return data, nil
 }
 
 func (d *Device) GetScreenshot() ([]byte, error) {
-	return d.TakeScreenshot(ScreenshotOptions{
+	return d.GetScreenshotCtx(context.Background())
+}
+
+// GetScreenshotCtx 同 GetScreenshot，调用前检查 ctx 是否已取消
+func (d *Device) GetScreenshotCtx(ctx context.Context) ([]byte, error) {
+	return d.TakeScreenshotCtx(ctx, ScreenshotOptions{
 		Quality: 90,
 		Region: image.Rectangle{
 			Min: image.Point{X: 0, Y: 0},
@@ -104,41 +109,53 @@ func (d *Device) GetScreenshot() ([]byte, error) {
 }
 
 func (d *Device) KeyPress(code KeyCode) error {
-	proc, err := d.client.GetDLL().FindProc("keyPress")
-	if err != nil {
-		return fmt.Errorf("查找keyPress函数失败: %v", err)
+	return d.KeyPressCtx(context.Background(), code)
+}
+
+// KeyPressCtx 同 KeyPress，调用前检查 ctx 是否已取消
+func (d *Device) KeyPressCtx(ctx context.Context, code KeyCode) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	ret, _, err := proc.Call(
-		uintptr(d.client.GetHandle()),
-		uintptr(code),
-	)
-	if ret == 0 {
-		return errors.New("按键操作失败")
+	if err := d.client.Backend().KeyPress(d.client.GetHandle(), int(code)); err != nil {
+		return fmt.Errorf("key press failed (按键操作失败): %w", err)
 	}
 
 	return nil
 }
 
 func (d *Device) Swipe(opts SwipeOptions) error {
-	proc, err := d.client.GetDLL().FindProc("swipe")
+	return d.SwipeCtx(context.Background(), opts)
+}
+
+// SwipeCtx 同 Swipe，调用前检查 ctx 是否已取消
+func (d *Device) SwipeCtx(ctx context.Context, opts SwipeOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	startX, startY, err := d.touchPoint(ctx, opts.StartX, opts.StartY)
+	if err != nil {
+		return err
+	}
+	endX, endY, err := d.touchPoint(ctx, opts.EndX, opts.EndY)
 	if err != nil {
-		return fmt.Errorf("查找swipe函数失败: %v", err)
-	}
-
-	ret, _, err := proc.Call(
-		uintptr(d.client.GetHandle()),
-		uintptr(1),
-		uintptr(opts.StartX),
-		uintptr(opts.StartY),
-		uintptr(opts.EndX),
-		uintptr(opts.EndY),
-		uintptr(opts.Duration.Milliseconds()),
-		uintptr(0),
+		return err
+	}
+
+	err = d.client.Backend().Swipe(
+		d.client.GetHandle(),
+		1,
+		startX,
+		startY,
+		endX,
+		endY,
+		int(opts.Duration.Milliseconds()),
+		false,
 	)
-
-	if ret == 0 {
-		return errors.New("滑动操作失败")
+	if err != nil {
+		return fmt.Errorf("swipe failed (滑动操作失败): %w", err)
 	}
 
 	return nil
@@ -146,90 +163,86 @@ func (d *Device) Swipe(opts SwipeOptions) error {
 
 // LongClick 长按操作
 func (d *Device) LongClick(fingerID int, x, y int, duration float64) error {
-	// 按下
-	proc, err := d.client.GetDLL().FindProc("touchDown")
+	return d.LongClickCtx(context.Background(), fingerID, x, y, duration)
+}
+
+// LongClickCtx 同 LongClick，ctx 取消时提前抬起并返回 ctx 的错误
+func (d *Device) LongClickCtx(ctx context.Context, fingerID int, x, y int, duration float64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	x, y, err := d.touchPoint(ctx, x, y)
 	if err != nil {
-		return fmt.Errorf("查找touchDown函数失败: %v", err)
+		return err
 	}
 
-	ret, _, err := proc.Call(
-		uintptr(d.client.GetHandle()),
-		uintptr(fingerID),
-		uintptr(x),
-		uintptr(y),
-	)
-	if ret == 0 {
-		return errors.New("按下操作失败")
+	// 按下
+	if err := d.client.Backend().TouchDown(d.client.GetHandle(), fingerID, x, y); err != nil {
+		return fmt.Errorf("touch down failed (按下操作失败): %w", err)
 	}
 
-	// 等待指定时间
-	time.Sleep(time.Duration(duration * float64(time.Second)))
+	// 等待指定时间，被取消时仍然抬起，避免手指停留在按下状态
+	waitErr := Sleep(ctx, time.Duration(duration*float64(time.Second)))
 
 	// 抬起
-	proc, err = d.client.GetDLL().FindProc("touchUp")
-	if err != nil {
-		return fmt.Errorf("查找touchUp函数失败: %v", err)
-	}
-
-	ret, _, err = proc.Call(
-		uintptr(d.client.GetHandle()),
-		uintptr(fingerID),
-		uintptr(x),
-		uintptr(y),
-	)
-	if ret == 0 {
-		return errors.New("抬起操作失败")
+	if err := d.client.Backend().TouchUp(d.client.GetHandle(), fingerID, x, y); err != nil {
+		return fmt.Errorf("touch up failed (抬起操作失败): %w", err)
 	}
 
-	return nil
+	return waitErr
 }
 
 // SaveScreenshotToFile 保存截图到文件
 func (d *Device) SaveScreenshotToFile(opts ScreenshotOptions, filePath string) error {
-	// 尝试不同的函数名
-	var proc *syscall.Proc
-	var err error
+	return d.SaveScreenshotToFileCtx(context.Background(), opts, filePath)
+}
 
-	for _, funcName := range []string{"screentshotEx", "ScreentshotEx", "screentShotEx"} {
-		if proc, err = d.client.GetDLL().FindProc(funcName); err == nil {
-			break
-		}
+// SaveScreenshotToFileCtx 同 SaveScreenshotToFile，ctx 取消时在原生调用之间中止
+func (d *Device) SaveScreenshotToFileCtx(ctx context.Context, opts ScreenshotOptions, filePath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
+	caps, err := d.client.Capabilities()
 	if err != nil {
-		// 如果都失败了，尝试使用TakeScreenshot并手动保存
-		data, err := d.TakeScreenshot(opts)
-		if err != nil {
-			return fmt.Errorf("截图失败: %v", err)
-		}
-
-		// 创建文件并写入数据
-		file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY, 0644)
+		return fmt.Errorf("screenshot failed (截图失败): %w", err)
+	}
+
+	// 格式优先按文件扩展名选择
+	opts.Format = formatForFile(filePath, opts.Format)
+
+	// 库支持时由设备端直接保存，否则截图后在本地写入
+	if caps.ScreenshotEx {
+		err := d.client.Backend().ScreenshotEx(
+			d.client.GetHandle(),
+			opts.Region.Min.X,
+			opts.Region.Min.Y,
+			opts.Region.Max.X,
+			opts.Region.Max.Y,
+			opts.Format.native(),
+			opts.quality(),
+			filePath,
+		)
 		if err != nil {
-			return fmt.Errorf("创建文件失败: %v", err)
+			return fmt.Errorf("screenshot failed (截图失败): %w", err)
 		}
-		defer file.Close()
-
-		if _, err := file.Write(data); err != nil {
-			return fmt.Errorf("写入文件失败: %v", err)
-		}
-
 		return nil
 	}
 
-	ret, _, err := proc.Call(
-		uintptr(d.client.GetHandle()),
-		uintptr(opts.Region.Min.X),
-		uintptr(opts.Region.Min.Y),
-		uintptr(opts.Region.Max.X),
-		uintptr(opts.Region.Max.Y),
-		uintptr(1), // type: 1 for JPG
-		uintptr(opts.Quality),
-		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(filePath))),
-	)
+	data, err := d.TakeScreenshotCtx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("screenshot failed (截图失败): %w", err)
+	}
 
-	if ret == 0 {
-		return errors.New("保存截图失败")
+	// 创建文件并写入数据
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("create file failed (创建文件失败): %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("write file failed (写入文件失败): %w", err)
 	}
 
 	return nil
@@ -237,46 +250,37 @@ func (d *Device) SaveScreenshotToFile(opts ScreenshotOptions, filePath string) e
 
 // ExecCmd 执行命令
 func (d *Device) ExecCmd(cmd string) (string, error) {
-	proc, err := d.client.GetDLL().FindProc("execCmd")
-	if err != nil {
-		return "", fmt.Errorf("查找execCmd函数失败: %v", err)
-	}
-
-	cmdBytes := []byte(cmd + "\x00")
-	ret, _, err := proc.Call(
-		uintptr(d.client.GetHandle()),
-		uintptr(1), // sync mode
-		uintptr(unsafe.Pointer(&cmdBytes[0])),
-	)
+	return d.ExecCmdCtx(context.Background(), cmd)
+}
 
-	if ret == 0 {
-		return "", errors.New("执行命令失败")
+// ExecCmdCtx 同 ExecCmd，调用前检查 ctx 是否已取消
+func (d *Device) ExecCmdCtx(ctx context.Context, cmd string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
 	}
 
-	// 使用 unsafe.String 替代 syscall.UTF16PtrToString
-	ptr := (*uint16)(unsafe.Pointer(ret))
-	n := 0
-	for *(*uint16)(unsafe.Pointer(uintptr(unsafe.Pointer(ptr)) + uintptr(n)*2)) != 0 {
-		n++
+	// sync mode
+	out, err := d.client.Backend().ExecCmd(d.client.GetHandle(), true, cmd)
+	if err != nil {
+		return "", fmt.Errorf("exec command failed (执行命令失败): %w", err)
 	}
-	return unsafe.String((*byte)(unsafe.Pointer(ptr)), n*2), nil
+
+	return out, nil
 }
 
 // OpenApp 打开应用
 func (d *Device) OpenApp(packageName string) error {
-	proc, err := d.client.GetDLL().FindProc("openApp")
-	if err != nil {
-		return fmt.Errorf("查找openApp函数失败: %v", err)
-	}
+	return d.OpenAppCtx(context.Background(), packageName)
+}
 
-	pkgBytes := []byte(packageName + "\x00")
-	ret, _, err := proc.Call(
-		uintptr(d.client.GetHandle()),
-		uintptr(unsafe.Pointer(&pkgBytes[0])),
-	)
+// OpenAppCtx 同 OpenApp，调用前检查 ctx 是否已取消
+func (d *Device) OpenAppCtx(ctx context.Context, packageName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	if ret == 0 {
-		return fmt.Errorf("打开应用 %s 失败", packageName)
+	if err := d.client.Backend().OpenApp(d.client.GetHandle(), packageName); err != nil {
+		return fmt.Errorf("open app %s failed (打开应用失败): %w", packageName, err)
 	}
 
 	return nil
@@ -284,19 +288,17 @@ func (d *Device) OpenApp(packageName string) error {
 
 // StopApp 关闭应用
 func (d *Device) StopApp(packageName string) error {
-	proc, err := d.client.GetDLL().FindProc("stopApp")
-	if err != nil {
-		return fmt.Errorf("查找stopApp函数失败: %v", err)
-	}
+	return d.StopAppCtx(context.Background(), packageName)
+}
 
-	pkgBytes := []byte(packageName + "\x00")
-	ret, _, err := proc.Call(
-		uintptr(d.client.GetHandle()),
-		uintptr(unsafe.Pointer(&pkgBytes[0])),
-	)
+// StopAppCtx 同 StopApp，调用前检查 ctx 是否已取消
+func (d *Device) StopAppCtx(ctx context.Context, packageName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	if ret == 0 {
-		return fmt.Errorf("关闭应用 %s 失败", packageName)
+	if err := d.client.Backend().StopApp(d.client.GetHandle(), packageName); err != nil {
+		return fmt.Errorf("stop app %s failed (关闭应用失败): %w", packageName, err)
 	}
 
 	return nil
@@ -304,19 +306,17 @@ func (d *Device) StopApp(packageName string) error {
 
 // SendText 输入文本
 func (d *Device) SendText(text string) error {
-	proc, err := d.client.GetDLL().FindProc("sendText")
-	if err != nil {
-		return fmt.Errorf("查找sendText函数失败: %v", err)
-	}
+	return d.SendTextCtx(context.Background(), text)
+}
 
-	textBytes := []byte(text + "\x00")
-	ret, _, err := proc.Call(
-		uintptr(d.client.GetHandle()),
-		uintptr(unsafe.Pointer(&textBytes[0])),
-	)
+// SendTextCtx 同 SendText，调用前检查 ctx 是否已取消
+func (d *Device) SendTextCtx(ctx context.Context, text string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	if ret == 0 {
-		return errors.New("发送文本失败")
+	if err := d.client.Backend().SendText(d.client.GetHandle(), text); err != nil {
+		return fmt.Errorf("send text failed (发送文本失败): %w", err)
 	}
 
 	return nil
@@ -324,21 +324,22 @@ func (d *Device) SendText(text string) error {
 
 // ClearText 清除文本
 func (d *Device) ClearText(count int) error {
-	// 使用 sendText 发送退格键
-	proc, err := d.client.GetDLL().FindProc("keyPress")
-	if err != nil {
-		return fmt.Errorf("查找keyPress函数失败: %v", err)
+	return d.ClearTextCtx(context.Background(), count)
+}
+
+// ClearTextCtx 同 ClearText，ctx 取消时在两次退格之间中止
+func (d *Device) ClearTextCtx(ctx context.Context, count int) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
 	// 发送count个退格键
 	for i := 0; i < count; i++ {
-		ret, _, _ := proc.Call(
-			uintptr(d.client.GetHandle()),
-			uintptr(67),
-		)
-
-		if ret == 0 {
-			return errors.New("清除文本失败")
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := d.client.Backend().KeyPress(d.client.GetHandle(), 67); err != nil {
+			return fmt.Errorf("clear text failed (清除文本失败): %w", err)
 		}
 	}
 
@@ -347,103 +348,128 @@ func (d *Device) ClearText(count int) error {
 
 // DumpNodeXml 导出节点XML信息
 func (d *Device) DumpNodeXml(dumpAll bool) (string, error) {
-	proc, err := d.client.GetDLL().FindProc("dumpNodeXml")
-	if err != nil {
-		return "", fmt.Errorf("查找dumpNodeXml函数失败: %v", err)
-	}
+	return d.DumpNodeXmlCtx(context.Background(), dumpAll)
+}
 
-	var mode uintptr
-	if dumpAll {
-		mode = 1
+// DumpNodeXmlCtx 同 DumpNodeXml，调用前检查 ctx 是否已取消
+func (d *Device) DumpNodeXmlCtx(ctx context.Context, dumpAll bool) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
 	}
 
-	ret, _, _ := proc.Call(d.client.GetHandle(), mode)
-	if ret == 0 {
-		return "", fmt.Errorf("导出节点XML失败")
+	xml, err := d.client.Backend().DumpNodeXml(d.client.GetHandle(), dumpAll)
+	if err != nil {
+		return "", fmt.Errorf("dump node xml failed (导出节点XML失败): %w", err)
 	}
 
-	// 转换返回的字符串
-	ptr := (*byte)(unsafe.Pointer(ret))
-	n := 0
-	for *(*byte)(unsafe.Pointer(uintptr(unsafe.Pointer(ptr)) + uintptr(n))) != 0 {
-		n++
+	return xml, nil
+}
+
+// DumpNodeXmlEx 导出节点XML信息（带工作模式和超时参数）
+func (d *Device) DumpNodeXmlEx(workMode bool, timeout int) (string, error) {
+	return d.DumpNodeXmlExCtx(context.Background(), workMode, timeout)
+}
+
+// DumpNodeXmlExCtx 同 DumpNodeXmlEx，调用前检查 ctx 是否已取消
+func (d *Device) DumpNodeXmlExCtx(ctx context.Context, workMode bool, timeout int) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
 	}
-	bytes := make([]byte, n)
-	copy(bytes, unsafe.Slice(ptr, n))
 
-	// 释放内存
-	if freeProc, err := d.client.GetDLL().FindProc("freeRpcPtr"); err == nil {
-		freeProc.Call(ret)
+	xml, err := d.client.Backend().DumpNodeXmlEx(d.client.GetHandle(), workMode, timeout)
+	if err != nil {
+		return "", fmt.Errorf("dump node xml failed (导出节点XML失败): %w", err)
 	}
 
-	return string(bytes), nil
+	return xml, nil
 }
 
-// DumpNodeXmlEx 导出节点XML信息（带工作模式和超时参数）
-func (d *Device) DumpNodeXmlEx(workMode bool, timeout int) (string, error) {
-	proc, err := d.client.GetDLL().FindProc("dumpNodeXmlEx")
+func (d *Device) TouchDown(x, y int, fingerID int) error {
+	return d.TouchDownCtx(context.Background(), x, y, fingerID)
+}
+
+// TouchDownCtx 同 TouchDown，调用前检查 ctx 是否已取消
+func (d *Device) TouchDownCtx(ctx context.Context, x, y int, fingerID int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	x, y, err := d.touchPoint(ctx, x, y)
 	if err != nil {
-		return "", fmt.Errorf("查找dumpNodeXmlEx函数失败: %v", err)
+		return err
 	}
 
-	var mode uintptr
-	if workMode {
-		mode = 1
+	if err := d.client.Backend().TouchDown(d.client.GetHandle(), fingerID, x, y); err != nil {
+		return fmt.Errorf("touch down failed (触摸按下失败): %w", err)
 	}
+	return nil
+}
+
+func (d *Device) TouchUp(x, y int, fingerID int) error {
+	return d.TouchUpCtx(context.Background(), x, y, fingerID)
+}
 
-	ret, _, _ := proc.Call(d.client.GetHandle(), mode, uintptr(timeout))
-	if ret == 0 {
-		return "", fmt.Errorf("导出节点XML失败")
+// TouchUpCtx 同 TouchUp，调用前检查 ctx 是否已取消
+func (d *Device) TouchUpCtx(ctx context.Context, x, y int, fingerID int) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	// 转换返回的字符串
-	ptr := (*byte)(unsafe.Pointer(ret))
-	n := 0
-	for *(*byte)(unsafe.Pointer(uintptr(unsafe.Pointer(ptr)) + uintptr(n))) != 0 {
-		n++
+	x, y, err := d.touchPoint(ctx, x, y)
+	if err != nil {
+		return err
 	}
-	bytes := make([]byte, n)
-	copy(bytes, unsafe.Slice(ptr, n))
 
-	// 释放内存
-	if freeProc, err := d.client.GetDLL().FindProc("freeRpcPtr"); err == nil {
-		freeProc.Call(ret)
+	if err := d.client.Backend().TouchUp(d.client.GetHandle(), fingerID, x, y); err != nil {
+		return fmt.Errorf("touch up failed (触摸抬起失败): %w", err)
 	}
+	return nil
+}
 
-	return string(bytes), nil
+func (d *Device) TouchMove(x, y int, fingerID int) error {
+	return d.TouchMoveCtx(context.Background(), x, y, fingerID)
 }
 
-func (d *Device) TouchDown(x, y int, fingerID int) error {
-	proc, err := d.client.GetDLL().FindProc("touchDown")
+// TouchMoveCtx 将按下的手指移动到 (x, y)，调用前检查 ctx 是否已取消
+func (d *Device) TouchMoveCtx(ctx context.Context, x, y int, fingerID int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	x, y, err := d.touchPoint(ctx, x, y)
 	if err != nil {
-		return fmt.Errorf("查找touchDown函数失败: %v", err)
+		return err
 	}
-	ret, _, _ := proc.Call(
-		uintptr(d.client.GetHandle()),
-		uintptr(fingerID),
-		uintptr(x),
-		uintptr(y),
-	)
-	if ret == 0 {
-		return errors.New("触摸按下失败")
+
+	if err := d.client.Backend().TouchMove(d.client.GetHandle(), fingerID, x, y); err != nil {
+		return fmt.Errorf("touch move failed (触摸移动失败): %w", err)
 	}
 	return nil
 }
 
-func (d *Device) TouchUp(x, y int, fingerID int) error {
-	proc, err := d.client.GetDLL().FindProc("touchUp")
+// touchPoint 把调用方坐标转换为发送给原生库的触摸坐标
+func (d *Device) touchPoint(ctx context.Context, x, y int) (int, int, error) {
+	if d.mapPoint == nil {
+		return x, y, nil
+	}
+	p, err := d.mapPoint(ctx, image.Pt(x, y))
 	if err != nil {
-		return fmt.Errorf("查找touchUp函数失败: %v", err)
+		return 0, 0, fmt.Errorf("map coordinates failed (坐标转换失败): %w", err)
 	}
+	return p.X, p.Y, nil
+}
 
-	ret, _, _ := proc.Call(
-		uintptr(d.client.GetHandle()),
-		uintptr(fingerID),
-		uintptr(x),
-		uintptr(y),
-	)
-	if ret == 0 {
-		return errors.New("触摸抬起失败")
+// Sleep 等待 d 或直到 ctx 取消，取消时返回 ctx 的错误
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
-	return nil
 }
@@ -0,0 +1,215 @@
+package device
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Rotation 为屏幕方向，取值与 getDisplayRotate 及 Android Surface.ROTATION_* 一致，
+// 表示画面相对自然方向逆时针旋转的角度
+type Rotation int
+
+const (
+	Rotation0 Rotation = iota
+	Rotation90
+	Rotation180
+	Rotation270
+)
+
+// Degrees 返回旋转角度
+func (r Rotation) Degrees() int {
+	return int(r.normalize()) * 90
+}
+
+// Landscape 报告该方向下画面宽高是否与自然方向相反
+func (r Rotation) Landscape() bool {
+	return r.normalize()%2 == 1
+}
+
+func (r Rotation) normalize() Rotation {
+	return (r%4 + 4) % 4
+}
+
+func (r Rotation) String() string {
+	return strconv.Itoa(r.Degrees()) + "°"
+}
+
+// DisplayInfo 为屏幕几何信息。
+//
+// Width 和 Height 为自然方向（通常为竖屏）下的像素尺寸，触摸坐标也使用该方向，称为物理坐标；
+// 截图和UI层级中的坐标随屏幕方向旋转，称为逻辑坐标。
+type DisplayInfo struct {
+	Width    int
+	Height   int
+	Density  int
+	Rotation Rotation
+}
+
+// Size 返回当前方向下画面的逻辑尺寸
+func (i DisplayInfo) Size() image.Point {
+	if i.Rotation.Landscape() {
+		return image.Pt(i.Height, i.Width)
+	}
+	return image.Pt(i.Width, i.Height)
+}
+
+// Bounds 返回当前方向下画面的逻辑区域
+func (i DisplayInfo) Bounds() image.Rectangle {
+	return image.Rectangle{Max: i.Size()}
+}
+
+// ToPhysical 把逻辑坐标转换为物理触摸坐标
+func (i DisplayInfo) ToPhysical(p image.Point) image.Point {
+	w, h := i.Width, i.Height
+	switch i.Rotation.normalize() {
+	case Rotation90:
+		return image.Pt(w-1-p.Y, p.X)
+	case Rotation180:
+		return image.Pt(w-1-p.X, h-1-p.Y)
+	case Rotation270:
+		return image.Pt(p.Y, h-1-p.X)
+	}
+	return p
+}
+
+// ToLogical 把物理触摸坐标转换为逻辑坐标，是 ToPhysical 的逆变换
+func (i DisplayInfo) ToLogical(p image.Point) image.Point {
+	w, h := i.Width, i.Height
+	switch i.Rotation.normalize() {
+	case Rotation90:
+		return image.Pt(p.Y, w-1-p.X)
+	case Rotation180:
+		return image.Pt(w-1-p.X, h-1-p.Y)
+	case Rotation270:
+		return image.Pt(h-1-p.Y, p.X)
+	}
+	return p
+}
+
+// RectToPhysical 把逻辑区域转换为物理区域
+func (i DisplayInfo) RectToPhysical(r image.Rectangle) image.Rectangle {
+	return transformRect(r, i.ToPhysical)
+}
+
+// RectToLogical 把物理区域转换为逻辑区域
+func (i DisplayInfo) RectToLogical(r image.Rectangle) image.Rectangle {
+	return transformRect(r, i.ToLogical)
+}
+
+// transformRect 按像素转换区域的两个对角，结果仍为左闭右开
+func transformRect(r image.Rectangle, fn func(image.Point) image.Point) image.Rectangle {
+	r = r.Canon()
+	if r.Empty() {
+		p := fn(r.Min)
+		return image.Rectangle{Min: p, Max: p}
+	}
+	a, b := fn(r.Min), fn(r.Max.Sub(image.Pt(1, 1)))
+	out := image.Rectangle{Min: a, Max: b}.Canon()
+	out.Max = out.Max.Add(image.Pt(1, 1))
+	return out
+}
+
+// DisplayInfo 返回屏幕尺寸、密度和方向，方向来自 getDisplayRotate，尺寸和密度来自 wm size 和 wm density
+func (d *Device) DisplayInfo() (DisplayInfo, error) {
+	return d.DisplayInfoCtx(context.Background())
+}
+
+// DisplayInfoCtx 同 DisplayInfo，每个步骤前检查 ctx 是否已取消
+func (d *Device) DisplayInfoCtx(ctx context.Context) (DisplayInfo, error) {
+	var info DisplayInfo
+	if err := ctx.Err(); err != nil {
+		return info, err
+	}
+
+	rotation, err := d.client.Backend().GetDisplayRotate(d.client.GetHandle())
+	if err != nil {
+		return info, fmt.Errorf("get display rotation failed (获取屏幕方向失败): %w", err)
+	}
+	info.Rotation = Rotation(rotation).normalize()
+
+	out, err := d.ExecCmdCtx(ctx, "wm size")
+	if err != nil {
+		return info, err
+	}
+	if info.Width, info.Height, err = ParseWmSize(out); err != nil {
+		return info, err
+	}
+
+	out, err = d.ExecCmdCtx(ctx, "wm density")
+	if err != nil {
+		return info, err
+	}
+	if info.Density, err = ParseWmDensity(out); err != nil {
+		return info, err
+	}
+	return info, nil
+}
+
+// Logical 返回使用逻辑坐标的 Device，触摸、滑动、长按和手势的坐标按 info 的方向转换为物理坐标。
+// 屏幕方向改变后需要重新获取 DisplayInfo 并创建新的 Device。
+func (d *Device) Logical(info DisplayInfo) *Device {
+	return d.withMapper(func(_ context.Context, p image.Point) (image.Point, error) {
+		return info.ToPhysical(p), nil
+	})
+}
+
+// withMapper 返回共享同一客户端、在现有转换之前先做 fn 转换的 Device
+func (d *Device) withMapper(fn func(ctx context.Context, p image.Point) (image.Point, error)) *Device {
+	view := &Device{client: d.client, mapPoint: fn, display: d.display}
+	if next := d.mapPoint; next != nil {
+		view.mapPoint = func(ctx context.Context, p image.Point) (image.Point, error) {
+			p, err := fn(ctx, p)
+			if err != nil {
+				return p, err
+			}
+			return next(ctx, p)
+		}
+	}
+	return view
+}
+
+var (
+	wmSizePattern    = regexp.MustCompile(`(\d+)x(\d+)`)
+	wmDensityPattern = regexp.MustCompile(`:\s*(\d+)`)
+)
+
+// ParseWmSize 解析 wm size 的输出，有 Override size 时优先使用
+func ParseWmSize(out string) (width, height int, err error) {
+	m := parseWmOutput(out, wmSizePattern)
+	if m == nil {
+		return 0, 0, fmt.Errorf("unexpected wm size output (无法解析屏幕尺寸): %q", strings.TrimSpace(out))
+	}
+	width, _ = strconv.Atoi(m[1])
+	height, _ = strconv.Atoi(m[2])
+	return width, height, nil
+}
+
+// ParseWmDensity 解析 wm density 的输出，有 Override density 时优先使用
+func ParseWmDensity(out string) (int, error) {
+	m := parseWmOutput(out, wmDensityPattern)
+	if m == nil {
+		return 0, fmt.Errorf("unexpected wm density output (无法解析屏幕密度): %q", strings.TrimSpace(out))
+	}
+	density, _ := strconv.Atoi(m[1])
+	return density, nil
+}
+
+// parseWmOutput 返回第一处匹配，之后遇到 Override 行时以其为准
+func parseWmOutput(out string, pattern *regexp.Regexp) []string {
+	var found []string
+	for _, line := range strings.Split(out, "\n") {
+		m := pattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		if found != nil && !strings.Contains(line, "Override") {
+			continue
+		}
+		found = m
+	}
+	return found
+}
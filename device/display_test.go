@@ -0,0 +1,202 @@
+package device_test
+
+import (
+	"image"
+	"os"
+	"strings"
+	"testing"
+
+	"mytrpc/device"
+	"mytrpc/rpc"
+	"mytrpc/sim"
+)
+
+func TestParseWmSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		w, h    int
+		wantErr bool
+	}{
+		{name: "physical", out: "Physical size: 720x1280\n", w: 720, h: 1280},
+		{name: "override", out: "Physical size: 1080x2340\nOverride size: 720x1560\n", w: 720, h: 1560},
+		{name: "override first", out: "Override size: 720x1560\nPhysical size: 1080x2340\n", w: 720, h: 1560},
+		{name: "crlf", out: "Physical size: 1080x1920\r\n", w: 1080, h: 1920},
+		{name: "empty", out: "", wantErr: true},
+		{name: "error", out: "/system/bin/sh: wm: not found\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, h, err := device.ParseWmSize(tt.out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if w != tt.w || h != tt.h {
+				t.Fatalf("got %dx%d, want %dx%d", w, h, tt.w, tt.h)
+			}
+		})
+	}
+}
+
+func TestParseWmDensity(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		want    int
+		wantErr bool
+	}{
+		{name: "physical", out: "Physical density: 320\n", want: 320},
+		{name: "override", out: "Physical density: 480\nOverride density: 400\n", want: 400},
+		{name: "override first", out: "Override density: 400\nPhysical density: 480\n", want: 400},
+		{name: "empty", out: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := device.ParseWmDensity(tt.out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	tests := []struct {
+		r         device.Rotation
+		degrees   int
+		landscape bool
+	}{
+		{device.Rotation0, 0, false},
+		{device.Rotation90, 90, true},
+		{device.Rotation180, 180, false},
+		{device.Rotation270, 270, true},
+		{4, 0, false},
+		{-1, 270, true},
+	}
+	for _, tt := range tests {
+		if got := tt.r.Degrees(); got != tt.degrees {
+			t.Errorf("Rotation(%d).Degrees() = %d, want %d", tt.r, got, tt.degrees)
+		}
+		if got := tt.r.Landscape(); got != tt.landscape {
+			t.Errorf("Rotation(%d).Landscape() = %v, want %v", tt.r, got, tt.landscape)
+		}
+	}
+}
+
+func TestDisplayInfoRotations(t *testing.T) {
+	// 物理尺寸 720x1280，逻辑坐标的左上角和右下角在各方向下对应的物理坐标
+	tests := []struct {
+		rotation    device.Rotation
+		size        image.Point
+		topLeft     image.Point
+		bottomRight image.Point
+	}{
+		{device.Rotation0, image.Pt(720, 1280), image.Pt(0, 0), image.Pt(719, 1279)},
+		{device.Rotation90, image.Pt(1280, 720), image.Pt(719, 0), image.Pt(0, 1279)},
+		{device.Rotation180, image.Pt(720, 1280), image.Pt(719, 1279), image.Pt(0, 0)},
+		{device.Rotation270, image.Pt(1280, 720), image.Pt(0, 1279), image.Pt(719, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rotation.String(), func(t *testing.T) {
+			info := device.DisplayInfo{Width: 720, Height: 1280, Rotation: tt.rotation}
+			if got := info.Size(); got != tt.size {
+				t.Errorf("Size() = %v, want %v", got, tt.size)
+			}
+			if got := info.ToPhysical(image.Pt(0, 0)); got != tt.topLeft {
+				t.Errorf("ToPhysical(top left) = %v, want %v", got, tt.topLeft)
+			}
+			if got := info.ToPhysical(tt.size.Sub(image.Pt(1, 1))); got != tt.bottomRight {
+				t.Errorf("ToPhysical(bottom right) = %v, want %v", got, tt.bottomRight)
+			}
+			if got := info.RectToPhysical(info.Bounds()); got != image.Rect(0, 0, 720, 1280) {
+				t.Errorf("RectToPhysical(Bounds()) = %v, want the physical screen", got)
+			}
+		})
+	}
+}
+
+func TestToPhysicalToLogicalInverse(t *testing.T) {
+	physical := image.Rect(0, 0, 720, 1280)
+	for r := device.Rotation0; r <= device.Rotation270; r++ {
+		info := device.DisplayInfo{Width: 720, Height: 1280, Rotation: r}
+		size := info.Size()
+		for y := 0; y < size.Y; y += 37 {
+			for x := 0; x < size.X; x += 29 {
+				p := image.Pt(x, y)
+				phys := info.ToPhysical(p)
+				if !phys.In(physical) {
+					t.Fatalf("%v: ToPhysical(%v) = %v outside the screen", r, p, phys)
+				}
+				if back := info.ToLogical(phys); back != p {
+					t.Fatalf("%v: ToLogical(ToPhysical(%v)) = %v", r, p, back)
+				}
+			}
+		}
+		for y := 0; y < 1280; y += 41 {
+			for x := 0; x < 720; x += 23 {
+				p := image.Pt(x, y)
+				if back := info.ToPhysical(info.ToLogical(p)); back != p {
+					t.Fatalf("%v: ToPhysical(ToLogical(%v)) = %v", r, p, back)
+				}
+			}
+		}
+
+		rect := image.Rect(10, 20, 110, 70)
+		phys := info.RectToPhysical(rect)
+		if phys.Dx()*phys.Dy() != rect.Dx()*rect.Dy() {
+			t.Errorf("%v: RectToPhysical(%v) = %v changes the area", r, rect, phys)
+		}
+		if back := info.RectToLogical(phys); back != rect {
+			t.Errorf("%v: RectToLogical(RectToPhysical(%v)) = %v", r, rect, back)
+		}
+	}
+}
+
+func TestDisplayInfoFromDevice(t *testing.T) {
+	xml, err := os.ReadFile("../nodes_ex.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		rotation string
+		wmSize   string
+		want     device.DisplayInfo
+	}{
+		{name: "defaults", rotation: "0", want: device.DisplayInfo{Width: 720, Height: 1280, Density: 320}},
+		{name: "rotated", rotation: "1", want: device.DisplayInfo{Width: 1280, Height: 720, Density: 320, Rotation: device.Rotation90}},
+		{
+			name: "override", rotation: "0",
+			wmSize: "Physical size: 1080x1920\nOverride size: 720x1280\n",
+			want:   device.DisplayInfo{Width: 720, Height: 1280, Density: 320},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := sim.New()
+			screen := strings.Replace(string(xml), `rotation="0"`, `rotation="`+tt.rotation+`"`, 1)
+			if err := fake.AddScreen("home", []byte(screen)); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wmSize != "" {
+				fake.SetCommandOutput("wm size", tt.wmSize)
+			}
+			client := rpc.NewClientWithBackend(fake)
+			if err := client.Connect("sim", 0); err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			info, err := device.NewDevice(client).DisplayInfo()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info != tt.want {
+				t.Fatalf("DisplayInfo() = %+v, want %+v", info, tt.want)
+			}
+		})
+	}
+}
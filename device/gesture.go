@@ -0,0 +1,305 @@
+package device
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultSampleInterval 为手势默认的采样间隔，即相邻两次 touchMove 的时间间隔
+const DefaultSampleInterval = 16 * time.Millisecond
+
+// TouchAction 为触摸事件类型
+type TouchAction int
+
+const (
+	TouchActionDown TouchAction = iota
+	TouchActionMove
+	TouchActionUp
+)
+
+func (a TouchAction) String() string {
+	switch a {
+	case TouchActionDown:
+		return "down"
+	case TouchActionMove:
+		return "move"
+	case TouchActionUp:
+		return "up"
+	}
+	return fmt.Sprintf("TouchAction(%d)", int(a))
+}
+
+// TouchEvent 是时间线上的一个触摸事件，At 为相对手势开始的时间
+type TouchEvent struct {
+	At     time.Duration
+	Action TouchAction
+	Finger int
+	Point  image.Point
+}
+
+// segment 是手势中的一段轨迹，at 把 [0,1] 的进度映射为坐标
+type segment struct {
+	dur  time.Duration
+	at   func(t float64) image.Point
+	ease func(t float64) float64
+	hold bool
+}
+
+// Gesture 描述一根手指从按下到抬起的轨迹，由若干段直线、曲线或停留组成。
+//
+//	err := dev.Gesture(image.Pt(100, 800)).
+//		MoveTo(image.Pt(100, 400), 300*time.Millisecond).
+//		Hold(200*time.Millisecond).
+//		Perform(ctx)
+type Gesture struct {
+	dev      *Device
+	finger   int
+	start    image.Point
+	cur      image.Point
+	interval time.Duration
+	ease     func(t float64) float64
+	segments []segment
+}
+
+// Gesture 从 start 开始构建一个单指手势，默认使用手指1
+func (d *Device) Gesture(start image.Point) *Gesture {
+	return &Gesture{
+		dev:      d,
+		finger:   1,
+		start:    start,
+		cur:      start,
+		interval: DefaultSampleInterval,
+	}
+}
+
+// Finger 设置手指编号
+func (g *Gesture) Finger(id int) *Gesture {
+	g.finger = id
+	return g
+}
+
+// SampleInterval 设置采样间隔，间隔越小轨迹越平滑，原生调用也越多
+func (g *Gesture) SampleInterval(interval time.Duration) *Gesture {
+	if interval > 0 {
+		g.interval = interval
+	}
+	return g
+}
+
+// Ease 设置之后各段的速度曲线，fn 把时间进度 [0,1] 映射为路程进度 [0,1]，nil 表示匀速
+func (g *Gesture) Ease(fn func(t float64) float64) *Gesture {
+	g.ease = fn
+	return g
+}
+
+// MoveTo 在 dur 内沿直线移动到 p
+func (g *Gesture) MoveTo(p image.Point, dur time.Duration) *Gesture {
+	from := g.cur
+	g.add(dur, func(t float64) image.Point { return lerp(from, p, t) })
+	g.cur = p
+	return g
+}
+
+// CurveTo 在 dur 内沿以 control 为控制点的二次贝塞尔曲线移动到 p
+func (g *Gesture) CurveTo(control, p image.Point, dur time.Duration) *Gesture {
+	from := g.cur
+	g.add(dur, func(t float64) image.Point {
+		u := 1 - t
+		return ptf(
+			u*u*float64(from.X)+2*u*t*float64(control.X)+t*t*float64(p.X),
+			u*u*float64(from.Y)+2*u*t*float64(control.Y)+t*t*float64(p.Y),
+		)
+	})
+	g.cur = p
+	return g
+}
+
+// CubicTo 在 dur 内沿以 c1、c2 为控制点的三次贝塞尔曲线移动到 p
+func (g *Gesture) CubicTo(c1, c2, p image.Point, dur time.Duration) *Gesture {
+	from := g.cur
+	g.add(dur, func(t float64) image.Point {
+		u := 1 - t
+		a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+		return ptf(
+			a*float64(from.X)+b*float64(c1.X)+c*float64(c2.X)+d*float64(p.X),
+			a*float64(from.Y)+b*float64(c1.Y)+c*float64(c2.Y)+d*float64(p.Y),
+		)
+	})
+	g.cur = p
+	return g
+}
+
+// Along 在 dur 内沿任意轨迹移动，fn 把进度 [0,1] 映射为坐标，fn(1) 为终点
+func (g *Gesture) Along(fn func(t float64) image.Point, dur time.Duration) *Gesture {
+	g.add(dur, fn)
+	g.cur = fn(1)
+	return g
+}
+
+// Path 依次移动经过 points，每段耗时 perSegment
+func (g *Gesture) Path(points []image.Point, perSegment time.Duration) *Gesture {
+	for _, p := range points {
+		g.MoveTo(p, perSegment)
+	}
+	return g
+}
+
+// Hold 在当前位置停留 dur，期间不发送移动事件
+func (g *Gesture) Hold(dur time.Duration) *Gesture {
+	at := g.cur
+	g.segments = append(g.segments, segment{dur: dur, at: func(float64) image.Point { return at }, hold: true})
+	return g
+}
+
+func (g *Gesture) add(dur time.Duration, at func(t float64) image.Point) {
+	g.segments = append(g.segments, segment{dur: dur, at: at, ease: g.ease})
+}
+
+// End 返回手势结束时的位置
+func (g *Gesture) End() image.Point {
+	return g.cur
+}
+
+// Duration 返回手势的总时长
+func (g *Gesture) Duration() time.Duration {
+	var total time.Duration
+	for _, s := range g.segments {
+		total += s.dur
+	}
+	return total
+}
+
+// Events 按采样间隔把手势展开为触摸事件时间线，依次为按下、移动和抬起
+func (g *Gesture) Events() []TouchEvent {
+	events := []TouchEvent{{At: 0, Action: TouchActionDown, Finger: g.finger, Point: g.start}}
+	var offset time.Duration
+	last := g.start
+	for _, s := range g.segments {
+		if !s.hold {
+			n := max(1, int(math.Ceil(float64(s.dur)/float64(g.interval))))
+			for i := 1; i <= n; i++ {
+				t := float64(i) / float64(n)
+				if s.ease != nil {
+					t = s.ease(t)
+				}
+				p := s.at(t)
+				if p == last && i < n {
+					continue
+				}
+				events = append(events, TouchEvent{
+					At:     offset + time.Duration(float64(s.dur)*float64(i)/float64(n)),
+					Action: TouchActionMove,
+					Finger: g.finger,
+					Point:  p,
+				})
+				last = p
+			}
+		}
+		offset += s.dur
+	}
+	events = append(events, TouchEvent{At: offset, Action: TouchActionUp, Finger: g.finger, Point: g.cur})
+	return events
+}
+
+// Perform 执行手势，ctx 取消或出错时抬起手指并返回错误
+func (g *Gesture) Perform(ctx context.Context) error {
+	return g.dev.PlayTouches(ctx, g.Events())
+}
+
+// PlayTouches 按时间线执行触摸事件，事件按 At 排序后依次在对应时间点发送。
+// 时间以开始执行的时刻为基准，某次调用较慢时后续事件会尽快补上而不是整体顺延。
+// ctx 取消或出错时，会在最后位置抬起所有仍处于按下状态的手指。
+func (d *Device) PlayTouches(ctx context.Context, events []TouchEvent) error {
+	events = append([]TouchEvent(nil), events...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At < events[j].At })
+
+	down := make(map[int]image.Point)
+	release := func() {
+		for finger, p := range down {
+			d.TouchUp(p.X, p.Y, finger)
+		}
+	}
+
+	start := time.Now()
+	for _, ev := range events {
+		if err := Sleep(ctx, ev.At-time.Since(start)); err != nil {
+			release()
+			return err
+		}
+
+		var err error
+		switch ev.Action {
+		case TouchActionDown:
+			err = d.TouchDownCtx(ctx, ev.Point.X, ev.Point.Y, ev.Finger)
+			if err == nil {
+				down[ev.Finger] = ev.Point
+			}
+		case TouchActionMove:
+			err = d.TouchMoveCtx(ctx, ev.Point.X, ev.Point.Y, ev.Finger)
+			if err == nil {
+				down[ev.Finger] = ev.Point
+			}
+		case TouchActionUp:
+			err = d.TouchUpCtx(ctx, ev.Point.X, ev.Point.Y, ev.Finger)
+			delete(down, ev.Finger)
+		}
+		if err != nil {
+			release()
+			return err
+		}
+	}
+	return nil
+}
+
+// DragAndDrop 在 from 按住 hold 后拖动到 to，移动耗时 dur，到达后稍作停留再松开
+func (d *Device) DragAndDrop(ctx context.Context, from, to image.Point, hold, dur time.Duration) error {
+	return d.Gesture(from).
+		Hold(hold).
+		MoveTo(to, dur).
+		Hold(100 * time.Millisecond).
+		Perform(ctx)
+}
+
+// CurvedSwipe 从 from 沿以 control 为控制点的弧线滑动到 to，耗时 dur
+func (d *Device) CurvedSwipe(ctx context.Context, from, control, to image.Point, dur time.Duration) error {
+	return d.Gesture(from).CurveTo(control, to, dur).Perform(ctx)
+}
+
+// PatternLock 在 grid 区域内按 pattern 绘制 3x3 图案解锁，
+// 点位按手机键盘编号 1-9（从左到右、从上到下），每段移动耗时 perSegment
+func (d *Device) PatternLock(ctx context.Context, grid image.Rectangle, pattern []int, perSegment time.Duration) error {
+	if len(pattern) < 2 {
+		return fmt.Errorf("pattern needs at least 2 points (图案至少需要2个点): %v", pattern)
+	}
+	points := make([]image.Point, len(pattern))
+	for i, n := range pattern {
+		if n < 1 || n > 9 {
+			return fmt.Errorf("invalid pattern point %d (无效的图案点位)", n)
+		}
+		col, row := (n-1)%3, (n-1)/3
+		points[i] = image.Pt(
+			grid.Min.X+grid.Dx()*(2*col+1)/6,
+			grid.Min.Y+grid.Dy()*(2*row+1)/6,
+		)
+	}
+	return d.Gesture(points[0]).
+		Hold(50*time.Millisecond).
+		Path(points[1:], perSegment).
+		Perform(ctx)
+}
+
+// lerp 在 a 和 b 之间线性插值
+func lerp(a, b image.Point, t float64) image.Point {
+	return ptf(
+		float64(a.X)+(float64(b.X)-float64(a.X))*t,
+		float64(a.Y)+(float64(b.Y)-float64(a.Y))*t,
+	)
+}
+
+func ptf(x, y float64) image.Point {
+	return image.Pt(int(math.Round(x)), int(math.Round(y)))
+}
@@ -0,0 +1,206 @@
+package device_test
+
+import (
+	"context"
+	"errors"
+	"image"
+	"testing"
+	"time"
+
+	"mytrpc/device"
+	"mytrpc/sim"
+)
+
+func simDevice(t *testing.T) (*device.Device, *sim.Device) {
+	t.Helper()
+	fake := sim.New()
+	if err := fake.AddScreenFile("home", "../nodes_ex.xml"); err != nil {
+		t.Fatal(err)
+	}
+	return device.NewDevice(connectClient(t, fake)), fake
+}
+
+// touches 返回模拟设备记录的触摸事件
+func touches(fake *sim.Device) []sim.Event {
+	var out []sim.Event
+	for _, e := range fake.Events() {
+		switch e.Kind {
+		case sim.EventTouchDown, sim.EventTouchMove, sim.EventTouchUp:
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func TestGestureEvents(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name    string
+		gesture func(g *device.Gesture) *device.Gesture
+		want    []device.TouchEvent
+	}{
+		{
+			name:    "tap",
+			gesture: func(g *device.Gesture) *device.Gesture { return g },
+			want: []device.TouchEvent{
+				{At: 0, Action: device.TouchActionDown, Finger: 1, Point: image.Pt(0, 0)},
+				{At: 0, Action: device.TouchActionUp, Finger: 1, Point: image.Pt(0, 0)},
+			},
+		},
+		{
+			name: "linear samples",
+			gesture: func(g *device.Gesture) *device.Gesture {
+				return g.SampleInterval(10*ms).MoveTo(image.Pt(40, 0), 40*ms)
+			},
+			want: []device.TouchEvent{
+				{At: 0, Action: device.TouchActionDown, Finger: 1, Point: image.Pt(0, 0)},
+				{At: 10 * ms, Action: device.TouchActionMove, Finger: 1, Point: image.Pt(10, 0)},
+				{At: 20 * ms, Action: device.TouchActionMove, Finger: 1, Point: image.Pt(20, 0)},
+				{At: 30 * ms, Action: device.TouchActionMove, Finger: 1, Point: image.Pt(30, 0)},
+				{At: 40 * ms, Action: device.TouchActionMove, Finger: 1, Point: image.Pt(40, 0)},
+				{At: 40 * ms, Action: device.TouchActionUp, Finger: 1, Point: image.Pt(40, 0)},
+			},
+		},
+		{
+			// 采样数向上取整，时间均匀分布
+			name: "partial interval",
+			gesture: func(g *device.Gesture) *device.Gesture {
+				return g.SampleInterval(16*ms).MoveTo(image.Pt(0, 30), 30*ms)
+			},
+			want: []device.TouchEvent{
+				{At: 0, Action: device.TouchActionDown, Finger: 1, Point: image.Pt(0, 0)},
+				{At: 15 * ms, Action: device.TouchActionMove, Finger: 1, Point: image.Pt(0, 15)},
+				{At: 30 * ms, Action: device.TouchActionMove, Finger: 1, Point: image.Pt(0, 30)},
+				{At: 30 * ms, Action: device.TouchActionUp, Finger: 1, Point: image.Pt(0, 30)},
+			},
+		},
+		{
+			// 缓动只改变位置，不改变采样时间
+			name: "ease",
+			gesture: func(g *device.Gesture) *device.Gesture {
+				return g.SampleInterval(10*ms).Ease(func(t float64) float64 { return t * t }).MoveTo(image.Pt(100, 0), 20*ms)
+			},
+			want: []device.TouchEvent{
+				{At: 0, Action: device.TouchActionDown, Finger: 1, Point: image.Pt(0, 0)},
+				{At: 10 * ms, Action: device.TouchActionMove, Finger: 1, Point: image.Pt(25, 0)},
+				{At: 20 * ms, Action: device.TouchActionMove, Finger: 1, Point: image.Pt(100, 0)},
+				{At: 20 * ms, Action: device.TouchActionUp, Finger: 1, Point: image.Pt(100, 0)},
+			},
+		},
+		{
+			// 停留不产生事件，只推迟之后的事件
+			name: "hold",
+			gesture: func(g *device.Gesture) *device.Gesture {
+				return g.Finger(3).SampleInterval(10*ms).Hold(50*ms).MoveTo(image.Pt(0, 10), 10*ms).Hold(30 * ms)
+			},
+			want: []device.TouchEvent{
+				{At: 0, Action: device.TouchActionDown, Finger: 3, Point: image.Pt(0, 0)},
+				{At: 60 * ms, Action: device.TouchActionMove, Finger: 3, Point: image.Pt(0, 10)},
+				{At: 90 * ms, Action: device.TouchActionUp, Finger: 3, Point: image.Pt(0, 10)},
+			},
+		},
+		{
+			// 位置不变的中间采样被跳过，最后一个采样总是发送
+			name: "duplicate points",
+			gesture: func(g *device.Gesture) *device.Gesture {
+				return g.SampleInterval(10*ms).MoveTo(image.Pt(1, 0), 40*ms)
+			},
+			want: []device.TouchEvent{
+				{At: 0, Action: device.TouchActionDown, Finger: 1, Point: image.Pt(0, 0)},
+				{At: 20 * ms, Action: device.TouchActionMove, Finger: 1, Point: image.Pt(1, 0)},
+				{At: 40 * ms, Action: device.TouchActionMove, Finger: 1, Point: image.Pt(1, 0)},
+				{At: 40 * ms, Action: device.TouchActionUp, Finger: 1, Point: image.Pt(1, 0)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dev *device.Device
+			got := tt.gesture(dev.Gesture(image.Pt(0, 0))).Events()
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d events, want %d:\n%+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("event %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGestureCurveEndpoints(t *testing.T) {
+	var dev *device.Device
+	from, to := image.Pt(100, 800), image.Pt(600, 200)
+	for name, g := range map[string]*device.Gesture{
+		"quadratic": dev.Gesture(from).CurveTo(image.Pt(0, 0), to, 200*time.Millisecond),
+		"cubic":     dev.Gesture(from).CubicTo(image.Pt(700, 900), image.Pt(0, 0), to, 200*time.Millisecond),
+	} {
+		events := g.Events()
+		if events[0].Point != from || events[len(events)-1].Point != to || g.End() != to {
+			t.Errorf("%s: %v -> %v, end %v, want %v -> %v", name, events[0].Point, events[len(events)-1].Point, g.End(), from, to)
+		}
+		if n := len(events) - 2; n != 13 {
+			t.Errorf("%s: %d moves, want 13 at the default interval", name, n)
+		}
+	}
+}
+
+func TestPlayTouches(t *testing.T) {
+	dev, fake := simDevice(t)
+	err := dev.Gesture(image.Pt(100, 100)).
+		SampleInterval(5*time.Millisecond).
+		MoveTo(image.Pt(100, 120), 20*time.Millisecond).
+		Hold(10 * time.Millisecond).
+		Perform(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := touches(fake)
+	wantKinds := []sim.EventKind{sim.EventTouchDown, sim.EventTouchMove, sim.EventTouchMove, sim.EventTouchMove, sim.EventTouchMove, sim.EventTouchUp}
+	if len(got) != len(wantKinds) {
+		t.Fatalf("events = %+v", got)
+	}
+	for i, k := range wantKinds {
+		if got[i].Kind != k || got[i].FingerID != 1 {
+			t.Fatalf("event %d = %+v, want %s on finger 1", i, got[i], k)
+		}
+	}
+	if up := got[len(got)-1]; up.X != 100 || up.Y != 120 {
+		t.Errorf("up at (%d,%d), want (100,120)", up.X, up.Y)
+	}
+	// 停留期间手指保持按下
+	if gap := got[5].Time.Sub(got[4].Time); gap < 10*time.Millisecond {
+		t.Errorf("up %v after the last move, want at least the hold", gap)
+	}
+}
+
+func TestPlayTouchesCancelReleases(t *testing.T) {
+	dev, fake := simDevice(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := dev.Gesture(image.Pt(0, 0)).
+		SampleInterval(5*time.Millisecond).
+		MoveTo(image.Pt(0, 1000), 5*time.Second).
+		Perform(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Perform returned %v after cancel", d)
+	}
+
+	got := touches(fake)
+	if len(got) < 3 {
+		t.Fatalf("events = %+v, want down, moves and up", got)
+	}
+	last, up := got[len(got)-2], got[len(got)-1]
+	if up.Kind != sim.EventTouchUp || up.FingerID != 1 {
+		t.Fatalf("last event = %+v, want a touch up", up)
+	}
+	if up.X != last.X || up.Y != last.Y || up.Y >= 1000 {
+		t.Errorf("released at (%d,%d), last position (%d,%d)", up.X, up.Y, last.X, last.Y)
+	}
+}
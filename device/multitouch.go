@@ -0,0 +1,110 @@
+package device
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"time"
+)
+
+// MultiTouch 把多根手指的手势合并到同一条时间线上执行，
+// 各手指的移动事件按时间交错发送，用于缩放、旋转等多指操作。
+//
+//	err := dev.MultiTouch().
+//		Add(dev.Gesture(a).Finger(1).MoveTo(a2, dur)).
+//		Add(dev.Gesture(b).Finger(2).MoveTo(b2, dur)).
+//		Perform(ctx)
+type MultiTouch struct {
+	dev     *Device
+	tracks  []*Gesture
+	offsets []time.Duration
+}
+
+// MultiTouch 创建多指手势
+func (d *Device) MultiTouch() *MultiTouch {
+	return &MultiTouch{dev: d}
+}
+
+// Add 添加一根手指的手势，与其他手势同时开始
+func (m *MultiTouch) Add(g *Gesture) *MultiTouch {
+	return m.AddAt(0, g)
+}
+
+// AddAt 添加一根手指的手势，在时间线的 offset 处开始
+func (m *MultiTouch) AddAt(offset time.Duration, g *Gesture) *MultiTouch {
+	m.tracks = append(m.tracks, g)
+	m.offsets = append(m.offsets, offset)
+	return m
+}
+
+// Events 返回合并后的触摸事件时间线。同一时刻的事件按按下、移动、抬起的顺序排列，
+// 保证所有手指到达终点后才开始抬起；同类事件按添加顺序排列。
+func (m *MultiTouch) Events() []TouchEvent {
+	var events []TouchEvent
+	for i, g := range m.tracks {
+		for _, ev := range g.Events() {
+			ev.At += m.offsets[i]
+			events = append(events, ev)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].At != events[j].At {
+			return events[i].At < events[j].At
+		}
+		return events[i].Action < events[j].Action
+	})
+	return events
+}
+
+// Perform 执行多指手势，各手势的手指编号不能重复；ctx 取消或出错时抬起所有手指
+func (m *MultiTouch) Perform(ctx context.Context) error {
+	seen := make(map[int]bool, len(m.tracks))
+	for _, g := range m.tracks {
+		if seen[g.finger] {
+			return fmt.Errorf("duplicate finger id %d (手指编号重复)", g.finger)
+		}
+		seen[g.finger] = true
+	}
+	return m.dev.PlayTouches(ctx, m.Events())
+}
+
+// Pinch 以 center 为中心做双指缩放，两指沿水平方向从相距 2*fromRadius 移动到相距 2*toRadius，
+// toRadius 大于 fromRadius 时为放大，反之为缩小
+func (d *Device) Pinch(ctx context.Context, center image.Point, fromRadius, toRadius int, dur time.Duration) error {
+	left := image.Pt(-1, 0)
+	right := image.Pt(1, 0)
+	return d.MultiTouch().
+		Add(d.Gesture(center.Add(left.Mul(fromRadius))).Finger(1).MoveTo(center.Add(left.Mul(toRadius)), dur)).
+		Add(d.Gesture(center.Add(right.Mul(fromRadius))).Finger(2).MoveTo(center.Add(right.Mul(toRadius)), dur)).
+		Perform(ctx)
+}
+
+// Rotate 以 center 为中心做双指旋转，两指位于半径为 radius 的圆上相对的位置，
+// 沿圆弧转过 degrees 度（屏幕坐标下正值为顺时针）
+func (d *Device) Rotate(ctx context.Context, center image.Point, radius int, degrees float64, dur time.Duration) error {
+	arc := func(startDeg float64) func(t float64) image.Point {
+		return func(t float64) image.Point {
+			rad := (startDeg + degrees*t) * math.Pi / 180
+			return ptf(
+				float64(center.X)+float64(radius)*math.Cos(rad),
+				float64(center.Y)+float64(radius)*math.Sin(rad),
+			)
+		}
+	}
+	first, second := arc(180), arc(0)
+	return d.MultiTouch().
+		Add(d.Gesture(first(0)).Finger(1).Along(first, dur)).
+		Add(d.Gesture(second(0)).Finger(2).Along(second, dur)).
+		Perform(ctx)
+}
+
+// TwoFingerSwipe 双指平行滑动，两指在水平方向相距 spacing，以两指中点从 from 移动到 to
+func (d *Device) TwoFingerSwipe(ctx context.Context, from, to image.Point, spacing int, dur time.Duration) error {
+	half := image.Pt(spacing/2, 0)
+	return d.MultiTouch().
+		Add(d.Gesture(from.Sub(half)).Finger(1).MoveTo(to.Sub(half), dur)).
+		Add(d.Gesture(from.Add(half)).Finger(2).MoveTo(to.Add(half), dur)).
+		Perform(ctx)
+}
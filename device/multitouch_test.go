@@ -0,0 +1,181 @@
+package device_test
+
+import (
+	"context"
+	"image"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"mytrpc/device"
+	"mytrpc/sim"
+)
+
+func TestMultiTouchEvents(t *testing.T) {
+	ms := time.Millisecond
+	down, move, up := device.TouchActionDown, device.TouchActionMove, device.TouchActionUp
+	var dev *device.Device
+	horizontal := dev.Gesture(image.Pt(0, 0)).Finger(1).SampleInterval(10*ms).MoveTo(image.Pt(20, 0), 20*ms)
+	vertical := func() *device.Gesture {
+		return dev.Gesture(image.Pt(0, 100)).Finger(2).SampleInterval(10*ms).MoveTo(image.Pt(0, 120), 20*ms)
+	}
+	tests := []struct {
+		name string
+		mt   *device.MultiTouch
+		want []device.TouchEvent
+	}{
+		{
+			// 同一时刻先按下、再移动，所有手指都到达终点后才抬起
+			name: "together",
+			mt:   dev.MultiTouch().Add(horizontal).Add(vertical()),
+			want: []device.TouchEvent{
+				{At: 0, Action: down, Finger: 1, Point: image.Pt(0, 0)},
+				{At: 0, Action: down, Finger: 2, Point: image.Pt(0, 100)},
+				{At: 10 * ms, Action: move, Finger: 1, Point: image.Pt(10, 0)},
+				{At: 10 * ms, Action: move, Finger: 2, Point: image.Pt(0, 110)},
+				{At: 20 * ms, Action: move, Finger: 1, Point: image.Pt(20, 0)},
+				{At: 20 * ms, Action: move, Finger: 2, Point: image.Pt(0, 120)},
+				{At: 20 * ms, Action: up, Finger: 1, Point: image.Pt(20, 0)},
+				{At: 20 * ms, Action: up, Finger: 2, Point: image.Pt(0, 120)},
+			},
+		},
+		{
+			name: "offset",
+			mt:   dev.MultiTouch().Add(horizontal).AddAt(15*ms, vertical()),
+			want: []device.TouchEvent{
+				{At: 0, Action: down, Finger: 1, Point: image.Pt(0, 0)},
+				{At: 10 * ms, Action: move, Finger: 1, Point: image.Pt(10, 0)},
+				{At: 15 * ms, Action: down, Finger: 2, Point: image.Pt(0, 100)},
+				{At: 20 * ms, Action: move, Finger: 1, Point: image.Pt(20, 0)},
+				{At: 20 * ms, Action: up, Finger: 1, Point: image.Pt(20, 0)},
+				{At: 25 * ms, Action: move, Finger: 2, Point: image.Pt(0, 110)},
+				{At: 35 * ms, Action: move, Finger: 2, Point: image.Pt(0, 120)},
+				{At: 35 * ms, Action: up, Finger: 2, Point: image.Pt(0, 120)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.mt.Events()
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d events, want %d:\n%+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("event %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMultiTouchDuplicateFinger(t *testing.T) {
+	dev, fake := simDevice(t)
+	err := dev.MultiTouch().
+		Add(dev.Gesture(image.Pt(100, 100)).MoveTo(image.Pt(200, 100), 10*time.Millisecond)).
+		Add(dev.Gesture(image.Pt(100, 300)).MoveTo(image.Pt(200, 300), 10*time.Millisecond)).
+		Perform(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "duplicate finger id 1") {
+		t.Fatalf("err = %v, want duplicate finger id 1", err)
+	}
+	if got := touches(fake); len(got) != 0 {
+		t.Fatalf("events = %+v, want none", got)
+	}
+}
+
+// byFinger 按手指编号分组触摸事件，并检查按下在前、抬起在后
+func byFinger(t *testing.T, events []sim.Event) map[int][]sim.Event {
+	t.Helper()
+	n := len(events)
+	if n < 4 || events[0].Kind != sim.EventTouchDown || events[1].Kind != sim.EventTouchDown ||
+		events[n-2].Kind != sim.EventTouchUp || events[n-1].Kind != sim.EventTouchUp {
+		t.Fatalf("events = %+v, want both downs first and both ups last", events)
+	}
+	fingers := make(map[int][]sim.Event)
+	for _, e := range events {
+		fingers[e.FingerID] = append(fingers[e.FingerID], e)
+	}
+	if len(fingers[1]) == 0 || len(fingers[2]) == 0 || len(fingers) != 2 {
+		t.Fatalf("fingers = %v, want 1 and 2", fingers)
+	}
+	return fingers
+}
+
+func TestPinchGeometry(t *testing.T) {
+	center := image.Pt(360, 640)
+	tests := []struct {
+		name     string
+		from, to int
+	}{
+		{name: "zoom in", from: 50, to: 250},
+		{name: "zoom out", from: 250, to: 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev, fake := simDevice(t)
+			if err := dev.Pinch(context.Background(), center, tt.from, tt.to, 50*time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+			fingers := byFinger(t, touches(fake))
+			// 两指在过中心的水平线上，分别位于中心两侧
+			for id, side := range map[int]int{1: -1, 2: 1} {
+				events := fingers[id]
+				for _, e := range events {
+					if e.Y != center.Y || (e.X-center.X)*side < 0 {
+						t.Fatalf("finger %d at (%d,%d), want on the %+d side of %v", id, e.X, e.Y, side, center)
+					}
+				}
+				first, last := events[0], events[len(events)-1]
+				if first.X != center.X+side*tt.from || last.X != center.X+side*tt.to {
+					t.Errorf("finger %d moves %d -> %d, want %d -> %d", id, first.X, last.X, center.X+side*tt.from, center.X+side*tt.to)
+				}
+			}
+		})
+	}
+}
+
+func TestRotateGeometry(t *testing.T) {
+	dev, fake := simDevice(t)
+	center, radius := image.Pt(360, 640), 200
+	if err := dev.Rotate(context.Background(), center, radius, 90, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	fingers := byFinger(t, touches(fake))
+	for id, events := range fingers {
+		for _, e := range events {
+			if d := math.Hypot(float64(e.X-center.X), float64(e.Y-center.Y)); math.Abs(d-float64(radius)) > 1 {
+				t.Fatalf("finger %d at (%d,%d), %.1f from center, want %d", id, e.X, e.Y, d, radius)
+			}
+		}
+	}
+	// 从水平直径两端出发，顺时针转过90度后位于竖直直径两端
+	ends := map[int][2]image.Point{
+		1: {image.Pt(160, 640), image.Pt(360, 440)},
+		2: {image.Pt(560, 640), image.Pt(360, 840)},
+	}
+	for id, want := range ends {
+		events := fingers[id]
+		first, last := events[0], events[len(events)-1]
+		if image.Pt(first.X, first.Y) != want[0] || image.Pt(last.X, last.Y) != want[1] {
+			t.Errorf("finger %d moves (%d,%d) -> (%d,%d), want %v -> %v", id, first.X, first.Y, last.X, last.Y, want[0], want[1])
+		}
+	}
+	// 同一时刻两指关于中心对称
+	one, two := fingers[1], fingers[2]
+	if len(one) != len(two) {
+		t.Fatalf("finger 1 has %d events, finger 2 has %d", len(one), len(two))
+	}
+	for i := range one {
+		if dx, dy := one[i].X+two[i].X-2*center.X, one[i].Y+two[i].Y-2*center.Y; abs(dx) > 1 || abs(dy) > 1 {
+			t.Fatalf("step %d: (%d,%d) and (%d,%d) not opposite", i, one[i].X, one[i].Y, two[i].X, two[i].Y)
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,161 @@
+package device
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultJPEGQuality 为 JPEG 截图未指定质量时使用的质量
+const DefaultJPEGQuality = 90
+
+// ImageFormat 为截图的图片格式
+type ImageFormat int
+
+const (
+	FormatPNG ImageFormat = iota
+	FormatJPEG
+)
+
+func (f ImageFormat) String() string {
+	switch f {
+	case FormatPNG:
+		return "png"
+	case FormatJPEG:
+		return "jpeg"
+	}
+	return fmt.Sprintf("ImageFormat(%d)", int(f))
+}
+
+// native 返回原生库的图片类型参数：0 为PNG，1 为JPG
+func (f ImageFormat) native() int {
+	if f == FormatJPEG {
+		return 1
+	}
+	return 0
+}
+
+// quality 返回传给原生库的质量，JPEG 未指定时使用 DefaultJPEGQuality
+func (o ScreenshotOptions) quality() int {
+	if o.Format == FormatJPEG && o.Quality <= 0 {
+		return DefaultJPEGQuality
+	}
+	return o.Quality
+}
+
+// formatForFile 按文件扩展名选择格式，无法判断时使用 fallback
+func formatForFile(path string, fallback ImageFormat) ImageFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		return FormatJPEG
+	case ".png":
+		return FormatPNG
+	}
+	return fallback
+}
+
+// takeRegion 截取 opts.Region 区域。库支持 takeCaptrueCompressEx 时由设备端裁剪，
+// 否则截取整个屏幕后在本地裁剪并重新编码
+func (d *Device) takeRegion(ctx context.Context, opts ScreenshotOptions) ([]byte, error) {
+	caps, err := d.client.Capabilities()
+	if err != nil {
+		return nil, err
+	}
+	r := opts.Region.Canon()
+	if caps.CaptureCompressEx {
+		return d.client.Backend().TakeCaptrueCompressEx(
+			d.client.GetHandle(),
+			r.Min.X, r.Min.Y, r.Max.X, r.Max.Y,
+			opts.Format.native(), opts.quality(),
+		)
+	}
+
+	data, err := d.client.Backend().TakeCaptrueCompress(d.client.GetHandle(), FormatPNG.native(), 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	full, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode screenshot failed (解码截图失败): %w", err)
+	}
+	r = r.Add(full.Bounds().Min).Intersect(full.Bounds())
+	if r.Empty() {
+		return nil, fmt.Errorf("region %v outside screen %v (截图区域超出屏幕)", opts.Region, full.Bounds())
+	}
+	cropped := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(cropped, cropped.Rect, full, r.Min, draw.Src)
+	return encode(cropped, opts.Format, opts.quality())
+}
+
+// encode 在本地编码图片
+func encode(img image.Image, format ImageFormat, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if format == FormatJPEG {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encode screenshot failed (编码截图失败): %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Screenshot 按 opts 截图并解码为 image.Image，支持区域截图和 PNG/JPEG 格式
+func (d *Device) Screenshot(ctx context.Context, opts ScreenshotOptions) (image.Image, error) {
+	data, err := d.TakeScreenshotCtx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode screenshot failed (解码截图失败): %w", err)
+	}
+	return img, nil
+}
+
+// CaptureRGBA 通过 takeCaptrue 获取未压缩的像素，省去编码和解码，适合频繁的像素分析。
+// region 为空时截取整个屏幕，否则通过 takeCaptrueEx 截取该区域，返回图片的 Bounds 与 region 的屏幕坐标一致。
+func (d *Device) CaptureRGBA(ctx context.Context, region image.Rectangle) (*image.RGBA, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var (
+		pix                   []byte
+		width, height, stride int
+		err                   error
+	)
+	region = region.Canon()
+	if region.Empty() {
+		pix, width, height, stride, err = d.client.Backend().TakeCaptrue(d.client.GetHandle())
+	} else {
+		pix, width, height, stride, err = d.client.Backend().TakeCaptrueEx(
+			d.client.GetHandle(),
+			region.Min.X, region.Min.Y, region.Max.X, region.Max.Y,
+		)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("capture failed (截图失败): %w", err)
+	}
+	if width <= 0 || height <= 0 || stride < width*4 || len(pix) < stride*(height-1)+width*4 {
+		return nil, fmt.Errorf("capture failed (截图失败): invalid image %dx%d stride %d with %d bytes", width, height, stride, len(pix))
+	}
+
+	origin := region.Min
+	return &image.RGBA{
+		Pix:    pix,
+		Stride: stride,
+		Rect:   image.Rect(origin.X, origin.Y, origin.X+width, origin.Y+height),
+	}, nil
+}
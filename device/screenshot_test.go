@@ -0,0 +1,118 @@
+package device_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"mytrpc/device"
+	"mytrpc/rpc"
+	"mytrpc/sim"
+)
+
+// noRegionDevice 模拟缺少 takeCaptrueCompressEx 的旧版原生库
+type noRegionDevice struct {
+	*sim.Device
+}
+
+func (d noRegionDevice) MissingSymbols() []string {
+	return append(d.Device.MissingSymbols(), "takeCaptrueCompressEx")
+}
+
+// gradient 返回每个像素颜色都不同的截图，裁剪位置错误时内容必然不同
+func gradient(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: uint8(x>>8 | y>>8<<4), A: 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func connectClient(t *testing.T, backend rpc.Backend) *rpc.Client {
+	t.Helper()
+	client := rpc.NewClientWithBackend(backend)
+	if err := client.Connect("sim", 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestRegionScreenshotFallback(t *testing.T) {
+	fake := sim.New()
+	if err := fake.AddScreenFile("home", "../nodes_ex.xml"); err != nil {
+		t.Fatal(err)
+	}
+	fake.SetScreenshot(gradient(t, 720, 1280))
+	native := device.NewDevice(connectClient(t, fake))
+	client := connectClient(t, noRegionDevice{fake})
+	fallback := device.NewDevice(client)
+
+	ctx := context.Background()
+	if caps, err := client.Capabilities(); err != nil || caps.CaptureCompressEx {
+		t.Fatalf("fallback device should lack takeCaptrueCompressEx: %+v, %v", caps, err)
+	}
+
+	regions := []image.Rectangle{
+		image.Rect(100, 200, 300, 260),
+		image.Rect(0, 0, 1, 1),
+		image.Rect(620, 1180, 720, 1280),
+		// 反向的区域按 Canon 处理
+		image.Rect(300, 260, 100, 200),
+		// 超出屏幕的部分被裁掉
+		image.Rect(700, 1270, 800, 1400),
+	}
+	for _, region := range regions {
+		opts := device.ScreenshotOptions{Format: device.FormatPNG, Region: region}
+		want, err := native.Screenshot(ctx, opts)
+		if err != nil {
+			t.Fatalf("%v native: %v", region, err)
+		}
+		got, err := fallback.Screenshot(ctx, opts)
+		if err != nil {
+			t.Fatalf("%v fallback: %v", region, err)
+		}
+		if got.Bounds() != want.Bounds() {
+			t.Errorf("%v: fallback bounds %v, native %v", region, got.Bounds(), want.Bounds())
+			continue
+		}
+		if size := region.Canon().Intersect(image.Rect(0, 0, 720, 1280)).Size(); want.Bounds().Size() != size {
+			t.Errorf("%v: native size %v, want %v", region, want.Bounds().Size(), size)
+		}
+		for y := want.Bounds().Min.Y; y < want.Bounds().Max.Y; y++ {
+			for x := want.Bounds().Min.X; x < want.Bounds().Max.X; x++ {
+				if g, w := color.RGBAModel.Convert(got.At(x, y)), color.RGBAModel.Convert(want.At(x, y)); g != w {
+					t.Fatalf("%v: pixel (%d,%d) = %v, native %v", region, x, y, g, w)
+				}
+			}
+		}
+	}
+
+	// JPEG 有损，只比较尺寸
+	opts := device.ScreenshotOptions{Format: device.FormatJPEG, Quality: 80, Region: regions[0]}
+	want, err := native.Screenshot(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := fallback.Screenshot(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Bounds() != want.Bounds() {
+		t.Errorf("jpeg: fallback bounds %v, native %v", got.Bounds(), want.Bounds())
+	}
+
+	if _, err := fallback.Screenshot(ctx, device.ScreenshotOptions{Region: image.Rect(800, 0, 900, 10)}); err == nil {
+		t.Error("fallback: region outside the screen should fail")
+	}
+}
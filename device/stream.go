@@ -0,0 +1,318 @@
+package device
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"mytrpc/h264"
+	"mytrpc/rpc"
+)
+
+// Codec 为视频流的编码格式
+type Codec string
+
+// CodecH264 为原生库推送的 H.264 Annex-B 码流
+const CodecH264 Codec = "h264"
+
+// FrameType 为视频帧类型
+type FrameType int
+
+const (
+	FrameUnknown FrameType = iota
+	// FrameConfig 只包含 SPS/PPS 等参数集
+	FrameConfig
+	// FrameKey 包含 IDR 帧，可以从此处开始解码
+	FrameKey
+	// FrameDelta 依赖之前的帧
+	FrameDelta
+)
+
+func (t FrameType) String() string {
+	switch t {
+	case FrameConfig:
+		return "config"
+	case FrameKey:
+		return "key"
+	case FrameDelta:
+		return "delta"
+	}
+	return "unknown"
+}
+
+// Frame 是视频流中的一段数据，通常对应一帧
+type Frame struct {
+	// Seq 为原生库推送的序号，从0开始，被丢弃的帧也占用序号
+	Seq uint64
+	// Time 为收到数据的时间，PTS 为相对推流开始的时间
+	Time time.Time
+	PTS  time.Duration
+	Type FrameType
+	// Config 表示数据中包含 SPS/PPS，关键帧前通常会附带
+	Config bool
+	Codec  Codec
+	// Rotation 为推流时的屏幕方向
+	Rotation Rotation
+	// Width、Height 为请求的推流尺寸
+	Width, Height int
+	// Data 为 Annex-B 格式的码流，包含起始码
+	Data []byte
+	// Dropped 为截至该帧累计丢弃的帧数
+	Dropped uint64
+}
+
+// DropPolicy 决定缓冲区已满时如何处理新到达的帧
+type DropPolicy int
+
+const (
+	// DropOldest 丢弃缓冲区中最旧的帧，保证画面实时
+	DropOldest DropPolicy = iota
+	// DropNewest 丢弃新到达的帧，保留已缓冲的帧
+	DropNewest
+	// DropUntilKeyframe 丢弃新到达的帧直到下一个关键帧，关键帧到达时清空缓冲区，
+	// 保证交给解码器的帧不会缺少参考帧
+	DropUntilKeyframe
+)
+
+// VideoStreamOptions 为视频流配置
+type VideoStreamOptions struct {
+	// Width、Height 为推流尺寸，为0时使用屏幕的物理尺寸
+	Width, Height int
+	// Bitrate 为码率（bps），为0时使用 DefaultVideoBitrate
+	Bitrate int
+	// Buffer 为通道缓冲的帧数，为0时使用 DefaultVideoBuffer
+	Buffer int
+	// Drop 为缓冲区已满时的丢帧策略，默认 DropOldest
+	Drop DropPolicy
+}
+
+const (
+	DefaultVideoBitrate = 4_000_000
+	DefaultVideoBuffer  = 30
+)
+
+// Stream 为一次推流，实现 io.Closer。
+// ctx 取消、调用 Close 或客户端关闭时停止推流并关闭 Frames 和 Audio 通道。
+type Stream struct {
+	// Frames 为视频帧通道，StartAudioStream 返回的 Stream 中为 nil
+	Frames <-chan Frame
+	// Audio 为音频通道，StartVideoStream 返回的 Stream 中为 nil
+	Audio <-chan AudioChunk
+
+	once sync.Once
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Close 停止推流，等待通道关闭后返回，可以重复调用
+func (s *Stream) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+	return nil
+}
+
+// Done 返回在推流停止、通道关闭后关闭的通道
+func (s *Stream) Done() <-chan struct{} {
+	return s.done
+}
+
+// StartVideoStream 开始推流，通过 startVideoStream 的回调接收 H.264 码流并发送到 Stream.Frames。
+// 接收方处理不及时时按 opts.Drop 丢帧，不会阻塞原生库。
+// 原生库的回调是全局的，同一进程内同时只能有一路视频流，其他设备正在推流时返回 rpc.ErrStreamBusy。
+func (d *Device) StartVideoStream(ctx context.Context, opts VideoStreamOptions) (*Stream, error) {
+	s, v, _, err := d.startStream(ctx, opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.Frames = v.frames
+	return s, nil
+}
+
+// StartMediaStream 同时接收视频和音频，两个通道共用同一次推流，PTS 的起点相同；audio.Format 须指定，见 AudioStreamOptions
+func (d *Device) StartMediaStream(ctx context.Context, video VideoStreamOptions, audio AudioStreamOptions) (*Stream, error) {
+	s, v, a, err := d.startStream(ctx, video, &audio)
+	if err != nil {
+		return nil, err
+	}
+	s.Frames, s.Audio = v.frames, a.chunks
+	return s, nil
+}
+
+// startStream 调用 startVideoStream，在 ctx 取消、Stream.Close 或客户端关闭时停止，
+// audio 为 nil 时不接收音频
+func (d *Device) startStream(ctx context.Context, opts VideoStreamOptions, audio *AudioStreamOptions) (*Stream, *videoStream, *audioStream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+	if audio != nil {
+		if err := audio.Format.validate(); err != nil {
+			return nil, nil, nil, fmt.Errorf("start audio stream failed (开始推流失败): %w", err)
+		}
+	}
+	if opts.Width <= 0 || opts.Height <= 0 {
+		info, err := d.display.get(ctx, d, DefaultDisplayRefresh)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("start video stream failed (开始推流失败): %w", err)
+		}
+		opts.Width, opts.Height = info.Width, info.Height
+	}
+	if opts.Bitrate <= 0 {
+		opts.Bitrate = DefaultVideoBitrate
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultVideoBuffer
+	}
+
+	start := time.Now()
+	v := &videoStream{
+		opts:   opts,
+		frames: make(chan Frame, opts.Buffer),
+		start:  start,
+	}
+	var a *audioStream
+	var onAudio rpc.AudioCallback
+	if audio != nil {
+		a = newAudioStream(*audio, start)
+		onAudio = a.onAudio
+	}
+	backend, handle := d.client.Backend(), d.client.GetHandle()
+	if err := backend.StartVideoStream(handle, opts.Width, opts.Height, opts.Bitrate, v.onVideo, onAudio); err != nil {
+		return nil, nil, nil, fmt.Errorf("start video stream failed (开始推流失败): %w", err)
+	}
+
+	s := &Stream{stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		select {
+		case <-ctx.Done():
+		case <-s.stop:
+		case <-d.client.Done():
+			// 客户端关闭时已清除回调并关闭设备，这里只需关闭通道
+		}
+		backend.StopVideoStream(handle)
+		v.close()
+		if a != nil {
+			a.close()
+		}
+	}()
+	return s, v, a, nil
+}
+
+// videoStream 把原生回调转换为带缓冲的通道
+type videoStream struct {
+	opts  VideoStreamOptions
+	start time.Time
+
+	mu      sync.Mutex
+	frames  chan Frame
+	closed  bool
+	seq     uint64
+	dropped uint64
+	waitKey bool
+}
+
+func (s *videoStream) onVideo(rotation int, data []byte) {
+	now := time.Now()
+	typ, config := classifyH264(data)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	f := Frame{
+		Seq:      s.seq,
+		Time:     now,
+		PTS:      now.Sub(s.start),
+		Type:     typ,
+		Config:   config,
+		Codec:    CodecH264,
+		Rotation: Rotation(rotation).normalize(),
+		Width:    s.opts.Width,
+		Height:   s.opts.Height,
+		Data:     data,
+	}
+	s.seq++
+	s.push(f)
+}
+
+// push 按丢帧策略发送，调用时持有 mu
+func (s *videoStream) push(f Frame) {
+	switch s.opts.Drop {
+	case DropNewest:
+		if !s.send(f) {
+			s.dropped++
+		}
+
+	case DropUntilKeyframe:
+		if s.waitKey {
+			switch f.Type {
+			case FrameKey:
+				s.waitKey = false
+			case FrameConfig:
+			default:
+				s.dropped++
+				return
+			}
+		}
+		if s.send(f) {
+			return
+		}
+		if f.Type != FrameKey {
+			s.dropped++
+			s.waitKey = true
+			return
+		}
+		// 关键帧之前的帧都不再需要
+		for len(s.frames) > 0 {
+			<-s.frames
+			s.dropped++
+		}
+		s.send(f)
+
+	default:
+		for !s.send(f) {
+			select {
+			case <-s.frames:
+				s.dropped++
+			default:
+			}
+		}
+	}
+}
+
+// send 非阻塞发送，Dropped 为发送时的累计丢帧数
+func (s *videoStream) send(f Frame) bool {
+	f.Dropped = s.dropped
+	select {
+	case s.frames <- f:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *videoStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.frames)
+	}
+}
+
+// classifyH264 根据 Annex-B 码流中的 NAL 类型判断帧类型，并报告是否包含 SPS/PPS
+func classifyH264(data []byte) (FrameType, bool) {
+	au := h264.ParseAccessUnit(data)
+	config := au.SPS != nil || au.PPS != nil
+	switch {
+	case au.Key:
+		return FrameKey, config
+	case au.HasPicture():
+		return FrameDelta, config
+	case config:
+		return FrameConfig, config
+	}
+	return FrameUnknown, false
+}
@@ -0,0 +1,124 @@
+package device_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"mytrpc/device"
+	"mytrpc/sim"
+)
+
+// idr 为只包含一个 IDR NAL 的最小 Annex-B 数据
+var idr = []byte{0, 0, 0, 1, 0x65, 0x88}
+
+// waitClosed 等待 Frames 通道关闭，超时则失败
+func waitClosed(t *testing.T, frames <-chan device.Frame) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-frames:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("frames channel not closed")
+		}
+	}
+}
+
+func TestStreamStop(t *testing.T) {
+	tests := []struct {
+		name string
+		stop func(cancel context.CancelFunc, stream *device.Stream, closeClient func())
+	}{
+		{name: "close", stop: func(_ context.CancelFunc, stream *device.Stream, _ func()) { stream.Close() }},
+		{name: "cancel", stop: func(cancel context.CancelFunc, _ *device.Stream, _ func()) { cancel() }},
+		{name: "client close", stop: func(_ context.CancelFunc, _ *device.Stream, closeClient func()) { closeClient() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := sim.New()
+			if err := fake.AddScreenFile("home", "../nodes_ex.xml"); err != nil {
+				t.Fatal(err)
+			}
+			client := connectClient(t, fake)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			stream, err := device.NewDevice(client).StartVideoStream(ctx, device.VideoStreamOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stream.Audio != nil {
+				t.Error("video stream should have no audio channel")
+			}
+			if w, h, _, ok := fake.Streaming(); !ok || w != 720 || h != 1280 {
+				t.Fatalf("Streaming() = %dx%d %v, want 720x1280 true", w, h, ok)
+			}
+			if !fake.PushVideo(0, idr) {
+				t.Fatal("PushVideo: no callback registered")
+			}
+			if f := <-stream.Frames; f.Type != device.FrameKey || f.Width != 720 {
+				t.Fatalf("frame = %+v, want a 720 wide key frame", f)
+			}
+
+			tt.stop(cancel, stream, func() { client.Close() })
+			waitClosed(t, stream.Frames)
+			select {
+			case <-stream.Done():
+			case <-time.After(2 * time.Second):
+				t.Fatal("Done not closed")
+			}
+			if _, _, _, ok := fake.Streaming(); ok {
+				t.Error("stream still registered after stop")
+			}
+			if fake.PushVideo(0, idr) {
+				t.Error("PushVideo delivered after stop")
+			}
+			// Close 在任何停止方式之后都可以重复调用
+			if err := stream.Close(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestStreamFrameTypes(t *testing.T) {
+	fake := sim.New()
+	if err := fake.AddScreenFile("home", "../nodes_ex.xml"); err != nil {
+		t.Fatal(err)
+	}
+	client := connectClient(t, fake)
+	stream, err := device.NewDevice(client).StartVideoStream(context.Background(), device.VideoStreamOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	sps, pps := []byte{0, 0, 0, 1, 0x67, 0x42, 0xc0, 0x1f}, []byte{0, 0, 0, 1, 0x68, 0xce}
+	tests := []struct {
+		name   string
+		data   []byte
+		typ    device.FrameType
+		config bool
+	}{
+		{name: "key with parameter sets", data: append(append(append([]byte{}, sps...), pps...), idr...), typ: device.FrameKey, config: true},
+		{name: "parameter sets only", data: append(append([]byte{}, sps...), pps...), typ: device.FrameConfig, config: true},
+		{name: "key", data: idr, typ: device.FrameKey},
+		{name: "delta", data: []byte{0, 0, 0, 1, 0x41, 0x9a}, typ: device.FrameDelta},
+		// 3 字节起始码，访问单元分隔符之后是片
+		{name: "delta after aud", data: []byte{0, 0, 1, 0x09, 0xf0, 0, 0, 1, 0x41, 0x9a}, typ: device.FrameDelta},
+		{name: "sei only", data: []byte{0, 0, 0, 1, 0x06, 0x05, 0x01}, typ: device.FrameUnknown},
+		{name: "empty", typ: device.FrameUnknown},
+	}
+	for _, tt := range tests {
+		if !fake.PushVideo(0, tt.data) {
+			t.Fatal("PushVideo: no callback registered")
+		}
+		if f := <-stream.Frames; f.Type != tt.typ || f.Config != tt.config {
+			t.Errorf("%s: type %v config %v, want %v %v", tt.name, f.Type, f.Config, tt.typ, tt.config)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+// Package discovery 扫描主机或网段的端口范围，找出可连接的MYT设备。
+//
+// 每个候选地址先做一次 TCP 探测，端口开放时再通过 rpc.Client 连接并用 checkLive 确认，
+// 因此测试时可以用本地 TCP 监听配合模拟后端代替真实设备。
+// 所有候选地址共用一个后端，原生库在每次扫描中只加载一次。
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mytrpc/device"
+	"mytrpc/rpc"
+)
+
+// maxHosts 为单次扫描允许展开的最大主机数，对应 /16 网段
+const maxHosts = 1 << 16
+
+// Options 为扫描配置
+type Options struct {
+	// Concurrency 为同时探测的地址数，默认64
+	Concurrency int
+	// DialTimeout 为 TCP 探测超时，默认500毫秒
+	DialTimeout time.Duration
+	// ConnectTimeout 为端口开放后连接设备的超时，默认5秒
+	ConnectTimeout time.Duration
+	// Backend 为所有候选地址共用的后端，未设置时在扫描开始时加载一次原生库，扫描结束后释放；
+	// 测试时可以使用模拟后端
+	Backend rpc.Backend
+	// NewClient 创建连接候选地址的客户端，设置后忽略 Backend
+	NewClient func(host string, port int) *rpc.Client
+	// OnFound 在发现设备时调用，可用于实时输出；可能被多个 goroutine 同时调用
+	OnFound func(Endpoint)
+}
+
+// Endpoint 是扫描发现的设备
+type Endpoint struct {
+	Host       string
+	Port       int
+	SDKVersion string
+	Version    rpc.Version
+	// Rotation、Width、Height 和 Density 来自 device.DisplayInfo，获取失败时为0
+	Rotation device.Rotation
+	Width    int
+	Height   int
+	Density  int
+	// Latency 为从开始连接到确认就绪的耗时
+	Latency time.Duration
+}
+
+// Address 返回 host:port
+func (e Endpoint) Address() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+// Scan 并发扫描 target 的 ports，target 可以是主机名、IP 或 CIDR（如 192.168.1.0/24）。
+// 返回按地址和端口排序的在线设备；ctx 取消时返回已发现的设备和 ctx 的错误。
+func Scan(ctx context.Context, target string, ports []int, opts Options) ([]Endpoint, error) {
+	hosts, err := ExpandHosts(target)
+	if err != nil {
+		return nil, err
+	}
+	if len(ports) == 0 {
+		return nil, errors.New("discovery: no ports to scan (未指定端口)")
+	}
+	opts = withDefaults(opts)
+	if opts.NewClient == nil {
+		backend := opts.Backend
+		if backend == nil {
+			native, err := rpc.LoadBackend(rpc.WithLogger(quietLogger))
+			if err != nil {
+				return nil, fmt.Errorf("discovery: %w", err)
+			}
+			if closer, ok := native.(io.Closer); ok {
+				defer closer.Close()
+			}
+			backend = native
+		}
+		timeout := opts.ConnectTimeout
+		opts.NewClient = func(host string, port int) *rpc.Client {
+			return rpc.NewClientWithBackend(backend, rpc.WithConnectTimeout(timeout), rpc.WithLogger(quietLogger))
+		}
+	}
+
+	type candidate struct {
+		host string
+		port int
+	}
+	candidates := make(chan candidate)
+	go func() {
+		defer close(candidates)
+		for _, host := range hosts {
+			for _, port := range ports {
+				select {
+				case candidates <- candidate{host, port}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	var found []Endpoint
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range candidates {
+				ep, ok := probe(ctx, c.host, c.port, opts)
+				if !ok {
+					continue
+				}
+				if opts.OnFound != nil {
+					opts.OnFound(ep)
+				}
+				mu.Lock()
+				found = append(found, ep)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	sortEndpoints(found)
+	return found, ctx.Err()
+}
+
+func withDefaults(opts Options) Options {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 64
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 500 * time.Millisecond
+	}
+	if opts.ConnectTimeout <= 0 {
+		opts.ConnectTimeout = 5 * time.Second
+	}
+	return opts
+}
+
+// quietLogger 丢弃客户端日志，避免每个候选地址都输出连接信息
+var quietLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// probe 探测一个候选地址，端口开放且设备在线时返回设备信息
+func probe(ctx context.Context, host string, port int, opts Options) (Endpoint, bool) {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	dialer := net.Dialer{Timeout: opts.DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return Endpoint{}, false
+	}
+	conn.Close()
+
+	start := time.Now()
+	client := opts.NewClient(host, port)
+	defer client.Close()
+	if err := client.Connect(host, port); err != nil {
+		return Endpoint{}, false
+	}
+	if live, err := client.CheckConnectState(); err != nil || !live {
+		return Endpoint{}, false
+	}
+
+	ep := Endpoint{Host: host, Port: port, Latency: time.Since(start)}
+	ep.SDKVersion, _ = client.GetSDKVersion()
+	if caps, err := client.Capabilities(); err == nil {
+		ep.Version = caps.Version
+	}
+	if info, err := device.NewDevice(client).DisplayInfoCtx(ctx); err == nil {
+		ep.Rotation, ep.Width, ep.Height, ep.Density = info.Rotation, info.Width, info.Height, info.Density
+	}
+	return ep, true
+}
+
+// ExpandHosts 把 target 展开为主机列表：CIDR 展开为其中的地址（IPv4 去掉网络地址和广播地址），
+// 其他值原样返回。网段过大（超过 /16）时返回错误。
+func ExpandHosts(target string) ([]string, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return nil, errors.New("discovery: empty target (未指定扫描目标)")
+	}
+	if !strings.Contains(target, "/") {
+		return []string{target}, nil
+	}
+
+	prefix, err := netip.ParsePrefix(target)
+	if err != nil {
+		return nil, fmt.Errorf("discovery: invalid CIDR %q (无效的网段): %w", target, err)
+	}
+	prefix = prefix.Masked()
+	bits := prefix.Addr().BitLen() - prefix.Bits()
+	if bits > 16 {
+		return nil, fmt.Errorf("discovery: %s has more than %d hosts (网段过大)", target, maxHosts)
+	}
+
+	var hosts []string
+	for addr := prefix.Addr(); addr.IsValid() && prefix.Contains(addr); addr = addr.Next() {
+		hosts = append(hosts, addr.String())
+	}
+	if prefix.Addr().Is4() && bits >= 2 {
+		hosts = hosts[1 : len(hosts)-1]
+	}
+	return hosts, nil
+}
+
+// ParsePorts 解析端口列表，如 "7100-7199,8000"
+func ParsePorts(spec string) ([]int, error) {
+	seen := make(map[int]bool)
+	var ports []int
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		start, err := parsePort(lo)
+		if err != nil {
+			return nil, err
+		}
+		end := start
+		if isRange {
+			if end, err = parsePort(hi); err != nil {
+				return nil, err
+			}
+		}
+		if end < start {
+			return nil, fmt.Errorf("discovery: invalid port range %q (无效的端口范围)", part)
+		}
+		for p := start; p <= end; p++ {
+			if !seen[p] {
+				seen[p] = true
+				ports = append(ports, p)
+			}
+		}
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("discovery: no ports in %q (未指定端口)", spec)
+	}
+	return ports, nil
+}
+
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || p <= 0 || p > 65535 {
+		return 0, fmt.Errorf("discovery: invalid port %q (无效的端口号)", s)
+	}
+	return p, nil
+}
+
+// sortEndpoints 按地址和端口排序，IP 按数值比较
+func sortEndpoints(eps []Endpoint) {
+	key := func(host string) []byte {
+		if addr, err := netip.ParseAddr(host); err == nil {
+			b := addr.As16()
+			return b[:]
+		}
+		return []byte(host)
+	}
+	sort.Slice(eps, func(i, j int) bool {
+		if c := bytes.Compare(key(eps[i].Host), key(eps[j].Host)); c != 0 {
+			return c < 0
+		}
+		return eps[i].Port < eps[j].Port
+	})
+}
@@ -0,0 +1,196 @@
+package discovery_test
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mytrpc/discovery"
+	"mytrpc/rpc"
+	"mytrpc/sim"
+)
+
+// countingDevice 统计 openDevice 的调用次数，确认所有候选地址共用同一个后端
+type countingDevice struct {
+	*sim.Device
+	opens atomic.Int32
+}
+
+func (d *countingDevice) OpenDevice(host string, port int, timeout int) (uintptr, error) {
+	d.opens.Add(1)
+	return d.Device.OpenDevice(host, port, timeout)
+}
+
+// listen 在本地开启 n 个 TCP 监听，返回端口号，测试结束时关闭
+func listen(t *testing.T, n int) []int {
+	t.Helper()
+	var ports []int
+	for i := 0; i < n; i++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ln.Close() })
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				conn.Close()
+			}
+		}()
+		ports = append(ports, ln.Addr().(*net.TCPAddr).Port)
+	}
+	return ports
+}
+
+// closedPort 返回一个当前没有监听的端口
+func closedPort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
+
+func TestScan(t *testing.T) {
+	fake := &countingDevice{Device: sim.New()}
+	if err := fake.AddScreenFile("home", "../nodes_ex.xml"); err != nil {
+		t.Fatal(err)
+	}
+	open := listen(t, 3)
+	ports := append([]int{closedPort(t)}, open...)
+
+	var mu sync.Mutex
+	var reported []int
+	found, err := discovery.Scan(context.Background(), "127.0.0.1", ports, discovery.Options{
+		Backend:     fake,
+		Concurrency: 4,
+		DialTimeout: time.Second,
+		OnFound: func(ep discovery.Endpoint) {
+			mu.Lock()
+			defer mu.Unlock()
+			reported = append(reported, ep.Port)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(found) != len(open) {
+		t.Fatalf("found %d devices, want %d: %+v", len(found), len(open), found)
+	}
+	for i, ep := range found {
+		if ep.Host != "127.0.0.1" || i > 0 && ep.Port <= found[i-1].Port {
+			t.Errorf("endpoint %d = %s, want sorted 127.0.0.1 ports", i, ep.Address())
+		}
+		if ep.Width != 720 || ep.Height != 1280 || ep.Density != 320 {
+			t.Errorf("%s: screen %dx%d %ddpi, want 720x1280 320dpi", ep.Address(), ep.Width, ep.Height, ep.Density)
+		}
+		if ep.SDKVersion == "" {
+			t.Errorf("%s: empty SDK version", ep.Address())
+		}
+	}
+	if len(reported) != len(open) {
+		t.Errorf("OnFound called %d times, want %d", len(reported), len(open))
+	}
+	// 关闭的端口不会进入连接阶段
+	if n := int(fake.opens.Load()); n != len(open) {
+		t.Errorf("openDevice called %d times, want %d", n, len(open))
+	}
+}
+
+func TestScanCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	found, err := discovery.Scan(ctx, "127.0.0.1", listen(t, 1), discovery.Options{Backend: sim.New()})
+	if err != context.Canceled {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if len(found) != 0 {
+		t.Fatalf("found %v after cancel", found)
+	}
+}
+
+func TestScanNewClientOverridesBackend(t *testing.T) {
+	var calls atomic.Int32
+	fake := sim.New()
+	found, err := discovery.Scan(context.Background(), "127.0.0.1", listen(t, 2), discovery.Options{
+		NewClient: func(host string, port int) *rpc.Client {
+			calls.Add(1)
+			return rpc.NewClientWithBackend(fake)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 || calls.Load() != 2 {
+		t.Fatalf("found %d devices with %d clients, want 2 and 2", len(found), calls.Load())
+	}
+}
+
+func TestExpandHosts(t *testing.T) {
+	tests := []struct {
+		target  string
+		want    []string
+		wantErr bool
+	}{
+		{target: "192.168.1.5", want: []string{"192.168.1.5"}},
+		{target: " device.local ", want: []string{"device.local"}},
+		{target: "10.0.0.0/30", want: []string{"10.0.0.1", "10.0.0.2"}},
+		{target: "10.0.0.7/31", want: []string{"10.0.0.6", "10.0.0.7"}},
+		{target: "10.0.0.9/32", want: []string{"10.0.0.9"}},
+		{target: "fd00::/127", want: []string{"fd00::", "fd00::1"}},
+		{target: "", wantErr: true},
+		{target: "10.0.0.0/33", wantErr: true},
+		{target: "10.0.0.0/15", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := discovery.ExpandHosts(tt.target)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ExpandHosts(%q) err = %v, wantErr %v", tt.target, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExpandHosts(%q) = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+	if hosts, err := discovery.ExpandHosts("10.1.0.0/16"); err != nil || len(hosts) != 1<<16-2 {
+		t.Errorf("ExpandHosts(/16) = %d hosts, %v", len(hosts), err)
+	}
+}
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []int
+		wantErr bool
+	}{
+		{spec: "7100", want: []int{7100}},
+		{spec: "7100-7102,8000", want: []int{7100, 7101, 7102, 8000}},
+		{spec: " 8000 , 7100-7101,7100", want: []int{8000, 7100, 7101}},
+		{spec: "", wantErr: true},
+		{spec: "0", wantErr: true},
+		{spec: "65536", wantErr: true},
+		{spec: "7102-7100", wantErr: true},
+		{spec: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := discovery.ParsePorts(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePorts(%q) err = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePorts(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
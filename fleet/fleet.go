@@ -0,0 +1,386 @@
+// Package fleet 管理多台设备的连接，按 host:port 复用客户端，
+// 支持首次使用时连接、空闲回收、批量关闭以及状态快照。
+package fleet
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mytrpc/device"
+	"mytrpc/rpc"
+)
+
+// ID 返回设备的标识，格式为 host_port
+func ID(host string, port int) string {
+	return fmt.Sprintf("%s_%d", host, port)
+}
+
+// options 为 Manager 配置
+type options struct {
+	parent      context.Context
+	clientOpts  []rpc.Option
+	newClient   func(host string, port int, opts ...rpc.Option) *rpc.Client
+	idleTimeout time.Duration
+}
+
+// Option 配置 Manager
+type Option func(*options)
+
+// WithContext 设置所有设备上下文的父上下文，父上下文取消时设备上下文随之取消
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.parent = ctx
+	}
+}
+
+// WithClientOptions 设置创建客户端时使用的选项，如 rpc.WithKeepalive
+func WithClientOptions(opts ...rpc.Option) Option {
+	return func(o *options) {
+		o.clientOpts = append(o.clientOpts, opts...)
+	}
+}
+
+// WithClientFactory 自定义客户端的创建方式，opts 为 Manager 附加的选项，需要传给 rpc.NewClient。
+// 可用于为每台设备注入不同的后端，如模拟设备。
+func WithClientFactory(fn func(host string, port int, opts ...rpc.Option) *rpc.Client) Option {
+	return func(o *options) {
+		o.newClient = fn
+	}
+}
+
+// WithIdleTimeout 设置空闲回收时间，设备超过该时间没有原生调用时被关闭并移除；为0时不回收
+func WithIdleTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.idleTimeout = d
+	}
+}
+
+// Manager 管理一组设备连接，可以被多个 goroutine 同时使用
+type Manager struct {
+	opts options
+
+	mu      sync.Mutex
+	members map[string]*Member
+	closed  bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// New 创建设备管理器
+func New(opts ...Option) *Manager {
+	o := options{
+		parent: context.Background(),
+		newClient: func(host string, port int, opts ...rpc.Option) *rpc.Client {
+			return rpc.NewClient(opts...)
+		},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	m := &Manager{
+		opts:    o,
+		members: make(map[string]*Member),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if o.idleTimeout > 0 {
+		go m.evictLoop()
+	} else {
+		close(m.done)
+	}
+	return m
+}
+
+// Member 是管理器中的一台设备
+type Member struct {
+	Host string
+	Port int
+
+	client *rpc.Client
+	device *device.Device
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// lastUsed 为最近一次使用的时间（UnixNano）
+	lastUsed atomic.Int64
+	// refs 为正在进行的 Get 和原生调用数，不为0时不会被空闲回收
+	refs atomic.Int32
+
+	// ready 在首次连接结束后关闭，connectErr 为连接失败的原因
+	ready      chan struct{}
+	connectErr error
+}
+
+// ID 返回设备标识
+func (mb *Member) ID() string {
+	return ID(mb.Host, mb.Port)
+}
+
+// Client 返回设备的RPC客户端
+func (mb *Member) Client() *rpc.Client {
+	return mb.client
+}
+
+// Device 返回设备操作对象
+func (mb *Member) Device() *device.Device {
+	return mb.device
+}
+
+// Context 返回设备上下文，设备被移除、回收或管理器关闭时取消
+func (mb *Member) Context() context.Context {
+	return mb.ctx
+}
+
+// LastUsed 返回最近一次使用的时间
+func (mb *Member) LastUsed() time.Time {
+	return time.Unix(0, mb.lastUsed.Load())
+}
+
+func (mb *Member) touch() {
+	mb.lastUsed.Store(time.Now().UnixNano())
+}
+
+func (mb *Member) close() error {
+	mb.cancel()
+	return mb.client.Close()
+}
+
+// newMember 创建设备，使用者的每次原生调用都会刷新空闲时间，
+// 保活检查等客户端自己发起的调用不算作使用
+func (m *Manager) newMember(host string, port int) *Member {
+	mb := &Member{Host: host, Port: port, ready: make(chan struct{})}
+	mb.ctx, mb.cancel = context.WithCancel(m.opts.parent)
+	mb.touch()
+
+	opts := append([]rpc.Option(nil), m.opts.clientOpts...)
+	opts = append(opts, rpc.WithInterceptors(func(call *rpc.Call, next func() error) error {
+		if call.Internal {
+			return next()
+		}
+		mb.refs.Add(1)
+		mb.touch()
+		defer func() {
+			mb.touch()
+			mb.refs.Add(-1)
+		}()
+		return next()
+	}))
+	mb.client = m.opts.newClient(host, port, opts...)
+	mb.device = device.NewDevice(mb.client)
+	return mb
+}
+
+// Get 返回设备，首次获取时创建客户端并连接，并发的 Get 等待同一次连接。
+// 连接失败时先移除该设备，再把错误返回给所有等待者。
+func (m *Manager) Get(host string, port int) (*Member, error) {
+	id := ID(host, port)
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, rpc.ErrClosed
+	}
+	mb, ok := m.members[id]
+	if !ok {
+		mb = m.newMember(host, port)
+		m.members[id] = mb
+	}
+	mb.touch()
+	mb.refs.Add(1)
+	m.mu.Unlock()
+	defer mb.refs.Add(-1)
+
+	if !ok {
+		m.connect(id, mb)
+	}
+	<-mb.ready
+	if mb.connectErr != nil {
+		return nil, mb.connectErr
+	}
+	return mb, nil
+}
+
+// connect 首次连接设备，失败时移除并关闭设备
+func (m *Manager) connect(id string, mb *Member) {
+	err := mb.client.Connect(mb.Host, mb.Port)
+	if err == nil {
+		close(mb.ready)
+		return
+	}
+
+	m.mu.Lock()
+	if m.members[id] == mb {
+		delete(m.members, id)
+	}
+	m.mu.Unlock()
+	mb.connectErr = fmt.Errorf("connect device %s failed (连接设备失败): %w", mb.ID(), err)
+	close(mb.ready)
+	mb.close()
+}
+
+// Lookup 返回已存在的设备，不会创建或连接
+func (m *Manager) Lookup(host string, port int) (*Member, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mb, ok := m.members[ID(host, port)]
+	return mb, ok
+}
+
+// Remove 关闭并移除设备，设备不存在时返回 nil
+func (m *Manager) Remove(host string, port int) error {
+	id := ID(host, port)
+	m.mu.Lock()
+	mb, ok := m.members[id]
+	m.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return m.remove(id, mb)
+}
+
+// remove 在 id 仍对应 mb 时移除，然后关闭 mb
+func (m *Manager) remove(id string, mb *Member) error {
+	m.mu.Lock()
+	if m.members[id] == mb {
+		delete(m.members, id)
+	}
+	m.mu.Unlock()
+	return mb.close()
+}
+
+// Len 返回设备数量
+func (m *Manager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.members)
+}
+
+// Range 按标识顺序遍历设备，fn 返回 false 时停止。遍历的是调用时的副本，fn 中可以调用 Manager 的方法。
+func (m *Manager) Range(fn func(*Member) bool) {
+	for _, mb := range m.list() {
+		if !fn(mb) {
+			return
+		}
+	}
+}
+
+// list 返回按标识排序的设备副本
+func (m *Manager) list() []*Member {
+	m.mu.Lock()
+	members := make([]*Member, 0, len(m.members))
+	for _, mb := range m.members {
+		members = append(members, mb)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(members, func(i, j int) bool { return members[i].ID() < members[j].ID() })
+	return members
+}
+
+// Status 是设备的状态快照
+type Status struct {
+	ID       string
+	Host     string
+	Port     int
+	State    rpc.ConnState
+	LastUsed time.Time
+	Idle     time.Duration
+}
+
+// Snapshot 返回所有设备的状态快照，按标识排序
+func (m *Manager) Snapshot() []Status {
+	now := time.Now()
+	members := m.list()
+	out := make([]Status, 0, len(members))
+	for _, mb := range members {
+		last := mb.LastUsed()
+		out = append(out, Status{
+			ID:       mb.ID(),
+			Host:     mb.Host,
+			Port:     mb.Port,
+			State:    mb.client.State(),
+			LastUsed: last,
+			Idle:     now.Sub(last),
+		})
+	}
+	return out
+}
+
+// evictLoop 定期关闭空闲超时的设备
+func (m *Manager) evictLoop() {
+	defer close(m.done)
+
+	interval := min(m.opts.idleTimeout/2, time.Minute)
+	interval = max(interval, 10*time.Millisecond)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.evictIdle()
+		}
+	}
+}
+
+// evictIdle 关闭并移除空闲超时的设备。Get 在 mu 内刷新使用时间并增加引用，
+// 因此在 mu 内重新检查后移除，不会关闭刚被 Get 返回或正在调用的设备
+func (m *Manager) evictIdle() {
+	deadline := time.Now().Add(-m.opts.idleTimeout)
+	var idle []*Member
+	m.mu.Lock()
+	for id, mb := range m.members {
+		if mb.refs.Load() == 0 && mb.LastUsed().Before(deadline) {
+			delete(m.members, id)
+			idle = append(idle, mb)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, mb := range idle {
+		mb.close()
+	}
+}
+
+// Close 关闭所有设备并停止空闲回收，之后的 Get 返回 rpc.ErrClosed。
+// 返回关闭过程中遇到的第一个错误。
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	close(m.stop)
+	members := m.members
+	m.members = make(map[string]*Member)
+	m.mu.Unlock()
+
+	<-m.done
+
+	// 并行关闭，每个客户端都会等待自己正在执行的调用
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var first error
+	for _, mb := range members {
+		wg.Add(1)
+		go func(mb *Member) {
+			defer wg.Done()
+			if err := mb.close(); err != nil {
+				mu.Lock()
+				if first == nil {
+					first = err
+				}
+				mu.Unlock()
+			}
+		}(mb)
+	}
+	wg.Wait()
+	return first
+}
@@ -0,0 +1,132 @@
+package fleet_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mytrpc/fleet"
+	"mytrpc/rpc"
+	"mytrpc/sim"
+)
+
+func simFactory(fake *sim.Device) fleet.Option {
+	return fleet.WithClientFactory(func(host string, port int, opts ...rpc.Option) *rpc.Client {
+		return rpc.NewClientWithBackend(fake, opts...)
+	})
+}
+
+// waitFor 轮询 cond 直到为 true 或超时
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) bool {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return cond()
+}
+
+func TestIdleEvictionWithKeepalive(t *testing.T) {
+	m := fleet.New(
+		simFactory(sim.New()),
+		fleet.WithIdleTimeout(100*time.Millisecond),
+		fleet.WithClientOptions(rpc.WithKeepalive(5*time.Millisecond)),
+	)
+	defer m.Close()
+
+	mb, err := m.Get("sim", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !waitFor(t, 2*time.Second, func() bool { return m.Len() == 0 }) {
+		t.Fatalf("member not evicted, last used %v ago", time.Since(mb.LastUsed()))
+	}
+	if mb.Context().Err() == nil {
+		t.Fatal("evicted member context not cancelled")
+	}
+}
+
+func TestUseKeepsMemberAlive(t *testing.T) {
+	m := fleet.New(simFactory(sim.New()), fleet.WithIdleTimeout(100*time.Millisecond))
+	defer m.Close()
+
+	mb, err := m.Get("sim", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for end := time.Now().Add(300 * time.Millisecond); time.Now().Before(end); {
+		if err := mb.Device().KeyPress(4); err != nil {
+			t.Fatalf("member closed while in use: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, ok := m.Lookup("sim", 1); !ok || got != mb {
+		t.Fatal("member in use was evicted")
+	}
+}
+
+// gatedDevice 在 openDevice 中等待 gate 关闭后再调用模拟设备，用于让并发的 Get 同时等待首次连接
+type gatedDevice struct {
+	*sim.Device
+	gate    chan struct{}
+	entered chan struct{}
+	opens   atomic.Int32
+}
+
+func (d *gatedDevice) OpenDevice(host string, port int, timeout int) (uintptr, error) {
+	if d.opens.Add(1) == 1 {
+		close(d.entered)
+	}
+	<-d.gate
+	return d.Device.OpenDevice(host, port, timeout)
+}
+
+func TestConcurrentGetConnectFailure(t *testing.T) {
+	fake := &gatedDevice{Device: sim.New(), gate: make(chan struct{}), entered: make(chan struct{})}
+	fake.SetReachable(false)
+	m := fleet.New(fleet.WithClientFactory(func(host string, port int, opts ...rpc.Option) *rpc.Client {
+		return rpc.NewClientWithBackend(fake, opts...)
+	}))
+	defer m.Close()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	get := func(i int) {
+		defer wg.Done()
+		_, errs[i] = m.Get("sim", 1)
+	}
+	wg.Add(1)
+	go get(0)
+	<-fake.entered
+	for i := 1; i < len(errs); i++ {
+		wg.Add(1)
+		go get(i)
+	}
+	// 让其余的 Get 进入等待后再让首次连接失败
+	time.Sleep(50 * time.Millisecond)
+	close(fake.gate)
+	wg.Wait()
+
+	for i, err := range errs {
+		// 等待者应收到首次连接失败的原因，而不是被关闭的客户端返回的 ErrClosed
+		if !errors.Is(err, rpc.ErrNotConnected) || errors.Is(err, rpc.ErrClosed) {
+			t.Errorf("Get %d: err = %v, want ErrNotConnected", i, err)
+		}
+	}
+	if n := fake.opens.Load(); n != 1 {
+		t.Errorf("openDevice called %d times, want 1 shared attempt", n)
+	}
+	if n := m.Len(); n != 0 {
+		t.Fatalf("Len() = %d after failed connect, want 0", n)
+	}
+
+	fake.SetReachable(true)
+	if _, err := m.Get("sim", 1); err != nil {
+		t.Fatalf("Get after failure: %v", err)
+	}
+}
@@ -2,4 +2,4 @@ module mytrpc
 
 go 1.23.1
 
-require golang.org/x/exp v0.0.0-20250218142911-aa4b98e5adaa // indirect
+require golang.org/x/exp v0.0.0-20250218142911-aa4b98e5adaa
@@ -0,0 +1,280 @@
+// Package h264 解析 H.264 Annex-B 码流：切分 NAL 单元、识别关键帧和参数集、读取 SPS 中的画面尺寸，
+// 供 device 标注视频帧类型和 recorder 封装文件共用。
+package h264
+
+import (
+	"errors"
+	"fmt"
+)
+
+// NALType 为 H.264 NAL 单元类型
+type NALType uint8
+
+const (
+	NALSlice    NALType = 1 // 非IDR片
+	NALIDR      NALType = 5 // IDR片，关键帧
+	NALSEI      NALType = 6
+	NALSPS      NALType = 7
+	NALPPS      NALType = 8
+	NALAUD      NALType = 9 // 访问单元分隔符
+	NALEndSeq   NALType = 10
+	NALEndStrm  NALType = 11
+	NALFiller   NALType = 12
+	nalTypeMask         = 0x1f
+)
+
+// TypeOf 返回 NAL 单元（不含起始码）的类型
+func TypeOf(nal []byte) NALType {
+	if len(nal) == 0 {
+		return 0
+	}
+	return NALType(nal[0] & nalTypeMask)
+}
+
+// SplitAnnexB 按 3 字节或 4 字节起始码切分 Annex-B 码流，返回不含起始码的 NAL 单元，
+// 切片引用 data 的内存。data 不以起始码开头时，开头部分视为一个 NAL 单元。
+func SplitAnnexB(data []byte) [][]byte {
+	var nals [][]byte
+	start := -1
+	for i := 0; i+2 < len(data); {
+		if data[i] != 0 || data[i+1] != 0 || data[i+2] != 1 {
+			i++
+			continue
+		}
+		// 4 字节起始码的前导0属于起始码
+		end := i
+		if end > 0 && data[end-1] == 0 {
+			end--
+		}
+		if start >= 0 {
+			nals = appendNAL(nals, data[start:end])
+		} else if end > 0 {
+			nals = appendNAL(nals, data[:end])
+		}
+		i += 3
+		start = i
+	}
+	if start >= 0 {
+		nals = appendNAL(nals, data[start:])
+	} else if len(data) > 0 {
+		nals = appendNAL(nals, data)
+	}
+	return nals
+}
+
+// appendNAL 去掉 NAL 单元末尾的填充0后追加
+func appendNAL(nals [][]byte, nal []byte) [][]byte {
+	for len(nal) > 0 && nal[len(nal)-1] == 0 {
+		nal = nal[:len(nal)-1]
+	}
+	if len(nal) == 0 {
+		return nals
+	}
+	return append(nals, nal)
+}
+
+// AccessUnit 是一帧的 NAL 单元及其分类
+type AccessUnit struct {
+	NALUs [][]byte
+	// Key 表示包含 IDR 片
+	Key bool
+	// SPS、PPS 为其中的参数集，没有时为 nil
+	SPS, PPS []byte
+}
+
+// HasPicture 报告是否包含图像数据（片），只有参数集或 SEI 时为 false
+func (au AccessUnit) HasPicture() bool {
+	for _, nal := range au.NALUs {
+		if t := TypeOf(nal); t >= NALSlice && t <= NALIDR {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseAccessUnit 切分 Annex-B 格式的一帧并识别关键帧和参数集
+func ParseAccessUnit(data []byte) AccessUnit {
+	au := AccessUnit{NALUs: SplitAnnexB(data)}
+	for _, nal := range au.NALUs {
+		switch TypeOf(nal) {
+		case NALIDR:
+			au.Key = true
+		case NALSPS:
+			au.SPS = nal
+		case NALPPS:
+			au.PPS = nal
+		}
+	}
+	return au
+}
+
+// SPS 为序列参数集中与封装相关的字段
+type SPS struct {
+	Profile            uint8
+	Compatibility      uint8
+	Level              uint8
+	ChromaFormat       uint
+	BitDepthLumaMinus8 uint
+	BitDepthChromaMin8 uint
+	Width, Height      int
+}
+
+// ParseSPS 解析 SPS（不含起始码），得到画面尺寸和编码档次
+func ParseSPS(nal []byte) (SPS, error) {
+	var s SPS
+	if TypeOf(nal) != NALSPS || len(nal) < 4 {
+		return s, errors.New("h264: not an SPS NAL unit (不是SPS)")
+	}
+	s.Profile, s.Compatibility, s.Level = nal[1], nal[2], nal[3]
+	s.ChromaFormat = 1
+
+	r := &bitReader{data: unescapeRBSP(nal[4:])}
+	r.ue() // seq_parameter_set_id
+	switch s.Profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		s.ChromaFormat = r.ue()
+		if s.ChromaFormat == 3 {
+			r.u(1) // separate_colour_plane_flag
+		}
+		s.BitDepthLumaMinus8 = r.ue()
+		s.BitDepthChromaMin8 = r.ue()
+		r.u(1) // qpprime_y_zero_transform_bypass_flag
+		if r.u(1) == 1 {
+			lists := 8
+			if s.ChromaFormat == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				if r.u(1) == 1 {
+					size := 16
+					if i >= 6 {
+						size = 64
+					}
+					r.skipScalingList(size)
+				}
+			}
+		}
+	}
+	r.ue() // log2_max_frame_num_minus4
+	switch r.ue() {
+	case 0:
+		r.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.u(1) // delta_pic_order_always_zero_flag
+		r.se() // offset_for_non_ref_pic
+		r.se() // offset_for_top_to_bottom_field
+		for n := r.ue(); n > 0 && r.err == nil; n-- {
+			r.se()
+		}
+	}
+	r.ue() // max_num_ref_frames
+	r.u(1) // gaps_in_frame_num_value_allowed_flag
+	widthMBs := int(r.ue()) + 1
+	heightMapUnits := int(r.ue()) + 1
+	frameMBsOnly := int(r.u(1))
+	if frameMBsOnly == 0 {
+		r.u(1) // mb_adaptive_frame_field_flag
+	}
+	r.u(1) // direct_8x8_inference_flag
+
+	var cropLeft, cropRight, cropTop, cropBottom int
+	if r.u(1) == 1 {
+		cropLeft, cropRight = int(r.ue()), int(r.ue())
+		cropTop, cropBottom = int(r.ue()), int(r.ue())
+	}
+	if r.err != nil {
+		return s, fmt.Errorf("h264: truncated SPS (SPS数据不完整): %w", r.err)
+	}
+
+	cropX, cropY := 1, 2-frameMBsOnly
+	switch s.ChromaFormat {
+	case 1:
+		cropX, cropY = 2, 2*(2-frameMBsOnly)
+	case 2:
+		cropX = 2
+	}
+	s.Width = widthMBs*16 - cropX*(cropLeft+cropRight)
+	s.Height = (2-frameMBsOnly)*heightMapUnits*16 - cropY*(cropTop+cropBottom)
+	if s.Width <= 0 || s.Height <= 0 {
+		return s, fmt.Errorf("h264: invalid SPS size %dx%d (SPS尺寸无效)", s.Width, s.Height)
+	}
+	return s, nil
+}
+
+// unescapeRBSP 去掉防竞争字节 0x000003 中的 03
+func unescapeRBSP(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	zeros := 0
+	for _, b := range data {
+		if zeros >= 2 && b == 3 {
+			zeros = 0
+			continue
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+var errShortRBSP = errors.New("unexpected end of data")
+
+// bitReader 按位读取 RBSP，出错后所有读取返回0并保留第一个错误
+type bitReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *bitReader) u(n int) uint {
+	var v uint
+	for i := 0; i < n; i++ {
+		if r.pos >= len(r.data)*8 {
+			r.err = errShortRBSP
+			return 0
+		}
+		bit := r.data[r.pos/8] >> (7 - r.pos%8) & 1
+		v = v<<1 | uint(bit)
+		r.pos++
+	}
+	return v
+}
+
+// ue 读取无符号指数哥伦布编码
+func (r *bitReader) ue() uint {
+	zeros := 0
+	for r.u(1) == 0 {
+		if r.err != nil || zeros > 31 {
+			if r.err == nil {
+				r.err = errors.New("invalid exp-Golomb code")
+			}
+			return 0
+		}
+		zeros++
+	}
+	return 1<<zeros - 1 + r.u(zeros)
+}
+
+// se 读取有符号指数哥伦布编码
+func (r *bitReader) se() int {
+	v := r.ue()
+	if v%2 == 1 {
+		return int(v+1) / 2
+	}
+	return -int(v / 2)
+}
+
+func (r *bitReader) skipScalingList(size int) {
+	last, next := 8, 8
+	for i := 0; i < size && r.err == nil; i++ {
+		if next != 0 {
+			next = (last + r.se() + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
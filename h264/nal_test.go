@@ -0,0 +1,125 @@
+package h264_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"mytrpc/h264"
+)
+
+// 编码器输出的真实参数集
+var (
+	// x264 编码的 1920x1080 High@4.0，编码尺寸 1920x1088，裁掉底部8行，带 VUI 及防竞争字节
+	spsX264 = mustHex("67640028acd940780227e584000003000400000300f03c60c658")
+	ppsX264 = mustHex("68ebe3cb22c0")
+	// 1280x720 Main@3.1，无裁剪
+	spsMain = mustHex("674d401f965402802dc8")
+	// 640x480 Constrained Baseline@3.1
+	spsBaseline = mustHex("6742c01f8c8d40501ed00f08846a")
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestSplitAnnexB(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want [][]byte
+	}{
+		{name: "empty", data: nil, want: nil},
+		{name: "4 byte start code", data: []byte{0, 0, 0, 1, 0x65, 1, 2}, want: [][]byte{{0x65, 1, 2}}},
+		{name: "3 byte start code", data: []byte{0, 0, 1, 0x41, 9}, want: [][]byte{{0x41, 9}}},
+		{
+			name: "mixed start codes",
+			data: []byte{0, 0, 0, 1, 0x67, 1, 0, 0, 1, 0x68, 2, 0, 0, 0, 1, 0x65, 3},
+			want: [][]byte{{0x67, 1}, {0x68, 2}, {0x65, 3}},
+		},
+		{name: "no start code", data: []byte{0x65, 1, 2}, want: [][]byte{{0x65, 1, 2}}},
+		{name: "leading data", data: []byte{0x09, 0xf0, 0, 0, 1, 0x65}, want: [][]byte{{0x09, 0xf0}, {0x65}}},
+		{name: "trailing zeros", data: []byte{0, 0, 1, 0x65, 7, 0, 0, 0, 0, 1, 0x41, 0, 0}, want: [][]byte{{0x65, 7}, {0x41}}},
+		{name: "empty NAL", data: []byte{0, 0, 1, 0, 0, 1, 0x65}, want: [][]byte{{0x65}}},
+		// 0x000003 为防竞争字节，不是起始码
+		{name: "emulation prevention", data: []byte{0, 0, 1, 0x65, 0, 0, 3, 1}, want: [][]byte{{0x65, 0, 0, 3, 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := h264.SplitAnnexB(tt.data)
+			if len(got) != len(tt.want) {
+				t.Fatalf("SplitAnnexB = %x, want %x", got, tt.want)
+			}
+			for i := range got {
+				if !bytes.Equal(got[i], tt.want[i]) {
+					t.Fatalf("NAL %d = %x, want %x", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseSPS(t *testing.T) {
+	tests := []struct {
+		name    string
+		nal     []byte
+		want    h264.SPS
+		wantErr bool
+	}{
+		{
+			name: "x264 high cropped",
+			nal:  spsX264,
+			want: h264.SPS{Profile: 100, Compatibility: 0, Level: 40, ChromaFormat: 1, Width: 1920, Height: 1080},
+		},
+		{
+			name: "main",
+			nal:  spsMain,
+			want: h264.SPS{Profile: 77, Compatibility: 0x40, Level: 31, ChromaFormat: 1, Width: 1280, Height: 720},
+		},
+		{
+			name: "constrained baseline",
+			nal:  spsBaseline,
+			want: h264.SPS{Profile: 66, Compatibility: 0xc0, Level: 31, ChromaFormat: 1, Width: 640, Height: 480},
+		},
+		{name: "pps", nal: ppsX264, wantErr: true},
+		{name: "too short", nal: []byte{0x67, 0x64}, wantErr: true},
+		{name: "truncated", nal: spsX264[:6], wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h264.ParseSPS(tt.nal)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Fatalf("ParseSPS = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAccessUnit(t *testing.T) {
+	var data []byte
+	for _, nal := range [][]byte{{0x09, 0xf0}, spsX264, ppsX264, {0x65, 0x88, 0x84}} {
+		data = append(append(data, 0, 0, 0, 1), nal...)
+	}
+	au := h264.ParseAccessUnit(data)
+	if len(au.NALUs) != 4 || !au.Key || !au.HasPicture() {
+		t.Fatalf("ParseAccessUnit = %d NALs, key %v, picture %v", len(au.NALUs), au.Key, au.HasPicture())
+	}
+	if !bytes.Equal(au.SPS, spsX264) || !bytes.Equal(au.PPS, ppsX264) {
+		t.Fatalf("SPS %x PPS %x, want %x %x", au.SPS, au.PPS, spsX264, ppsX264)
+	}
+
+	au = h264.ParseAccessUnit([]byte{0, 0, 1, 0x06, 5, 0, 0, 1, 0x41, 1})
+	if au.Key || !au.HasPicture() || au.SPS != nil {
+		t.Fatalf("delta frame parsed as %+v", au)
+	}
+	if h264.ParseAccessUnit(append([]byte{0, 0, 1}, spsX264...)).HasPicture() {
+		t.Fatal("SPS only access unit reported a picture")
+	}
+}
@@ -0,0 +1,235 @@
+// Package humanize 在 device.Device 之上生成更接近真人的输入：
+// 点击落在目标区域内的随机位置并随机按压时长，滑动沿贝塞尔曲线并带有加减速，
+// 文本分段输入并带有随机的按键间隔。
+//
+// 所有随机量都来自可指定种子的随机数生成器，相同种子生成相同的操作序列，便于复现和测试。
+package humanize
+
+import (
+	"context"
+	"image"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"mytrpc/device"
+)
+
+// Options 为随机参数，零值字段使用默认值
+type Options struct {
+	// PressMin、PressMax 为点击按压时长范围，默认 60ms-140ms
+	PressMin, PressMax time.Duration
+	// SwipeCurve 为滑动轨迹偏离直线的最大比例（相对滑动距离），默认0.15
+	SwipeCurve float64
+	// SwipeJitter 为滑动起点和终点的随机偏移像素，默认8
+	SwipeJitter int
+	// DurationJitter 为滑动时长的随机浮动比例，默认0.15
+	DurationJitter float64
+	// ChunkMin、ChunkMax 为每次 sendText 的字符数范围，默认 1-4
+	ChunkMin, ChunkMax int
+	// KeyDelayMin、KeyDelayMax 为每个字符的输入间隔范围，默认 60ms-180ms
+	KeyDelayMin, KeyDelayMax time.Duration
+	// ThinkChance 为两段输入之间额外停顿的概率，默认0.1；ThinkMin、ThinkMax 为停顿时长，默认 300ms-900ms
+	ThinkChance        float64
+	ThinkMin, ThinkMax time.Duration
+}
+
+func (o Options) withDefaults() Options {
+	durations := []struct {
+		min, max *time.Duration
+		a, b     time.Duration
+	}{
+		{&o.PressMin, &o.PressMax, 60 * time.Millisecond, 140 * time.Millisecond},
+		{&o.KeyDelayMin, &o.KeyDelayMax, 60 * time.Millisecond, 180 * time.Millisecond},
+		{&o.ThinkMin, &o.ThinkMax, 300 * time.Millisecond, 900 * time.Millisecond},
+	}
+	for _, d := range durations {
+		if *d.min <= 0 {
+			*d.min = d.a
+		}
+		if *d.max < *d.min {
+			*d.max = max(d.b, *d.min)
+		}
+	}
+	if o.SwipeCurve <= 0 {
+		o.SwipeCurve = 0.15
+	}
+	if o.SwipeJitter <= 0 {
+		o.SwipeJitter = 8
+	}
+	if o.DurationJitter <= 0 {
+		o.DurationJitter = 0.15
+	}
+	if o.ChunkMin <= 0 {
+		o.ChunkMin = 1
+	}
+	if o.ChunkMax < o.ChunkMin {
+		o.ChunkMax = max(4, o.ChunkMin)
+	}
+	if o.ThinkChance <= 0 {
+		o.ThinkChance = 0.1
+	}
+	return o
+}
+
+// Humanizer 生成并执行随机化的输入，可以被多个 goroutine 同时使用，
+// 但只有单个 goroutine 使用时操作序列才可复现
+type Humanizer struct {
+	dev  *device.Device
+	opts Options
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// New 创建使用 seed 作为随机种子的 Humanizer
+func New(dev *device.Device, seed int64, opts Options) *Humanizer {
+	return &Humanizer{
+		dev:  dev,
+		opts: opts.withDefaults(),
+		rng:  rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (h *Humanizer) float() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.rng.Float64()
+}
+
+func (h *Humanizer) norm() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.rng.NormFloat64()
+}
+
+// Between 返回 [min, max] 内的随机时长
+func (h *Humanizer) Between(min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+	return min + time.Duration(h.float()*float64(max-min))
+}
+
+func (h *Humanizer) intn(min, max int) int {
+	if max <= min {
+		return min
+	}
+	return min + int(h.float()*float64(max-min+1))
+}
+
+// Pause 随机等待 [min, max] 内的时长，ctx 取消时返回 ctx 的错误
+func (h *Humanizer) Pause(ctx context.Context, min, max time.Duration) error {
+	return device.Sleep(ctx, h.Between(min, max))
+}
+
+// TapPlan 返回一次点击的位置和按压时长。位置服从以区域中心为均值的正态分布，
+// 并被限制在区域内部
+func (h *Humanizer) TapPlan(target image.Rectangle) (image.Point, time.Duration) {
+	target = target.Canon()
+	c := image.Pt((target.Min.X+target.Max.X)/2, (target.Min.Y+target.Max.Y)/2)
+	x := float64(c.X) + h.norm()*float64(target.Dx())/6
+	y := float64(c.Y) + h.norm()*float64(target.Dy())/6
+	p := image.Pt(
+		clamp(int(math.Round(x)), target.Min.X, max(target.Min.X, target.Max.X-1)),
+		clamp(int(math.Round(y)), target.Min.Y, max(target.Min.Y, target.Max.Y-1)),
+	)
+	return p, h.Between(h.opts.PressMin, h.opts.PressMax)
+}
+
+// Tap 在 target 区域内随机位置点击
+func (h *Humanizer) Tap(ctx context.Context, target image.Rectangle) error {
+	p, press := h.TapPlan(target)
+	return h.dev.Gesture(p).Hold(press).Perform(ctx)
+}
+
+// TapAround 在以 p 为中心、边长为 2*radius 的区域内随机位置点击
+func (h *Humanizer) TapAround(ctx context.Context, p image.Point, radius int) error {
+	return h.Tap(ctx, image.Rect(p.X-radius, p.Y-radius, p.X+radius+1, p.Y+radius+1))
+}
+
+// SwipeGesture 生成从 from 到 to 的随机化滑动：起止点带有小幅偏移，
+// 轨迹为控制点随机偏离直线的三次贝塞尔曲线，速度按最小加加速度曲线先加速后减速
+func (h *Humanizer) SwipeGesture(from, to image.Point, dur time.Duration) *device.Gesture {
+	j := h.opts.SwipeJitter
+	from = from.Add(image.Pt(h.intn(-j, j), h.intn(-j, j)))
+	to = to.Add(image.Pt(h.intn(-j, j), h.intn(-j, j)))
+
+	dx, dy := float64(to.X-from.X), float64(to.Y-from.Y)
+	dist := math.Hypot(dx, dy)
+	// 垂直于滑动方向的单位向量
+	nx, ny := 0.0, 0.0
+	if dist > 0 {
+		nx, ny = -dy/dist, dx/dist
+	}
+	control := func(t float64) image.Point {
+		off := (h.float()*2 - 1) * h.opts.SwipeCurve * dist
+		return image.Pt(
+			int(math.Round(float64(from.X)+dx*t+nx*off)),
+			int(math.Round(float64(from.Y)+dy*t+ny*off)),
+		)
+	}
+	c1, c2 := control(0.25+h.float()*0.15), control(0.6+h.float()*0.15)
+
+	scale := 1 + (h.float()*2-1)*h.opts.DurationJitter
+	dur = time.Duration(float64(dur) * scale)
+
+	return h.dev.Gesture(from).
+		Ease(MinimumJerk).
+		CubicTo(c1, c2, to, dur)
+}
+
+// Swipe 执行随机化滑动
+func (h *Humanizer) Swipe(ctx context.Context, from, to image.Point, dur time.Duration) error {
+	return h.SwipeGesture(from, to, dur).Perform(ctx)
+}
+
+// Chunk 是一次 sendText 输入的文本以及输入前的等待时长
+type Chunk struct {
+	Text  string
+	Delay time.Duration
+}
+
+// TypePlan 把 text 拆分为若干段，每段的等待时长按字符数累加随机间隔，偶尔加入额外停顿
+func (h *Humanizer) TypePlan(text string) []Chunk {
+	runes := []rune(text)
+	var chunks []Chunk
+	for i := 0; i < len(runes); {
+		n := min(h.intn(h.opts.ChunkMin, h.opts.ChunkMax), len(runes)-i)
+		var delay time.Duration
+		if i > 0 {
+			for k := 0; k < n; k++ {
+				delay += h.Between(h.opts.KeyDelayMin, h.opts.KeyDelayMax)
+			}
+			if h.float() < h.opts.ThinkChance {
+				delay += h.Between(h.opts.ThinkMin, h.opts.ThinkMax)
+			}
+		}
+		chunks = append(chunks, Chunk{Text: string(runes[i : i+n]), Delay: delay})
+		i += n
+	}
+	return chunks
+}
+
+// Type 分段输入 text
+func (h *Humanizer) Type(ctx context.Context, text string) error {
+	for _, c := range h.TypePlan(text) {
+		if err := device.Sleep(ctx, c.Delay); err != nil {
+			return err
+		}
+		if err := h.dev.SendTextCtx(ctx, c.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MinimumJerk 是最小加加速度速度曲线，起止速度为0，中段最快，接近手指滑动的速度变化
+func MinimumJerk(t float64) float64 {
+	return t * t * t * (10 - 15*t + 6*t*t)
+}
+
+func clamp(v, lo, hi int) int {
+	return min(max(v, lo), hi)
+}
@@ -0,0 +1,254 @@
+package humanize_test
+
+import (
+	"context"
+	"image"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"mytrpc/device"
+	"mytrpc/humanize"
+	"mytrpc/rpc"
+	"mytrpc/sim"
+)
+
+func newDevice(t *testing.T) (*device.Device, *sim.Device) {
+	t.Helper()
+	fake := sim.New()
+	if err := fake.AddScreenFile("home", "../nodes_ex.xml"); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.NewClientWithBackend(fake)
+	if err := client.Connect("sim", 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return device.NewDevice(client), fake
+}
+
+// plan 用同一个 Humanizer 依次生成点击、滑动和输入计划
+type plan struct {
+	Taps   []image.Point
+	Press  []time.Duration
+	Swipe  []device.TouchEvent
+	Chunks []humanize.Chunk
+}
+
+func makePlan(seed int64) plan {
+	h := humanize.New(nil, seed, humanize.Options{})
+	var p plan
+	for i := 0; i < 5; i++ {
+		pt, press := h.TapPlan(image.Rect(100, 200, 300, 260))
+		p.Taps, p.Press = append(p.Taps, pt), append(p.Press, press)
+	}
+	p.Swipe = h.SwipeGesture(image.Pt(360, 1000), image.Pt(360, 300), 400*time.Millisecond).Events()
+	p.Chunks = h.TypePlan("hello, 世界 and more text")
+	return p
+}
+
+func TestSameSeedSamePlan(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b int64
+		same bool
+	}{
+		{name: "same seed", a: 42, b: 42, same: true},
+		{name: "zero seed", a: 0, b: 0, same: true},
+		{name: "different seed", a: 42, b: 43, same: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := makePlan(tt.a), makePlan(tt.b)
+			if got := reflect.DeepEqual(a, b); got != tt.same {
+				t.Fatalf("plans equal = %v, want %v\na: %+v\nb: %+v", got, tt.same, a, b)
+			}
+		})
+	}
+}
+
+func TestTapPlanInsideTarget(t *testing.T) {
+	opts := humanize.Options{PressMin: 50 * time.Millisecond, PressMax: 70 * time.Millisecond}
+	tests := []struct {
+		name   string
+		target image.Rectangle
+	}{
+		{name: "button", target: image.Rect(100, 200, 300, 260)},
+		{name: "reversed", target: image.Rect(300, 260, 100, 200)},
+		{name: "single pixel", target: image.Rect(10, 10, 11, 11)},
+		{name: "thin", target: image.Rect(0, 500, 720, 502)},
+		{name: "at origin", target: image.Rect(0, 0, 40, 40)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := humanize.New(nil, 1, opts)
+			target := tt.target.Canon()
+			var sum image.Point
+			const n = 2000
+			for i := 0; i < n; i++ {
+				p, press := h.TapPlan(tt.target)
+				if !p.In(target) {
+					t.Fatalf("tap %v outside %v", p, target)
+				}
+				if press < opts.PressMin || press > opts.PressMax {
+					t.Fatalf("press %v outside [%v, %v]", press, opts.PressMin, opts.PressMax)
+				}
+				sum = sum.Add(p)
+			}
+			// 位置以中心为均值
+			mean := sum.Div(n)
+			center := image.Pt((target.Min.X+target.Max.X)/2, (target.Min.Y+target.Max.Y)/2)
+			if d := mean.Sub(center); abs(d.X) > max(2, target.Dx()/20) || abs(d.Y) > max(2, target.Dy()/20) {
+				t.Errorf("mean tap %v, center %v", mean, center)
+			}
+		})
+	}
+}
+
+func TestSwipeGestureEndpoints(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to image.Point
+		jitter   int
+	}{
+		{name: "up", from: image.Pt(360, 1000), to: image.Pt(360, 300), jitter: 8},
+		{name: "diagonal", from: image.Pt(100, 100), to: image.Pt(600, 900), jitter: 3},
+		{name: "zero length", from: image.Pt(200, 200), to: image.Pt(200, 200), jitter: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for seed := int64(0); seed < 20; seed++ {
+				h := humanize.New(nil, seed, humanize.Options{SwipeJitter: tt.jitter})
+				g := h.SwipeGesture(tt.from, tt.to, 300*time.Millisecond)
+				events := g.Events()
+				first, last := events[0], events[len(events)-1]
+				if first.Action != device.TouchActionDown || last.Action != device.TouchActionUp {
+					t.Fatalf("events start with %s and end with %s", first.Action, last.Action)
+				}
+				if !near(first.Point, tt.from, tt.jitter) || !near(last.Point, tt.to, tt.jitter) {
+					t.Fatalf("seed %d: swipe %v -> %v, want within %d of %v -> %v",
+						seed, first.Point, last.Point, tt.jitter, tt.from, tt.to)
+				}
+				// 贝塞尔曲线在 t=1 时落在终点，最后一次移动即为抬起的位置
+				if move := events[len(events)-2]; move.Action != device.TouchActionMove || move.Point != last.Point {
+					t.Fatalf("seed %d: last move %+v, up at %v", seed, move, last.Point)
+				}
+				// 时长按 DurationJitter（默认0.15）浮动
+				if d := g.Duration(); d < 255*time.Millisecond || d > 345*time.Millisecond {
+					t.Fatalf("seed %d: duration %v", seed, d)
+				}
+			}
+		})
+	}
+}
+
+func TestMinimumJerk(t *testing.T) {
+	tests := []struct {
+		t, want float64
+	}{
+		{0, 0},
+		{1, 1},
+		{0.5, 0.5},
+		{0.25, 0.103515625},
+		{0.75, 0.896484375},
+	}
+	for _, tt := range tests {
+		if got := humanize.MinimumJerk(tt.t); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("MinimumJerk(%v) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+	// 单调递增，起止速度接近0，中段最快
+	const step = 1e-4
+	prev := 0.0
+	for i := 1; i <= 1e4; i++ {
+		v := humanize.MinimumJerk(float64(i) * step)
+		if v < prev {
+			t.Fatalf("not monotonic at %v", float64(i)*step)
+		}
+		prev = v
+	}
+	speed := func(x float64) float64 { return (humanize.MinimumJerk(x+step) - humanize.MinimumJerk(x)) / step }
+	if speed(0) > 1e-3 || speed(1-step) > 1e-3 || speed(0.5) < 1.8 {
+		t.Errorf("speeds: start %v, middle %v, end %v", speed(0), speed(0.5), speed(1-step))
+	}
+}
+
+func TestTypePlan(t *testing.T) {
+	opts := humanize.Options{
+		ChunkMin: 2, ChunkMax: 3,
+		KeyDelayMin: 10 * time.Millisecond, KeyDelayMax: 20 * time.Millisecond,
+		ThinkMin: time.Second, ThinkMax: time.Second,
+	}
+	tests := []string{"", "a", "hello world", "你好，世界", "mixed 文本 😀 text"}
+	for _, text := range tests {
+		h := humanize.New(nil, 7, opts)
+		chunks := h.TypePlan(text)
+		var joined strings.Builder
+		for i, c := range chunks {
+			joined.WriteString(c.Text)
+			n := len([]rune(c.Text))
+			if n < 1 || n > opts.ChunkMax || n < opts.ChunkMin && i != len(chunks)-1 {
+				t.Errorf("%q: chunk %d %q has %d characters", text, i, c.Text, n)
+			}
+			switch {
+			case i == 0 && c.Delay != 0:
+				t.Errorf("%q: first chunk waits %v", text, c.Delay)
+			case i > 0:
+				// 每个字符一个间隔，额外停顿固定为1秒
+				lo, hi := time.Duration(n)*opts.KeyDelayMin, time.Duration(n)*opts.KeyDelayMax
+				if d := c.Delay; !(d >= lo && d <= hi) && !(d >= lo+time.Second && d <= hi+time.Second) {
+					t.Errorf("%q: chunk %d delay %v outside [%v, %v] (+1s)", text, i, d, lo, hi)
+				}
+			}
+		}
+		if joined.String() != text {
+			t.Errorf("chunks join to %q, want %q", joined.String(), text)
+		}
+	}
+}
+
+func TestTapAndTypeOnDevice(t *testing.T) {
+	dev, fake := newDevice(t)
+	h := humanize.New(dev, 3, humanize.Options{PressMin: time.Millisecond, PressMax: 2 * time.Millisecond})
+	target := image.Rect(100, 200, 300, 260)
+	ctx := context.Background()
+	if err := h.Tap(ctx, target); err != nil {
+		t.Fatal(err)
+	}
+	events := fake.Events()
+	if len(events) != 2 || events[0].Kind != sim.EventTouchDown || events[1].Kind != sim.EventTouchUp {
+		t.Fatalf("tap events = %+v, want down and up", events)
+	}
+	if p := image.Pt(events[0].X, events[0].Y); !p.In(target) {
+		t.Fatalf("tap at %v outside %v", p, target)
+	}
+
+	fake.ResetEvents()
+	opts := humanize.Options{KeyDelayMin: time.Microsecond, KeyDelayMax: time.Microsecond, ThinkMin: time.Microsecond, ThinkMax: time.Microsecond}
+	h = humanize.New(dev, 3, opts)
+	if err := h.Type(ctx, "hello 世界"); err != nil {
+		t.Fatal(err)
+	}
+	var typed strings.Builder
+	for _, e := range fake.Events() {
+		if e.Kind == sim.EventSendText {
+			typed.WriteString(e.Text)
+		}
+	}
+	if typed.String() != "hello 世界" {
+		t.Fatalf("typed %q", typed.String())
+	}
+}
+
+func near(p, q image.Point, d int) bool {
+	return abs(p.X-q.X) <= d && abs(p.Y-q.Y) <= d
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
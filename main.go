@@ -2,122 +2,103 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"image"
 	"log"
 	"os"
+	"os/signal"
 	"strconv"
 	"sync"
+	"syscall"
 	"time"
 
 	"mytrpc/device"
+	"mytrpc/fleet"
+	"mytrpc/humanize"
 	"mytrpc/rpc"
 
 	"golang.org/x/exp/rand"
 )
 
-// 设备信息结构体
-type DeviceInfo struct {
-	Host   string
-	Port   int
-	Device *device.Device
-	Wg     sync.WaitGroup
-	Ctx    context.Context
-	Cancel context.CancelFunc
-}
-
-var (
-	devices     = make(map[string]*DeviceInfo) // 设备映射表 key格式: IP_Port
-	devicesLock sync.RWMutex
-)
-
-// 执行回复操作
-func doReply(dev *device.Device, i int, wg *sync.WaitGroup, sendText string) {
+// 执行回复操作，ctx 取消时在步骤之间及等待期间中止。
+// 点击位置、按压时长、等待时间和输入节奏都由 h 随机生成
+func doReply(ctx context.Context, dev *device.Device, h *humanize.Humanizer, i int, wg *sync.WaitGroup, sendText string) error {
 	defer wg.Done()
 
 	log.Println("第", i+1, "次开始")
 	// 按下键盘esc
-	dev.KeyPress(111)
-	time.Sleep(1000 * time.Millisecond) // 增加等待时间
+	if err := dev.KeyPressCtx(ctx, 111); err != nil {
+		return fmt.Errorf("press esc failed (按下esc失败): %w", err)
+	}
+	if err := h.Pause(ctx, 800*time.Millisecond, 1300*time.Millisecond); err != nil {
+		return err
+	}
 
 	// 点击More按钮
-	dev.TouchDown(660, 1200, 1)
-	time.Sleep(1500 * time.Millisecond) // 增加触摸时间
-	dev.TouchUp(660, 1200, 1)
-	time.Sleep(1500 * time.Millisecond) // 增加等待时间确保界面切换
+	if err := h.TapAround(ctx, image.Pt(660, 1200), 20); err != nil {
+		return err
+	}
+	if err := h.Pause(ctx, 1200*time.Millisecond, 2000*time.Millisecond); err != nil { // 等待界面切换
+		return err
+	}
 
 	// 点击Comment按钮
-	dev.TouchDown(200, 1000, 2)
-	time.Sleep(1500 * time.Millisecond) // 增加触摸时间
-	dev.TouchUp(200, 1000, 2)
-	time.Sleep(1500 * time.Millisecond) // 增加等待时间确保输入框就绪
+	if err := h.TapAround(ctx, image.Pt(200, 1000), 20); err != nil {
+		return err
+	}
+	if err := h.Pause(ctx, 1200*time.Millisecond, 2000*time.Millisecond); err != nil { // 等待输入框就绪
+		return err
+	}
 
 	// 输入评论（带重试机制）
 	for retry := 0; retry < 3; retry++ {
-		dev.ClearText(1000)
-		time.Sleep(800 * time.Millisecond)
-		if err := dev.SendText(sendText); err == nil {
+		if err := dev.ClearTextCtx(ctx, 1000); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("清空输入框失败(第%d次重试): %v", retry+1, err)
+			if err := h.Pause(ctx, 800*time.Millisecond, 1500*time.Millisecond); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := h.Pause(ctx, 600*time.Millisecond, 1000*time.Millisecond); err != nil {
+			return err
+		}
+		if err := h.Type(ctx, sendText); err == nil {
 			log.Println("发送文字成功!")
-			time.Sleep(500 * time.Millisecond)
+			if err := h.Pause(ctx, 400*time.Millisecond, 900*time.Millisecond); err != nil {
+				return err
+			}
 			// 点击发送
-			dev.KeyPress(66)
+			if err := dev.KeyPressCtx(ctx, 66); err != nil {
+				return fmt.Errorf("press enter to send failed (按回车发送失败): %w", err)
+			}
 			break
 		} else {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			log.Printf("发送文字失败(第%d次重试): %v", retry+1, err)
-			time.Sleep(1 * time.Second)
+			if err := h.Pause(ctx, 800*time.Millisecond, 1500*time.Millisecond); err != nil {
+				return err
+			}
 		}
 	}
 
-	time.Sleep(300 * time.Millisecond) // 增加结束等待
-	log.Println("第", i+1, "次结束")
-}
-
-// 初始化设备连接
-func initDevice(host string, port int) (*DeviceInfo, error) {
-	deviceID := fmt.Sprintf("%s_%d", host, port)
-
-	// 检查设备是否已存在
-	devicesLock.RLock()
-	if dev, exists := devices[deviceID]; exists {
-		devicesLock.RUnlock()
-		return dev, nil
+	if err := h.Pause(ctx, 300*time.Millisecond, 600*time.Millisecond); err != nil {
+		return err
 	}
-	devicesLock.RUnlock()
-
-	// 创建新设备连接
-	client := rpc.NewClient()
-	if err := client.Connect(host, port); err != nil {
-		return nil, fmt.Errorf("连接设备失败: %v", err)
-	}
-
-	// 检查连接状态
-	if connected, err := client.CheckConnectState(); err != nil || !connected {
-		return nil, fmt.Errorf("设备连接验证失败")
-	}
-
-	// 创建设备上下文
-	ctx, cancel := context.WithCancel(context.Background())
-
-	dev := &DeviceInfo{
-		Host:   host,
-		Port:   port,
-		Device: device.NewDevice(client),
-		Ctx:    ctx,
-		Cancel: cancel,
-	}
-
-	// 保存到设备映射表
-	devicesLock.Lock()
-	devices[deviceID] = dev
-	devicesLock.Unlock()
-
-	return dev, nil
+	log.Println("第", i+1, "次结束")
+	return nil
 }
 
 // 修改设备任务执行方式为顺序执行
-func startDeviceTask(host string, port int) {
-	deviceID := fmt.Sprintf("%s_%d", host, port)
+func startDeviceTask(devices *fleet.Manager, host string, port int) {
+	deviceID := fleet.ID(host, port)
 
-	dev, err := initDevice(host, port)
+	dev, err := devices.Get(host, port)
 	if err != nil {
 		log.Printf("[%s] 初始化失败: %v", deviceID, err)
 		return
@@ -125,26 +106,42 @@ func startDeviceTask(host string, port int) {
 
 	log.Printf("[%s] 开始执行任务", deviceID)
 
+	// 脚本中的坐标按 720x1280 竖屏画面给出，执行时按实际分辨率和屏幕方向缩放
+	target := dev.Device().Scaled(image.Pt(720, 1280))
+	h := humanize.New(target, time.Now().UnixNano(), humanize.Options{})
+
 	// 修改为顺序执行，每次循环等待完成
+	var wg sync.WaitGroup
 	for i := 0; i < 100; i++ {
 		select {
-		case <-dev.Ctx.Done():
+		case <-dev.Context().Done():
 			log.Printf("[%s] 任务已终止", deviceID)
 			return
 		default:
 			// 移除了goroutine，直接顺序执行
-			dev.Wg.Add(1)
+			wg.Add(1)
 			sendText := strconv.Itoa(rand.Intn(1000000))
-			doReply(dev.Device, i, &dev.Wg, sendText)
+			if err := doReply(dev.Context(), target, h, i, &wg, sendText); err != nil {
+				log.Printf("[%s] 任务已终止: %v", deviceID, err)
+				return
+			}
 		}
 	}
-	dev.Wg.Wait()
+	wg.Wait()
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "scan" {
+		if err := runScan(os.Args[2:], os.Stdout, nil); err != nil && err != flag.ErrHelp {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	if len(os.Args) < 3 {
 		log.Println("用法: mytrpc <host> <port1> [port2] ...")
 		log.Println("示例: mytrpc 192.168.1.181 7101 7102")
+		log.Println("扫描设备: mytrpc scan <host|cidr> <ports>，如 mytrpc scan 192.168.1.181 7100-7199")
 		return
 	}
 
@@ -166,23 +163,36 @@ func main() {
 		return
 	}
 
+	// 收到中断信号时取消所有设备任务
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 设备连接断线后自动重连，重连期间的操作最多等待1分钟
+	devices := fleet.New(
+		fleet.WithContext(ctx),
+		fleet.WithClientFactory(func(host string, port int, opts ...rpc.Option) *rpc.Client {
+			deviceID := fleet.ID(host, port)
+			return rpc.NewClient(append(opts,
+				rpc.WithKeepalive(5*time.Second),
+				rpc.WithReconnectWait(time.Minute),
+				rpc.WithStateListener(func(e rpc.StateChange) {
+					log.Printf("[%s] 连接状态 %s -> %s: %v", deviceID, e.From, e.To, e.Err)
+				}),
+			)...)
+		}),
+	)
+	defer devices.Close()
+
 	// 为每个端口启动独立任务
 	var mainWg sync.WaitGroup
 	for _, port := range ports {
 		mainWg.Add(1)
 		go func(p int) {
 			defer mainWg.Done()
-			startDeviceTask(host, p)
+			startDeviceTask(devices, host, p)
 		}(port)
 	}
 
 	// 等待所有任务完成
 	mainWg.Wait()
-
-	// 清理资源
-	devicesLock.RLock()
-	defer devicesLock.RUnlock()
-	for _, dev := range devices {
-		dev.Cancel()
-	}
 }
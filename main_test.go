@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"image"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mytrpc/device"
+	"mytrpc/humanize"
+	"mytrpc/rpc"
+	"mytrpc/sim"
+)
+
+// replySim 返回模拟回复流程的设备：video 画面点击 More 进入 menu，点击 Comment 进入 comment，
+// 在 comment 中按回车发送后进入 sent
+func replySim(t *testing.T) (*device.Device, *sim.Device) {
+	t.Helper()
+	fake := replyScreens(t)
+	return connectScaled(t, fake), fake
+}
+
+func replyScreens(t *testing.T) *sim.Device {
+	t.Helper()
+	fake := sim.New()
+	for _, name := range []string{"video", "menu", "comment", "sent"} {
+		if err := fake.AddScreenFile(name, "nodes_ex.xml"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fake.OnTap("video", image.Rect(640, 1180, 681, 1221), "menu")
+	fake.OnTap("menu", image.Rect(180, 980, 221, 1021), "comment")
+	fake.OnKey("comment", 66, "sent")
+	return fake
+}
+
+// connectScaled 连接 backend，与 startDeviceTask 相同，脚本坐标按 720x1280 缩放
+func connectScaled(t *testing.T, backend rpc.Backend) *device.Device {
+	t.Helper()
+	client := rpc.NewClientWithBackend(backend)
+	if err := client.Connect("sim", 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return device.NewDevice(client).Scaled(image.Pt(720, 1280))
+}
+
+// failingKey 让指定按键的前 times 次 keyPress 失败，times 为负数时一直失败
+type failingKey struct {
+	*sim.Device
+
+	code  int
+	mu    sync.Mutex
+	times int
+}
+
+var errKey = errors.New("key press rejected")
+
+func (f *failingKey) KeyPress(handle uintptr, code int) error {
+	f.mu.Lock()
+	fail := code == f.code && f.times != 0
+	if fail && f.times > 0 {
+		f.times--
+	}
+	f.mu.Unlock()
+	if fail {
+		return errKey
+	}
+	return f.Device.KeyPress(handle, code)
+}
+
+func TestDoReply(t *testing.T) {
+	if testing.Short() {
+		t.Skip("the reply flow waits several seconds between steps")
+	}
+	t.Parallel()
+	dev, fake := replySim(t)
+	h := humanize.New(dev, 1, humanize.Options{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	if err := doReply(context.Background(), dev, h, 0, &wg, "123456"); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if got := fake.Screen(); got != "sent" {
+		t.Fatalf("screen = %s, want sent", got)
+	}
+
+	// 依次为：esc、点击 More、点击 Comment、清空输入框、分段输入、回车
+	var steps []string
+	var typed strings.Builder
+	backspaces := 0
+	for _, e := range fake.Events() {
+		switch {
+		case e.Kind == sim.EventKeyPress && e.Code == 67:
+			backspaces++
+			if backspaces == 1 {
+				steps = append(steps, "clear@"+e.Screen)
+			}
+		case e.Kind == sim.EventKeyPress:
+			steps = append(steps, "key"+strconv.Itoa(e.Code)+"@"+e.Screen)
+		case e.Kind == sim.EventTouchDown:
+			steps = append(steps, "tap@"+e.Screen)
+		case e.Kind == sim.EventSendText:
+			if e.Screen != "comment" {
+				t.Fatalf("typed %q on %s", e.Text, e.Screen)
+			}
+			typed.WriteString(e.Text)
+		}
+	}
+	want := "key111@video tap@video tap@menu clear@comment key66@comment"
+	if got := strings.Join(steps, " "); got != want {
+		t.Fatalf("steps = %s\nwant %s", got, want)
+	}
+	if backspaces != 1000 {
+		t.Errorf("sent %d backspaces, want 1000", backspaces)
+	}
+	if typed.String() != "123456" {
+		t.Errorf("typed %q, want 123456", typed.String())
+	}
+}
+
+func TestDoReplyCancel(t *testing.T) {
+	dev, fake := replySim(t)
+	h := humanize.New(dev, 1, humanize.Options{})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	start := time.Now()
+	err := doReply(ctx, dev, h, 0, &wg, "123456")
+	wg.Wait()
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("doReply returned %v after cancel", d)
+	}
+	// 在第一次等待中取消，只按下了 esc
+	if events := fake.Events(); len(events) != 1 || events[0].Code != 111 || fake.Screen() != "video" {
+		t.Fatalf("events = %+v on %s, want only esc", events, fake.Screen())
+	}
+}
+
+func TestDoReplyKeyErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		code   int
+		times  int
+		long   bool
+		err    string
+		screen string
+	}{
+		{name: "esc fails", code: 111, times: -1, err: "press esc failed", screen: "video"},
+		{name: "send fails", code: 66, times: -1, long: true, err: "press enter to send failed", screen: "comment"},
+		// 清空失败时重试，之后的输入照常进行
+		{name: "clear fails once", code: 67, times: 1, long: true, screen: "sent"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.long {
+				if testing.Short() {
+					t.Skip("the reply flow waits several seconds between steps")
+				}
+				t.Parallel()
+			}
+			fake := &failingKey{Device: replyScreens(t), code: tt.code, times: tt.times}
+			dev := connectScaled(t, fake)
+			h := humanize.New(dev, 1, humanize.Options{})
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+			err := doReply(context.Background(), dev, h, 0, &wg, "42")
+			wg.Wait()
+			if tt.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.err != "" && (!errors.Is(err, errKey) || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("err = %v, want %q wrapping the key error", err, tt.err)
+			}
+			if got := fake.Screen(); got != tt.screen {
+				t.Fatalf("screen = %s, want %s", got, tt.screen)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"sync"
+
+	"mytrpc/device"
+)
+
+const (
+	// maxFingers 为同时按下的手指数上限
+	maxFingers = 10
+	// maxTextLen 为一次 text 消息的最大字符数
+	maxTextLen = 1000
+)
+
+// control 为观看页面发送的操作消息（WebSocket 文本消息）：
+//
+//	{"type":"down","id":0,"x":0.5,"y":0.25,"w":720,"h":1280}  按下，x、y 为画面上的归一化坐标 [0,1]
+//	{"type":"move","id":0,"x":0.5,"y":0.30,"w":720,"h":1280}  移动
+//	{"type":"up","id":0,"x":0.5,"y":0.35,"w":720,"h":1280}    抬起
+//	{"type":"key","code":4}                                    按键，code 为 Android 键码
+//	{"type":"text","text":"hello"}                             输入文本
+//
+// w、h 为页面实际解码出的画面尺寸，编码器输出的尺寸可能与请求的推流尺寸不同（如横屏时宽高互换），
+// 省略时使用消息头中的尺寸
+type control struct {
+	Type string  `json:"type"`
+	ID   int     `json:"id"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+	W    int     `json:"w"`
+	H    int     `json:"h"`
+	Code int     `json:"code"`
+	Text string  `json:"text"`
+}
+
+// serverMessage 为发送给观看页面的文本消息
+type serverMessage struct {
+	Type    string `json:"type"`
+	Control bool   `json:"control,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// frameView 记录一个连接最近发送的画面，用于把页面上的坐标映射回设备
+type frameView struct {
+	mu    sync.Mutex
+	frame Message
+}
+
+func (v *frameView) set(m Message) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.frame = m
+	v.frame.Data = nil
+}
+
+func (v *frameView) get() Message {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.frame
+}
+
+// controller 在一个连接上执行操作，记录按下的手指以便断开时抬起
+type controller struct {
+	s       *Server
+	view    *frameView
+	fingers map[int]touchPoint
+}
+
+// touchPoint 为手指最后的位置，坐标属于 dev 对应的坐标视图
+type touchPoint struct {
+	dev *device.Device
+	p   image.Point
+}
+
+func newController(s *Server, view *frameView) *controller {
+	return &controller{s: s, view: view, fingers: make(map[int]touchPoint)}
+}
+
+// handle 解析并执行一条操作消息
+func (c *controller) handle(ctx context.Context, data []byte) error {
+	var msg control
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return fmt.Errorf("invalid control message (操作消息格式错误): %w", err)
+	}
+	switch msg.Type {
+	case "down", "move", "up":
+		return c.touch(ctx, msg)
+	case "key":
+		if msg.Code <= 0 || msg.Code > 400 {
+			return fmt.Errorf("invalid key code %d (键码无效)", msg.Code)
+		}
+		return c.s.dev.KeyPressCtx(ctx, device.KeyCode(msg.Code))
+	case "text":
+		if msg.Text == "" {
+			return nil
+		}
+		if n := len([]rune(msg.Text)); n > maxTextLen {
+			return fmt.Errorf("text too long: %d characters (文本过长)", n)
+		}
+		return c.s.dev.SendTextCtx(ctx, msg.Text)
+	}
+	return fmt.Errorf("unknown control type %q (未知的操作类型)", msg.Type)
+}
+
+func (c *controller) touch(ctx context.Context, msg control) error {
+	if msg.ID < 0 || msg.ID >= maxFingers {
+		return fmt.Errorf("invalid finger id %d (手指编号无效)", msg.ID)
+	}
+	if math.IsNaN(msg.X) || math.IsNaN(msg.Y) {
+		return errors.New("invalid touch position (触摸坐标无效)")
+	}
+	_, down := c.fingers[msg.ID]
+	switch {
+	case msg.Type != "down" && !down:
+		return nil
+	case msg.Type == "down" && down:
+		// 页面漏发了抬起，先补上
+		if err := c.up(ctx, msg.ID); err != nil {
+			return err
+		}
+	}
+
+	frame := c.view.get()
+	if msg.W > 0 && msg.H > 0 {
+		frame.Width, frame.Height = msg.W, msg.H
+	}
+	dev, p, err := c.s.mapPoint(ctx, frame, msg.X, msg.Y)
+	if err != nil {
+		return err
+	}
+	c.fingers[msg.ID] = touchPoint{dev: dev, p: p}
+	switch msg.Type {
+	case "down":
+		return dev.TouchDownCtx(ctx, p.X, p.Y, msg.ID)
+	case "move":
+		return dev.TouchMoveCtx(ctx, p.X, p.Y, msg.ID)
+	}
+	return c.up(ctx, msg.ID)
+}
+
+func (c *controller) up(ctx context.Context, id int) error {
+	t := c.fingers[id]
+	delete(c.fingers, id)
+	return t.dev.TouchUpCtx(ctx, t.p.X, t.p.Y, id)
+}
+
+// release 抬起所有仍按下的手指，连接断开时调用
+func (c *controller) release(ctx context.Context) {
+	for id := range c.fingers {
+		c.up(ctx, id)
+	}
+}
+
+// mapPoint 把画面上的归一化坐标转换为设备坐标，返回应使用的坐标视图和坐标，
+// frame 的宽高为页面实际显示的画面尺寸。
+// 截图和已按屏幕方向旋转的视频画面与屏幕显示一致，使用 Normalized 视图按当前方向转换；
+// 视频画面为自然方向（横屏时宽高与屏幕显示相反）时直接按物理尺寸缩放。
+func (s *Server) mapPoint(ctx context.Context, frame Message, u, v float64) (*device.Device, image.Point, error) {
+	u, v = min(max(u, 0), 1), min(max(v, 0), 1)
+	if frame.Kind != KindH264 || frame.Width <= 0 || frame.Height <= 0 || !frame.Rotation.Landscape() {
+		return s.normalized, device.Norm(u, v), nil
+	}
+	info, err := s.displayInfo(ctx)
+	if err != nil {
+		return nil, image.Point{}, err
+	}
+	if (frame.Width > frame.Height) == (info.Width > info.Height) {
+		// 画面与自然方向一致，未随屏幕旋转
+		p := image.Pt(
+			min(int(math.Round(u*float64(info.Width))), info.Width-1),
+			min(int(math.Round(v*float64(info.Height))), info.Height-1),
+		)
+		return s.dev, p, nil
+	}
+	return s.normalized, device.Norm(u, v), nil
+}
+
+// displayInfo 返回屏幕的自然尺寸，只在第一次使用时获取
+func (s *Server) displayInfo(ctx context.Context) (device.DisplayInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.display != nil {
+		return *s.display, nil
+	}
+	info, err := s.dev.DisplayInfoCtx(ctx)
+	if err != nil {
+		return info, err
+	}
+	s.display = &info
+	return info, nil
+}
@@ -0,0 +1,78 @@
+package mirror
+
+import (
+	"context"
+	"image"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"mytrpc/device"
+	"mytrpc/rpc"
+	"mytrpc/sim"
+)
+
+var quiet = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// rotatedServer 返回屏幕旋转90度、物理尺寸 720x1280 的模拟设备上的镜像服务
+func rotatedServer(t *testing.T) (*Server, *sim.Device) {
+	t.Helper()
+	xml, err := os.ReadFile("../nodes_ex.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := sim.New()
+	if err := fake.AddScreen("home", []byte(strings.Replace(string(xml), `rotation="0"`, `rotation="1"`, 1))); err != nil {
+		t.Fatal(err)
+	}
+	fake.SetCommandOutput("wm size", "Physical size: 720x1280\n")
+	client := rpc.NewClientWithBackend(fake, rpc.WithLogger(quiet))
+	if err := client.Connect("sim", 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	s := New(device.NewDevice(client), Options{Control: true, Logger: quiet})
+	t.Cleanup(func() { s.Close() })
+	return s, fake
+}
+
+func TestTouchUsesDecodedSize(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want image.Point
+	}{
+		// 画面随屏幕旋转：按当前方向的逻辑坐标转换，逻辑 (320,360) 对应物理 (359,320)
+		{name: "rotated", msg: `{"type":"down","id":0,"x":0.25,"y":0.5,"w":1280,"h":720}`, want: image.Pt(359, 320)},
+		// 画面为自然方向：直接按物理尺寸缩放
+		{name: "natural", msg: `{"type":"down","id":0,"x":0.25,"y":0.5,"w":720,"h":1280}`, want: image.Pt(180, 640)},
+		// 没有解码尺寸时使用消息头中请求的推流尺寸
+		{name: "header", msg: `{"type":"down","id":0,"x":0.25,"y":0.5}`, want: image.Pt(180, 640)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, fake := rotatedServer(t)
+			view := &frameView{}
+			// 请求的推流尺寸为物理尺寸，实际编码的画面可能随屏幕旋转
+			view.set(Message{Kind: KindH264, Rotation: device.Rotation90, Width: 720, Height: 1280})
+			c := newController(s, view)
+			ctx := context.Background()
+			if err := c.handle(ctx, []byte(tt.msg)); err != nil {
+				t.Fatal(err)
+			}
+			c.release(ctx)
+
+			var downs []image.Point
+			for _, e := range fake.Events() {
+				if e.Kind == sim.EventTouchDown {
+					downs = append(downs, image.Pt(e.X, e.Y))
+				}
+			}
+			if len(downs) != 1 || downs[0] != tt.want {
+				t.Fatalf("touch down at %v, want [%v]", downs, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package mirror
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"mytrpc/device"
+)
+
+// Kind 为画面数据的类型
+type Kind uint8
+
+const (
+	// KindJPEG 为一张完整的 JPEG 截图
+	KindJPEG Kind = 1
+	// KindH264 为一帧 H.264 Annex-B 码流
+	KindH264 Kind = 2
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindJPEG:
+		return "jpeg"
+	case KindH264:
+		return "h264"
+	}
+	return "unknown"
+}
+
+// HeaderSize 为 WebSocket 二进制消息头的长度
+const HeaderSize = 16
+
+// Message 是发给观看者的一帧画面
+type Message struct {
+	Kind Kind
+	// Key 表示可以从这一帧开始显示，JPEG 总是为 true
+	Key bool
+	// Config 表示包含 SPS/PPS
+	Config   bool
+	Rotation device.Rotation
+	// Width、Height 为画面尺寸
+	Width, Height int
+	PTS           time.Duration
+	Data          []byte
+}
+
+// MarshalBinary 编码为 WebSocket 二进制消息：16 字节大端序消息头后接数据。
+// 消息头依次为类型(1)、标志(1，bit0 关键帧、bit1 参数集)、方向(1，0-3)、保留(1)、
+// 宽(2)、高(2)、PTS 微秒(8)
+func (m Message) MarshalBinary() ([]byte, error) {
+	b := make([]byte, HeaderSize, HeaderSize+len(m.Data))
+	b[0] = byte(m.Kind)
+	if m.Key {
+		b[1] |= 1
+	}
+	if m.Config {
+		b[1] |= 2
+	}
+	b[2] = byte(m.Rotation)
+	binary.BigEndian.PutUint16(b[4:], uint16(m.Width))
+	binary.BigEndian.PutUint16(b[6:], uint16(m.Height))
+	binary.BigEndian.PutUint64(b[8:], uint64(m.PTS/time.Microsecond))
+	return append(b, m.Data...), nil
+}
+
+const (
+	// viewerBuffer 为每个观看者缓冲的帧数，也是新观看者补发的最大 GOP 长度
+	viewerBuffer = 120
+	// idleLinger 为最后一个观看者离开后采集循环继续运行的时间，刷新页面时不必重新推流
+	idleLinger = 3 * time.Second
+)
+
+// errLoopEnded 表示采集循环未出错但提前结束
+var errLoopEnded = errors.New("mirror: capture loop ended (采集已结束)")
+
+// captureFunc 为采集循环，持续调用 publish 直到 ctx 取消或出错
+type captureFunc func(ctx context.Context, publish func(Message)) error
+
+// hub 在有观看者时运行一个采集循环，把画面分发给所有观看者
+type hub struct {
+	parent  context.Context
+	capture captureFunc
+	// linger 为最后一个观看者离开后继续采集的时间，默认 idleLinger
+	linger time.Duration
+
+	mu   sync.Mutex
+	cur  *session
+	idle *time.Timer
+	// prev 为上一个采集循环的结束信号
+	prev chan struct{}
+}
+
+// session 为一次采集循环，循环结束后由新的 session 代替
+type session struct {
+	cancel  context.CancelFunc
+	done    chan struct{}
+	viewers map[*viewer]struct{}
+
+	// H.264 需要从关键帧开始解码，缓存当前 GOP 补发给新观看者
+	gop      []Message
+	overflow bool
+	last     *Message
+}
+
+// viewer 为一个观看者，done 关闭后 err 为采集循环结束的原因
+type viewer struct {
+	sess    *session
+	ch      chan Message
+	done    chan struct{}
+	err     error
+	waitKey bool
+}
+
+func newHub(parent context.Context, capture captureFunc) *hub {
+	return &hub{parent: parent, capture: capture, linger: idleLinger}
+}
+
+// subscribe 加入观看者，没有运行中的采集循环时启动
+func (h *hub) subscribe() *viewer {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.idle != nil {
+		h.idle.Stop()
+		h.idle = nil
+	}
+	if h.cur == nil {
+		h.start()
+	}
+	s := h.cur
+	v := &viewer{sess: s, ch: make(chan Message, viewerBuffer), done: make(chan struct{})}
+	switch {
+	case s.last != nil:
+		v.ch <- *s.last
+	case s.overflow:
+		// GOP 过长未能完整缓存，只补发参数集并等待下一个关键帧
+		for _, m := range s.gop {
+			if m.Config && !m.Key {
+				v.ch <- m
+			}
+		}
+		v.waitKey = true
+	default:
+		for _, m := range s.gop {
+			v.ch <- m
+		}
+	}
+	s.viewers[v] = struct{}{}
+	return v
+}
+
+// start 启动新的采集循环，等待上一个循环完全结束后再开始，避免两次推流交叠。调用时持有 mu
+func (h *hub) start() {
+	ctx, cancel := context.WithCancel(h.parent)
+	s := &session{cancel: cancel, done: make(chan struct{}), viewers: make(map[*viewer]struct{})}
+	prev := h.prev
+	h.cur = s
+	go func() {
+		defer close(s.done)
+		if prev != nil {
+			<-prev
+		}
+		err := h.capture(ctx, func(m Message) { h.publish(s, m) })
+		if err == nil && ctx.Err() == nil {
+			err = errLoopEnded
+		}
+		cancel()
+		h.stopped(s, err)
+	}()
+}
+
+// unsubscribe 移除观看者，没有观看者后经过 linger 停止采集
+func (h *hub) unsubscribe(v *viewer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := v.sess
+	delete(s.viewers, v)
+	if h.cur != s || len(s.viewers) > 0 || h.idle != nil {
+		return
+	}
+	h.idle = time.AfterFunc(h.linger, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.cur == s && len(s.viewers) == 0 {
+			s.cancel()
+			h.cur, h.prev = nil, s.done
+		}
+		h.idle = nil
+	})
+}
+
+// stopped 在采集循环结束后通知所有观看者
+func (h *hub) stopped(s *session, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cur == s {
+		h.cur, h.prev = nil, s.done
+	}
+	if err == nil {
+		err = context.Canceled
+	}
+	for v := range s.viewers {
+		v.err = err
+		close(v.done)
+		delete(s.viewers, v)
+	}
+}
+
+// viewers 返回当前观看者数量
+func (h *hub) viewers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cur == nil {
+		return 0
+	}
+	return len(h.cur.viewers)
+}
+
+// publish 缓存并分发一帧，观看者处理不及时时丢帧：JPEG 丢弃最旧的帧，
+// H.264 丢弃后续帧直到下一个关键帧
+func (h *hub) publish(s *session, m Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if m.Kind == KindJPEG {
+		s.last = &m
+		for v := range s.viewers {
+			for !v.send(m) {
+				select {
+				case <-v.ch:
+				default:
+				}
+			}
+		}
+		return
+	}
+
+	switch {
+	case m.Config && !m.Key:
+		s.gop, s.overflow = append(s.gop[:0], m), false
+	case m.Key:
+		if len(s.gop) == 0 || s.gop[len(s.gop)-1].Key || !s.gop[len(s.gop)-1].Config {
+			s.gop = s.gop[:0]
+		} else {
+			s.gop = append(s.gop[:0], s.gop[len(s.gop)-1])
+		}
+		s.gop, s.overflow = append(s.gop, m), false
+	case len(s.gop) > 0 && !s.overflow:
+		if len(s.gop) < viewerBuffer {
+			s.gop = append(s.gop, m)
+		} else {
+			s.overflow = true
+		}
+	}
+
+	for v := range s.viewers {
+		if v.waitKey {
+			if !m.Key && !m.Config {
+				continue
+			}
+			v.waitKey = !m.Key
+		}
+		if v.send(m) {
+			continue
+		}
+		if !m.Key {
+			v.waitKey = true
+			continue
+		}
+		for len(v.ch) > 0 {
+			<-v.ch
+		}
+		v.send(m)
+	}
+}
+
+func (v *viewer) send(m Message) bool {
+	select {
+	case v.ch <- m:
+		return true
+	default:
+		return false
+	}
+}
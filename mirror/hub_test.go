@@ -0,0 +1,293 @@
+package mirror
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSource 为测试用的采集循环，push 在测试的 goroutine 中同步发布一帧
+type fakeSource struct {
+	mu      sync.Mutex
+	publish func(Message)
+	ready   chan struct{}
+	fail    chan error
+	starts  int
+	stops   int
+}
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{ready: make(chan struct{}, 10), fail: make(chan error, 1)}
+}
+
+func (f *fakeSource) capture(ctx context.Context, publish func(Message)) error {
+	f.mu.Lock()
+	f.starts++
+	f.publish = publish
+	f.mu.Unlock()
+	f.ready <- struct{}{}
+	defer func() {
+		f.mu.Lock()
+		f.stops++
+		f.publish = nil
+		f.mu.Unlock()
+	}()
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-f.fail:
+		return err
+	}
+}
+
+// waitReady 等待采集循环启动
+func (f *fakeSource) waitReady(t *testing.T) {
+	t.Helper()
+	select {
+	case <-f.ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("capture loop not started")
+	}
+}
+
+func (f *fakeSource) push(t *testing.T, msgs ...Message) {
+	t.Helper()
+	f.mu.Lock()
+	publish := f.publish
+	f.mu.Unlock()
+	if publish == nil {
+		t.Fatal("capture loop not running")
+	}
+	for _, m := range msgs {
+		publish(m)
+	}
+}
+
+func (f *fakeSource) counts() (starts, stops int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.starts, f.stops
+}
+
+func newTestHub(t *testing.T) (*hub, *fakeSource) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	src := newFakeSource()
+	return newHub(ctx, src.capture), src
+}
+
+// frame 生成 H.264 帧，PTS 用作编号
+func frame(n int, key, config bool) Message {
+	return Message{Kind: KindH264, Key: key, Config: config, PTS: time.Duration(n)}
+}
+
+// pending 取出观看者通道中已有的帧编号
+func pending(v *viewer) []int {
+	var out []int
+	for {
+		select {
+		case m := <-v.ch:
+			out = append(out, int(m.PTS))
+		default:
+			return out
+		}
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestHubLateSubscriberGetsGOP(t *testing.T) {
+	h, src := newTestHub(t)
+	first := h.subscribe()
+	src.waitReady(t)
+
+	// 参数集、关键帧和之后的帧组成当前 GOP
+	src.push(t, frame(1, false, false), frame(2, false, true), frame(3, true, false), frame(4, false, false), frame(5, false, false))
+	late := h.subscribe()
+	if got, want := pending(late), []int{2, 3, 4, 5}; !equalInts(got, want) {
+		t.Fatalf("late subscriber got %v, want the GOP %v", got, want)
+	}
+	if got, want := pending(first), []int{1, 2, 3, 4, 5}; !equalInts(got, want) {
+		t.Fatalf("first subscriber got %v, want %v", got, want)
+	}
+
+	// 新的关键帧开始新的 GOP，关键帧之前紧邻的参数集保留
+	src.push(t, frame(6, false, true), frame(7, true, false), frame(8, false, false))
+	if got, want := pending(h.subscribe()), []int{6, 7, 8}; !equalInts(got, want) {
+		t.Fatalf("subscriber after a new GOP got %v, want %v", got, want)
+	}
+	src.push(t, frame(9, true, true))
+	if got, want := pending(h.subscribe()), []int{9}; !equalInts(got, want) {
+		t.Fatalf("subscriber after a key frame with parameter sets got %v, want %v", got, want)
+	}
+	if starts, _ := src.counts(); starts != 1 {
+		t.Fatalf("capture started %d times, want 1", starts)
+	}
+}
+
+func TestHubLongGOP(t *testing.T) {
+	h, src := newTestHub(t)
+	h.subscribe()
+	src.waitReady(t)
+	src.push(t, frame(1, false, true), frame(2, true, false))
+	for i := 0; i < viewerBuffer; i++ {
+		src.push(t, frame(100+i, false, false))
+	}
+	// GOP 超过缓冲长度时只补发参数集，等待下一个关键帧
+	late := h.subscribe()
+	if got, want := pending(late), []int{1}; !equalInts(got, want) {
+		t.Fatalf("late subscriber got %v, want only the parameter sets", got)
+	}
+	src.push(t, frame(500, false, false), frame(501, true, false), frame(502, false, false))
+	if got, want := pending(late), []int{501, 502}; !equalInts(got, want) {
+		t.Fatalf("late subscriber got %v after the next key frame, want %v", got, want)
+	}
+}
+
+func TestHubLateSubscriberGetsLastJPEG(t *testing.T) {
+	h, src := newTestHub(t)
+	h.subscribe()
+	src.waitReady(t)
+	for i := 1; i <= 3; i++ {
+		src.push(t, Message{Kind: KindJPEG, Key: true, PTS: time.Duration(i)})
+	}
+	if got, want := pending(h.subscribe()), []int{3}; !equalInts(got, want) {
+		t.Fatalf("late subscriber got %v, want the last frame", got)
+	}
+}
+
+func TestHubSlowViewerWaitsForKeyFrame(t *testing.T) {
+	h, src := newTestHub(t)
+	slow := h.subscribe()
+	src.waitReady(t)
+
+	src.push(t, frame(0, true, false))
+	for i := 1; i < viewerBuffer; i++ {
+		src.push(t, frame(i, false, false))
+	}
+	// 缓冲区已满，之后的非关键帧都被丢弃
+	src.push(t, frame(1000, false, false), frame(1001, false, false))
+	// 关键帧到达时缓冲区仍满则清空积压，从关键帧开始继续
+	fast := h.subscribe()
+	pending(fast)
+	src.push(t, frame(2000, true, false), frame(2001, false, false))
+	if got, want := pending(slow), []int{2000, 2001}; !equalInts(got, want) {
+		t.Fatalf("slow viewer got %v, want %v", got, want)
+	}
+	if got, want := pending(fast), []int{2000, 2001}; !equalInts(got, want) {
+		t.Fatalf("fast viewer got %v, want %v", got, want)
+	}
+}
+
+func TestHubSlowViewerPartialBacklog(t *testing.T) {
+	h, src := newTestHub(t)
+	slow := h.subscribe()
+	src.waitReady(t)
+	src.push(t, frame(0, true, false))
+	for i := 1; i <= viewerBuffer; i++ {
+		src.push(t, frame(i, false, false))
+	}
+	// 丢帧后即使缓冲区空出来，非关键帧仍被丢弃；关键帧直接追加，不丢弃已缓冲的帧
+	for i := 0; i < 10; i++ {
+		<-slow.ch
+	}
+	src.push(t, frame(999, false, false), frame(1000, true, false))
+	got := pending(slow)
+	if len(got) != viewerBuffer-10+1 || got[0] != 10 || got[len(got)-2] != viewerBuffer-1 || got[len(got)-1] != 1000 {
+		t.Fatalf("slow viewer got %d frames %v...%v, want 10..%d then the key frame", len(got), got[:2], got[len(got)-2:], viewerBuffer-1)
+	}
+}
+
+func TestHubSlowJPEGViewerDropsOldest(t *testing.T) {
+	h, src := newTestHub(t)
+	slow := h.subscribe()
+	src.waitReady(t)
+	for i := 0; i < viewerBuffer+5; i++ {
+		src.push(t, Message{Kind: KindJPEG, Key: true, PTS: time.Duration(i)})
+	}
+	got := pending(slow)
+	if len(got) != viewerBuffer || got[0] != 5 || got[len(got)-1] != viewerBuffer+4 {
+		t.Fatalf("slow viewer got %d frames from %d to %d, want the newest %d", len(got), got[0], got[len(got)-1], viewerBuffer)
+	}
+}
+
+func TestHubLinger(t *testing.T) {
+	h, src := newTestHub(t)
+	h.linger = 200 * time.Millisecond
+	v := h.subscribe()
+	src.waitReady(t)
+
+	// 在 linger 内重新订阅时沿用原来的采集循环和缓存
+	h.unsubscribe(v)
+	time.Sleep(10 * time.Millisecond)
+	src.push(t, frame(1, true, false))
+	v = h.subscribe()
+	if starts, stops := src.counts(); starts != 1 || stops != 0 {
+		t.Fatalf("starts %d, stops %d after a quick resubscribe", starts, stops)
+	}
+	if got := pending(v); !equalInts(got, []int{1}) {
+		t.Fatalf("resubscribed viewer got %v, want the cached GOP", got)
+	}
+
+	h.unsubscribe(v)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, stops := src.counts(); stops == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("capture still running after the linger period")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := h.viewers(); n != 0 {
+		t.Fatalf("viewers = %d", n)
+	}
+
+	// 之后的订阅启动新的采集循环，不再收到旧的缓存
+	v = h.subscribe()
+	src.waitReady(t)
+	if starts, _ := src.counts(); starts != 2 {
+		t.Fatalf("starts = %d, want 2", starts)
+	}
+	if got := pending(v); len(got) != 0 {
+		t.Fatalf("new session replayed %v", got)
+	}
+}
+
+func TestHubCaptureError(t *testing.T) {
+	h, src := newTestHub(t)
+	a, b := h.subscribe(), h.subscribe()
+	src.waitReady(t)
+	boom := errors.New("boom")
+	src.fail <- boom
+	for _, v := range []*viewer{a, b} {
+		select {
+		case <-v.done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("viewer not notified")
+		}
+		if !errors.Is(v.err, boom) {
+			t.Fatalf("viewer err = %v, want boom", v.err)
+		}
+	}
+	if n := h.viewers(); n != 0 {
+		t.Fatalf("viewers = %d after the loop ended", n)
+	}
+	// 出错后新的观看者重新启动采集
+	h.subscribe()
+	src.waitReady(t)
+}
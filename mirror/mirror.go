@@ -0,0 +1,376 @@
+// Package mirror 通过 HTTP 把设备画面镜像到浏览器。
+//
+// 每台设备对应一个 Server，提供以下路径：
+//
+//	/              内置的观看页面
+//	/mjpeg         multipart MJPEG 流，可直接用于 <img> 标签
+//	/snapshot.jpg  当前画面的一张 JPEG
+//	/ws            WebSocket，每条二进制消息为一帧画面，格式见 Message.MarshalBinary
+//
+// 同一台设备的所有观看者共用一个采集循环，第一个观看者到来时启动，最后一个离开后停止。
+// WebSocket 优先使用原生视频流发送 H.264，视频流不可用（缺少导出函数、其他设备正在推流等）
+// 时回退为定时 takeCaptrueCompress 截图发送 JPEG；MJPEG 始终使用截图。
+//
+// 开启 Options.Control 后，观看页面上的鼠标、触摸和键盘操作通过同一个 WebSocket 发回，
+// 按画面尺寸和屏幕方向映射为设备坐标后执行 TouchDown/TouchMove/TouchUp、KeyPress 和 SendText。
+// /ws 拒绝来源与 Host 不同且不在 Options.AllowedOrigins 中的浏览器连接，
+// 设置 Options.Authorize 后只有通过鉴权的连接可以执行操作。
+package mirror
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"mytrpc/device"
+)
+
+// Options 为镜像配置
+type Options struct {
+	// Interval 为截图间隔，默认 200ms
+	Interval time.Duration
+	// Quality 为截图的 JPEG 质量，默认 70
+	Quality int
+	// Video 为视频流配置，Drop 固定为 device.DropUntilKeyframe
+	Video device.VideoStreamOptions
+	// NoVideo 禁用视频流，WebSocket 也只发送 JPEG
+	NoVideo bool
+	// VideoRetry 为视频流失败后重新尝试前的等待时间，默认 30s
+	VideoRetry time.Duration
+	// Logger 记录采集和操作错误，默认 slog.Default()
+	Logger *slog.Logger
+	// Control 允许观看者通过页面操作设备，默认只能观看
+	Control bool
+	// AllowedOrigins 为允许连接 /ws 的其他来源，如 "https://console.example.com" 或
+	// "console.example.com:8443"，"*" 允许任意来源。浏览器的 Origin 与请求的 Host 不同且不在
+	// 此列表中时拒绝握手，防止其他网站的页面借观看者的浏览器连接并操作设备
+	AllowedOrigins []string
+	// Authorize 在 WebSocket 握手时检查请求（如 Cookie、令牌），返回错误时该连接只能观看，
+	// 不执行操作消息；为 nil 时不检查
+	Authorize func(r *http.Request) error
+}
+
+func (o Options) withDefaults() Options {
+	if o.Interval <= 0 {
+		o.Interval = 200 * time.Millisecond
+	}
+	if o.Quality <= 0 {
+		o.Quality = 70
+	}
+	if o.VideoRetry <= 0 {
+		o.VideoRetry = 30 * time.Second
+	}
+	if o.Logger == nil {
+		o.Logger = slog.Default()
+	}
+	o.Video.Drop = device.DropUntilKeyframe
+	return o
+}
+
+//go:embed index.html
+var indexHTML []byte
+
+// Server 把一台设备的画面提供给多个观看者，实现 http.Handler
+type Server struct {
+	dev  *device.Device
+	opts Options
+	// normalized 为 dev 的归一化坐标视图，用于映射与屏幕显示方向一致的画面
+	normalized *device.Device
+	ctx        context.Context
+	cancel     context.CancelFunc
+	mux        *http.ServeMux
+
+	jpeg  *hub
+	video *hub
+
+	mu           sync.Mutex
+	noVideoUntil time.Time
+	display      *device.DisplayInfo
+}
+
+// New 创建镜像服务，采集在有观看者时才开始
+func New(dev *device.Device, opts Options) *Server {
+	opts = opts.withDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
+		dev:        dev,
+		opts:       opts,
+		normalized: dev.Normalized(),
+		ctx:        ctx,
+		cancel:     cancel,
+		mux:        http.NewServeMux(),
+	}
+	s.jpeg = newHub(ctx, s.captureJPEG)
+	s.video = newHub(ctx, s.captureVideo)
+
+	s.mux.HandleFunc("GET /{$}", s.handleIndex)
+	s.mux.HandleFunc("GET /mjpeg", s.handleMJPEG)
+	s.mux.HandleFunc("GET /snapshot.jpg", s.handleSnapshot)
+	s.mux.HandleFunc("GET /ws", s.handleWS)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Viewers 返回当前观看者数量
+func (s *Server) Viewers() int {
+	return s.jpeg.viewers() + s.video.viewers()
+}
+
+// Close 停止采集并断开所有观看者，不关闭设备
+func (s *Server) Close() error {
+	s.cancel()
+	return nil
+}
+
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(indexHTML)
+}
+
+func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	data, err := s.screenshot(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
+
+const mjpegBoundary = "mytrpcframe"
+
+func (s *Server) handleMJPEG(w http.ResponseWriter, r *http.Request) {
+	v := s.jpeg.subscribe()
+	defer s.jpeg.unsubscribe(v)
+
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+mjpegBoundary)
+	w.Header().Set("Cache-Control", "no-store")
+	rc := http.NewResponseController(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-v.done:
+			return
+		case m := <-v.ch:
+			rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+			_, err := fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", mjpegBoundary, len(m.Data))
+			if err == nil {
+				_, err = w.Write(m.Data)
+			}
+			if err == nil {
+				_, err = io.WriteString(w, "\r\n")
+			}
+			if err == nil {
+				err = rc.Flush()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleWS 发送画面并接收操作，?mode=jpeg 时只发送截图
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	if !checkOrigin(r, s.opts.AllowedOrigins) {
+		s.opts.Logger.Warn("拒绝其他来源的 WebSocket 连接", "origin", r.Header.Get("Origin"), "host", r.Host)
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	denied := s.authorize(r)
+	conn, err := upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if err := s.writeJSON(conn, serverMessage{Type: "hello", Control: denied == nil}); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	view := &frameView{}
+	go s.readControl(ctx, cancel, conn, view, denied)
+
+	if r.URL.Query().Get("mode") != "jpeg" && s.videoAllowed() {
+		err := s.stream(ctx, conn, s.video, view)
+		if ctx.Err() != nil || s.ctx.Err() != nil {
+			return
+		}
+		s.opts.Logger.Warn("视频流不可用，改用截图", "err", err)
+		s.videoFailed()
+	}
+	s.stream(ctx, conn, s.jpeg, view)
+}
+
+var (
+	errControlDisabled = errors.New("control disabled (未开启操作)")
+	errUnauthorized    = errors.New("unauthorized (无操作权限)")
+)
+
+// authorize 检查连接是否可以执行操作，不可以时返回发给页面的错误
+func (s *Server) authorize(r *http.Request) error {
+	if !s.opts.Control {
+		return errControlDisabled
+	}
+	if s.opts.Authorize != nil {
+		if err := s.opts.Authorize(r); err != nil {
+			s.opts.Logger.Warn("操作鉴权失败，连接只能观看", "remote", r.RemoteAddr, "err", err)
+			return errUnauthorized
+		}
+	}
+	return nil
+}
+
+// readControl 读取页面发来的消息直到连接断开，denied 为 nil 时按顺序执行操作，
+// 断开时抬起仍按下的手指
+func (s *Server) readControl(ctx context.Context, cancel context.CancelFunc, conn *wsConn, view *frameView, denied error) {
+	defer cancel()
+	c := newController(s, view)
+	defer c.release(context.Background())
+	for {
+		op, data, err := conn.readMessage()
+		if err != nil {
+			return
+		}
+		if op != opText {
+			continue
+		}
+		if denied != nil {
+			s.writeJSON(conn, serverMessage{Type: "error", Message: denied.Error()})
+			continue
+		}
+		if err := c.handle(ctx, data); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.opts.Logger.Warn("执行操作失败", "err", err)
+			s.writeJSON(conn, serverMessage{Type: "error", Message: err.Error()})
+		}
+	}
+}
+
+func (s *Server) writeJSON(conn *wsConn, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return conn.writeMessage(opText, data)
+}
+
+// stream 把 h 的画面发送到 conn，直到 ctx 取消、发送失败或采集循环结束
+func (s *Server) stream(ctx context.Context, conn *wsConn, h *hub, view *frameView) error {
+	v := h.subscribe()
+	defer h.unsubscribe(v)
+	for {
+		select {
+		case <-ctx.Done():
+			conn.closeWith(1001)
+			return ctx.Err()
+		case <-v.done:
+			return v.err
+		case m := <-v.ch:
+			data, _ := m.MarshalBinary()
+			if err := conn.writeMessage(opBinary, data); err != nil {
+				return err
+			}
+			view.set(m)
+		}
+	}
+}
+
+func (s *Server) videoAllowed() bool {
+	if s.opts.NoVideo {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Now().After(s.noVideoUntil)
+}
+
+func (s *Server) videoFailed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.noVideoUntil = time.Now().Add(s.opts.VideoRetry)
+}
+
+func (s *Server) screenshot(ctx context.Context) ([]byte, error) {
+	return s.dev.TakeScreenshotCtx(ctx, device.ScreenshotOptions{Format: device.FormatJPEG, Quality: s.opts.Quality})
+}
+
+// captureJPEG 定时截图，画面未变化时不发送
+func (s *Server) captureJPEG(ctx context.Context, publish func(Message)) error {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+	start := time.Now()
+	var (
+		last    []byte
+		failing bool
+	)
+	for {
+		data, err := s.screenshot(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case err != nil:
+			if !failing {
+				s.opts.Logger.Warn("截图失败", "err", err)
+			}
+			failing = true
+		case !bytes.Equal(data, last):
+			failing = false
+			last = data
+			m := Message{Kind: KindJPEG, Key: true, PTS: time.Since(start), Data: data}
+			if cfg, err := jpeg.DecodeConfig(bytes.NewReader(data)); err == nil {
+				m.Width, m.Height = cfg.Width, cfg.Height
+			}
+			publish(m)
+		default:
+			failing = false
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// captureVideo 转发原生视频流
+func (s *Server) captureVideo(ctx context.Context, publish func(Message)) error {
+	stream, err := s.dev.StartVideoStream(ctx, s.opts.Video)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	for f := range stream.Frames {
+		publish(Message{
+			Kind:     KindH264,
+			Key:      f.Type == device.FrameKey,
+			Config:   f.Config,
+			Rotation: f.Rotation,
+			Width:    f.Width,
+			Height:   f.Height,
+			PTS:      f.PTS,
+			Data:     f.Data,
+		})
+	}
+	if ctx.Err() == nil {
+		return errors.New("mirror: video stream closed (视频流已关闭)")
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package mirror_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"mytrpc/mirror"
+)
+
+func solidJPEG(t *testing.T, c color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 36, 64))
+	for i := 0; i < len(img.Pix); i += 4 {
+		r, g, b, a := c.RGBA()
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = byte(r>>8), byte(g>>8), byte(b>>8), byte(a>>8)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestMJPEG(t *testing.T) {
+	ts, fake := newServer(t, mirror.Options{Interval: 10 * time.Millisecond})
+	red, blue := solidJPEG(t, color.RGBA{R: 255, A: 255}), solidJPEG(t, color.RGBA{B: 255, A: 255})
+	fake.SetScreenshot(red)
+
+	resp, err := http.Get(ts.URL + "/mjpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/x-mixed-replace" || params["boundary"] == "" {
+		t.Fatalf("Content-Type = %q, err %v", resp.Header.Get("Content-Type"), err)
+	}
+	if cc := resp.Header.Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", cc)
+	}
+
+	parts := multipart.NewReader(resp.Body, params["boundary"])
+	next := func() []byte {
+		t.Helper()
+		part, err := parts.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ct := part.Header.Get("Content-Type"); ct != "image/jpeg" {
+			t.Fatalf("part Content-Type = %q", ct)
+		}
+		// 下一个分隔符要等到下一帧才发送，按 Content-Length 读取
+		n, err := strconv.Atoi(part.Header.Get("Content-Length"))
+		if err != nil {
+			t.Fatalf("part Content-Length: %v", err)
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(part, data); err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	if data := next(); !bytes.Equal(data, red) {
+		t.Fatalf("first part is %d bytes, want the red screenshot", len(data))
+	}
+	// 画面不变时不重复发送，变化后发送新的截图
+	fake.SetScreenshot(blue)
+	if data := next(); !bytes.Equal(data, blue) {
+		t.Fatalf("second part is %d bytes, want the blue screenshot", len(data))
+	}
+}
@@ -0,0 +1,211 @@
+package mirror
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 最小的 WebSocket（RFC 6455）服务端实现，只支持本包需要的功能：
+// 不协商扩展和子协议，服务端发送不分片的消息，客户端消息最大 maxMessageSize。
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+
+	wsGUID         = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	maxMessageSize = 1 << 20
+	writeTimeout   = 10 * time.Second
+)
+
+var errBadHandshake = errors.New("mirror: not a websocket handshake (不是WebSocket握手请求)")
+
+// wsConn 为一个 WebSocket 连接，写入可并发调用，读取只能在一个 goroutine 中进行
+type wsConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	wmu sync.Mutex
+}
+
+// upgrade 完成握手并接管连接
+func upgrade(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet ||
+		!headerHasToken(r.Header, "Connection", "upgrade") ||
+		!headerHasToken(r.Header, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" || key == "" {
+		http.Error(w, "websocket handshake required", http.StatusBadRequest)
+		return nil, errBadHandshake
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("mirror: response does not support hijacking (连接不支持接管)")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("mirror: hijack failed (接管连接失败): %w", err)
+	}
+
+	sum := sha1.Sum([]byte(key + wsGUID))
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n"
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := conn.Write([]byte(resp)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("mirror: websocket handshake failed (WebSocket握手失败): %w", err)
+	}
+	return &wsConn{conn: conn, br: rw.Reader}, nil
+}
+
+// checkOrigin 报告握手请求的来源是否可以接受：没有 Origin（非浏览器客户端）、
+// Origin 的主机与请求的 Host 相同或在 allowed 中时可以接受，allowed 中的 "*" 允许任意来源。
+// allowed 的元素可以是完整的来源（https://console.example.com）或主机（console.example.com:8443）
+func checkOrigin(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	host := ""
+	if err == nil {
+		host = u.Host
+	}
+	for _, a := range allowed {
+		if a == "*" || strings.EqualFold(a, origin) || host != "" && strings.EqualFold(a, host) {
+			return true
+		}
+	}
+	return host != "" && strings.EqualFold(host, r.Host)
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeMessage 发送一条不分片的消息
+func (c *wsConn) writeMessage(op byte, data []byte) error {
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | op
+	switch n := len(data); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	bufs := net.Buffers{header, data}
+	_, err := bufs.WriteTo(c.conn)
+	return err
+}
+
+// readMessage 读取一条完整的文本或二进制消息，自动回复 ping 和 close，
+// 对方关闭连接时返回 io.EOF
+func (c *wsConn) readMessage() (byte, []byte, error) {
+	var (
+		msgOp byte
+		msg   []byte
+	)
+	for {
+		var head [2]byte
+		if _, err := io.ReadFull(c.br, head[:]); err != nil {
+			return 0, nil, err
+		}
+		fin, op := head[0]&0x80 != 0, head[0]&0x0f
+		masked := head[1]&0x80 != 0
+		n := uint64(head[1] & 0x7f)
+		switch n {
+		case 126:
+			var b [2]byte
+			if _, err := io.ReadFull(c.br, b[:]); err != nil {
+				return 0, nil, err
+			}
+			n = uint64(binary.BigEndian.Uint16(b[:]))
+		case 127:
+			var b [8]byte
+			if _, err := io.ReadFull(c.br, b[:]); err != nil {
+				return 0, nil, err
+			}
+			n = binary.BigEndian.Uint64(b[:])
+		}
+		if !masked {
+			return 0, nil, errors.New("mirror: unmasked client frame (客户端消息未加掩码)")
+		}
+		if n > maxMessageSize || uint64(len(msg))+n > maxMessageSize {
+			c.closeWith(1009)
+			return 0, nil, errors.New("mirror: websocket message too large (WebSocket消息过大)")
+		}
+		var mask [4]byte
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return 0, nil, err
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			return 0, nil, err
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+
+		switch op {
+		case opPing:
+			c.writeMessage(opPong, payload)
+			continue
+		case opPong:
+			continue
+		case opClose:
+			c.writeMessage(opClose, payload[:min(len(payload), 2)])
+			return 0, nil, io.EOF
+		case opText, opBinary:
+			msgOp, msg = op, payload
+		case opContinuation:
+			msg = append(msg, payload...)
+		default:
+			c.closeWith(1002)
+			return 0, nil, fmt.Errorf("mirror: unknown websocket opcode %#x (未知的WebSocket操作码)", op)
+		}
+		if fin {
+			return msgOp, msg, nil
+		}
+	}
+}
+
+// closeWith 发送带状态码的关闭消息
+func (c *wsConn) closeWith(code uint16) error {
+	return c.writeMessage(opClose, binary.BigEndian.AppendUint16(nil, code))
+}
+
+func (c *wsConn) Close() error {
+	return c.conn.Close()
+}
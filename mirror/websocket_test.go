@@ -0,0 +1,205 @@
+package mirror_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mytrpc/device"
+	"mytrpc/mirror"
+	"mytrpc/rpc"
+	"mytrpc/sim"
+)
+
+var quiet = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func newServer(t *testing.T, opts mirror.Options) (*httptest.Server, *sim.Device) {
+	t.Helper()
+	fake := sim.New()
+	if err := fake.AddScreenFile("home", "../nodes_ex.xml"); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.NewClientWithBackend(fake, rpc.WithLogger(quiet))
+	if err := client.Connect("sim", 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	opts.NoVideo, opts.Logger = true, quiet
+	srv := mirror.New(device.NewDevice(client), opts)
+	ts := httptest.NewServer(srv)
+	t.Cleanup(func() {
+		srv.Close()
+		ts.Close()
+	})
+	return ts, fake
+}
+
+// wsClient 为测试用的最小 WebSocket 客户端
+type wsClient struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// dial 发送握手请求，返回响应状态码，握手成功时返回连接
+func dial(t *testing.T, ts *httptest.Server, header http.Header) (int, *wsClient) {
+	t.Helper()
+	host := strings.TrimPrefix(ts.URL, "http://")
+	conn, err := net.Dial("tcp", host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/ws?mode=jpeg", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, &wsClient{conn: conn, br: br}
+}
+
+// sendText 发送一条加掩码的文本消息
+func (c *wsClient) sendText(t *testing.T, s string) {
+	t.Helper()
+	if len(s) > 125 {
+		t.Fatal("message too long for the test client")
+	}
+	frame := []byte{0x81, 0x80 | byte(len(s)), 1, 2, 3, 4}
+	for i := 0; i < len(s); i++ {
+		frame = append(frame, s[i]^frame[2+i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readJSON 跳过画面消息，读取下一条文本消息
+func (c *wsClient) readJSON(t *testing.T) map[string]any {
+	t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var head [2]byte
+		if _, err := io.ReadFull(c.br, head[:]); err != nil {
+			t.Fatal(err)
+		}
+		n := uint64(head[1] & 0x7f)
+		switch n {
+		case 126:
+			var b [2]byte
+			io.ReadFull(c.br, b[:])
+			n = uint64(binary.BigEndian.Uint16(b[:]))
+		case 127:
+			var b [8]byte
+			io.ReadFull(c.br, b[:])
+			n = binary.BigEndian.Uint64(b[:])
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(c.br, payload); err != nil {
+			t.Fatal(err)
+		}
+		if head[0]&0x0f != 0x1 {
+			continue
+		}
+		var msg map[string]any
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	ts, _ := newServer(t, mirror.Options{AllowedOrigins: []string{"https://console.example.com", "tools.example.com:8443"}})
+	tests := []struct {
+		name   string
+		origin string
+		want   int
+	}{
+		{name: "no origin", want: http.StatusSwitchingProtocols},
+		{name: "same host", origin: ts.URL, want: http.StatusSwitchingProtocols},
+		{name: "allowed origin", origin: "https://console.example.com", want: http.StatusSwitchingProtocols},
+		{name: "allowed host", origin: "https://tools.example.com:8443", want: http.StatusSwitchingProtocols},
+		{name: "other site", origin: "https://evil.example.com", want: http.StatusForbidden},
+		{name: "other port", origin: "https://console.example.com:8443", want: http.StatusForbidden},
+		{name: "null", origin: "null", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", tt.origin)
+			}
+			if got, _ := dial(t, ts, header); got != tt.want {
+				t.Fatalf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebSocketAuthorize(t *testing.T) {
+	ts, fake := newServer(t, mirror.Options{
+		Control: true,
+		Authorize: func(r *http.Request) error {
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				return errors.New("bad token")
+			}
+			return nil
+		},
+	})
+	keyPresses := func() int {
+		n := 0
+		for _, e := range fake.Events() {
+			if e.Kind == sim.EventKeyPress {
+				n++
+			}
+		}
+		return n
+	}
+
+	_, viewer := dial(t, ts, nil)
+	if hello := viewer.readJSON(t); hello["type"] != "hello" || hello["control"] == true {
+		t.Fatalf("unauthorized hello = %v, want control off", hello)
+	}
+	viewer.sendText(t, `{"type":"key","code":4}`)
+	if msg := viewer.readJSON(t); msg["type"] != "error" {
+		t.Fatalf("unauthorized control reply = %v, want an error", msg)
+	}
+	if n := keyPresses(); n != 0 {
+		t.Fatalf("unauthorized connection pressed %d keys", n)
+	}
+
+	_, operator := dial(t, ts, http.Header{"Authorization": {"Bearer secret"}})
+	if hello := operator.readJSON(t); hello["type"] != "hello" || hello["control"] != true {
+		t.Fatalf("authorized hello = %v, want control on", hello)
+	}
+	operator.sendText(t, `{"type":"key","code":4}`)
+	deadline := time.Now().Add(5 * time.Second)
+	for keyPresses() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("authorized key press not executed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
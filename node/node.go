@@ -2,123 +2,98 @@ package node
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
-	"unsafe"
+
+	"mytrpc/rpc"
 )
 
 // Node 的方法实现
-func (n *Node) Click() error {
-	if n.handle == 0 {
-		return errors.New("node handle is invalid")
-	}
 
-	proc, err := n.rpcClient.GetDLL().FindProc("clickNode")
+// Click 通过原生 clickNode 点击节点，由无障碍服务执行，节点被遮挡、不在屏幕内或已移动时
+// 仍作用于该节点。Release 后返回 rpc.ErrInvalidHandle，此时可用 Tap 按快照位置点击
+func (n *Node) Click() error {
+	handle, err := n.nativeHandle()
 	if err != nil {
-		return fmt.Errorf("查找clickNode函数失败: %v", err)
+		return err
 	}
-
-	ret, _, _ := proc.Call(n.handle)
-	if ret == 0 {
-		return errors.New("点击节点失败")
+	if err := n.rpcClient.Backend().ClickNode(handle); err != nil {
+		return fmt.Errorf("click node failed (点击节点失败): %w", err)
 	}
-
 	return nil
 }
 
-// GetBounds 获取节点位置
-func (n *Node) GetBounds() (Rect, error) {
-	var bounds Rect
-	if n.handle == 0 {
-		return bounds, errors.New("node handle is invalid")
-	}
-
-	proc, err := n.rpcClient.GetDLL().FindProc("getNodeNound")
+// LongClick 通过原生 longClickNode 长按节点，Release 后返回 rpc.ErrInvalidHandle
+func (n *Node) LongClick() error {
+	handle, err := n.nativeHandle()
 	if err != nil {
-		return bounds, fmt.Errorf("查找getNodeNound函数失败: %v", err)
+		return err
 	}
-
-	ret, _, _ := proc.Call(
-		n.handle,
-		uintptr(unsafe.Pointer(&bounds.Left)),
-		uintptr(unsafe.Pointer(&bounds.Top)),
-		uintptr(unsafe.Pointer(&bounds.Right)),
-		uintptr(unsafe.Pointer(&bounds.Bottom)),
-	)
-
-	if ret == 0 {
-		return bounds, errors.New("获取节点位置失败")
+	if err := n.rpcClient.Backend().LongClickNode(handle); err != nil {
+		return fmt.Errorf("long click node failed (长按节点失败): %w", err)
 	}
-
-	return bounds, nil
+	return nil
 }
 
-// GetText 获取节点文本
-func (n *Node) GetText() string {
-	if n.handle == 0 {
-		return ""
+// Tap 在快照中节点区域的中心执行一次触摸点击。与 Click 不同，点击的是屏幕坐标：
+// 节点被遮挡时点到遮挡物上，节点已移动时点到原来的位置。Release 后仍可使用
+func (n *Node) Tap() error {
+	if n.rpcClient == nil {
+		return fmt.Errorf("%w: node", rpc.ErrInvalidHandle)
 	}
-
-	proc, err := n.rpcClient.GetDLL().FindProc("getNodeText")
-	if err != nil {
-		return ""
+	x, y := n.bounds.Center()
+	if err := n.rpcClient.Backend().TouchClick(n.rpcClient.GetHandle(), 0, x, y); err != nil {
+		return fmt.Errorf("tap node failed (点击节点位置失败): %w", err)
 	}
+	return nil
+}
 
-	ptr, _, _ := proc.Call(n.handle)
-	if ptr == 0 {
-		return ""
+// Release 释放原生节点集合，之后 Click、LongClick 不可用，快照中的信息仍可读取。可以重复调用
+func (n *Node) Release() error {
+	n.mu.Lock()
+	nodes := n.nodes
+	n.nodes, n.handle = 0, 0
+	n.mu.Unlock()
+	if nodes == 0 || n.rpcClient == nil {
+		return nil
+	}
+	if err := n.rpcClient.Backend().FreeNodes(nodes); err != nil {
+		return fmt.Errorf("free nodes failed (释放节点失败): %w", err)
 	}
+	return nil
+}
 
-	// 安全地获取字符串
-	b := make([]byte, 0)
-	for i := 0; ; i++ {
-		c := *(*byte)(unsafe.Pointer(uintptr(ptr) + uintptr(i)))
-		if c == 0 {
-			break
-		}
-		b = append(b, c)
+// nativeHandle 返回尚未释放的原生节点句柄
+func (n *Node) nativeHandle() (uintptr, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.rpcClient == nil || n.handle == 0 {
+		return 0, fmt.Errorf("%w: node", rpc.ErrInvalidHandle)
 	}
-	text := string(b)
+	return n.handle, nil
+}
 
-	// 释放内存
-	if freeProc, err := n.rpcClient.GetDLL().FindProc("freeRpcPtr"); err == nil {
-		freeProc.Call(ptr)
+// GetBounds 获取节点位置
+func (n *Node) GetBounds() (Rect, error) {
+	if n.rpcClient == nil {
+		return Rect{}, fmt.Errorf("%w: node", rpc.ErrInvalidHandle)
 	}
+	return n.bounds, nil
+}
 
-	return text
+// GetText 获取节点文本
+func (n *Node) GetText() string {
+	return n.text
 }
 
 // GetJSON 获取节点的JSON表示
 func (n *Node) GetJSON() (string, error) {
-	if n.handle == 0 {
-		return "", errors.New("node handle is invalid")
+	if n.rpcClient == nil {
+		return "", fmt.Errorf("%w: node", rpc.ErrInvalidHandle)
 	}
-
-	proc, err := n.rpcClient.GetDLL().FindProc("getNodeJson")
-	if err != nil {
-		return "", fmt.Errorf("查找getNodeJson函数失败: %v", err)
-	}
-
-	ptr, _, _ := proc.Call(n.handle)
-	if ptr == 0 {
-		return "", errors.New("获取节点JSON失败")
-	}
-
-	// 安全地获取字符串
-	b := make([]byte, 0)
-	for i := 0; ; i++ {
-		c := *(*byte)(unsafe.Pointer(uintptr(ptr) + uintptr(i)))
-		if c == 0 {
-			break
-		}
-		b = append(b, c)
-	}
-	jsonStr := string(b)
-
-	// 释放内存
-	if freeProc, err := n.rpcClient.GetDLL().FindProc("freeRpcPtr"); err == nil {
-		freeProc.Call(ptr)
+	if n.jsonErr != nil {
+		return "", fmt.Errorf("get node json failed (获取节点JSON失败): %w", n.jsonErr)
 	}
+	jsonStr := n.json
 
 	// 格式化JSON
 	var data interface{}
@@ -0,0 +1,132 @@
+package node_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mytrpc/node"
+	"mytrpc/rpc"
+	"mytrpc/sim"
+)
+
+// countingDevice 统计返回的节点集合数和 freeNodes 的调用次数，empty 为 true 时集合中没有节点
+type countingDevice struct {
+	*sim.Device
+	finds, frees atomic.Int32
+	empty        atomic.Bool
+}
+
+func (d *countingDevice) FindNodes(selector uintptr, maxNode int, timeout int) (uintptr, error) {
+	nodes, err := d.Device.FindNodes(selector, maxNode, timeout)
+	if nodes != 0 {
+		d.finds.Add(1)
+	}
+	return nodes, err
+}
+
+func (d *countingDevice) GetNodesSize(nodes uintptr) (int, error) {
+	if d.empty.Load() {
+		return 0, nil
+	}
+	return d.Device.GetNodesSize(nodes)
+}
+
+func (d *countingDevice) FreeNodes(nodes uintptr) error {
+	d.frees.Add(1)
+	return d.Device.FreeNodes(nodes)
+}
+
+func connect(t *testing.T) (*rpc.Client, *countingDevice) {
+	t.Helper()
+	fake := &countingDevice{Device: sim.New()}
+	if err := fake.AddScreenFile("home", "../nodes_ex.xml"); err != nil {
+		t.Fatal(err)
+	}
+	client := rpc.NewClientWithBackend(fake)
+	if err := client.Connect("sim", 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, fake
+}
+
+func find(t *testing.T, client *rpc.Client, text string) *node.Node {
+	t.Helper()
+	sel := node.NewSelector(client)
+	if sel == nil {
+		t.Fatal("NewSelector returned nil")
+	}
+	sel.AddTextQuery(text)
+	n, err := sel.FindOne(time.Second)
+	if err != nil {
+		t.Fatalf("FindOne(%q): %v", text, err)
+	}
+	return n
+}
+
+func TestNodeClickUsesNativeNode(t *testing.T) {
+	client, fake := connect(t)
+	n := find(t, client, "Settings")
+	if n.GetText() != "Settings" {
+		t.Fatalf("text = %q, want Settings", n.GetText())
+	}
+	if got := fake.frees.Load(); got != 0 {
+		t.Fatalf("node list freed %d times before Release", got)
+	}
+
+	fake.ResetEvents()
+	if err := n.Click(); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.LongClick(); err != nil {
+		t.Fatal(err)
+	}
+	events := fake.Events()
+	if len(events) != 2 || events[0].Kind != sim.EventClickNode || events[0].Text != "Settings" ||
+		events[1].Kind != sim.EventClickNode || events[1].Duration == 0 {
+		t.Fatalf("events = %+v, want clickNode and longClickNode on Settings", events)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := n.Release(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := fake.frees.Load(); got != 1 {
+		t.Fatalf("node list freed %d times, want 1", got)
+	}
+	if err := n.Click(); !errors.Is(err, rpc.ErrInvalidHandle) {
+		t.Fatalf("Click after Release = %v, want ErrInvalidHandle", err)
+	}
+
+	// Tap 按快照位置点击，释放后仍可使用
+	bounds, err := n.GetBounds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.ResetEvents()
+	if err := n.Tap(); err != nil {
+		t.Fatal(err)
+	}
+	x, y := bounds.Center()
+	events = fake.Events()
+	if len(events) != 1 || events[0].Kind != sim.EventTouchClick || events[0].X != x || events[0].Y != y {
+		t.Fatalf("events = %+v, want a touch click at (%d,%d)", events, x, y)
+	}
+}
+
+func TestFindOneFreesEmptyNodeList(t *testing.T) {
+	client, fake := connect(t)
+	// 原生库可能返回空的节点集合，查找结果为空时集合在返回前释放
+	fake.empty.Store(true)
+	sel := node.NewSelector(client)
+	sel.AddTextQuery("Settings")
+	if _, err := sel.FindOne(50 * time.Millisecond); !errors.Is(err, rpc.ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+	if finds, frees := fake.finds.Load(), fake.frees.Load(); finds == 0 || frees != finds {
+		t.Fatalf("findNodes returned %d lists, freed %d", finds, frees)
+	}
+}
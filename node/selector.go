@@ -1,21 +1,15 @@
 package node
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"mytrpc/rpc"
 	"time"
-	"unsafe"
 )
 
 func NewSelector(client *rpc.Client) *Selector {
-	proc, err := client.GetDLL().FindProc("newSelector")
-	if err != nil {
-		return nil
-	}
-
-	handle, _, _ := proc.Call(uintptr(client.GetHandle()))
-	if handle == 0 {
+	handle, err := client.Backend().NewSelector(client.GetHandle())
+	if err != nil || handle == 0 {
 		return nil
 	}
 
@@ -25,58 +19,126 @@ func NewSelector(client *rpc.Client) *Selector {
 	}
 }
 
+// findSlice 为 FindOneCtx 单次调用 findNodes 的最长等待时间，决定取消的响应速度
+const findSlice = 500 * time.Millisecond
+
+// findPollInterval 为 findNodes 立即返回未找到时的重试间隔
+const findPollInterval = 100 * time.Millisecond
+
 func (s *Selector) FindOne(timeout time.Duration) (*Node, error) {
+	return s.FindOneCtx(context.Background(), timeout)
+}
+
+// FindOneCtx 在 timeout 内查找第一个匹配的节点，返回的节点使用完毕后须调用 Release；
+// 超时未找到时返回 rpc.ErrNotFound，ctx 取消时立即返回 ctx 的错误。
+// 等待被拆分为多次较短的 findNodes 调用，以便在调用之间响应取消。
+func (s *Selector) FindOneCtx(ctx context.Context, timeout time.Duration) (*Node, error) {
 	if s.handle == 0 {
-		return nil, errors.New("selector handle is invalid")
+		return nil, fmt.Errorf("%w: selector", rpc.ErrInvalidHandle)
 	}
 
-	// 使用 findNodes 函数查找节点
-	proc, err := s.rpcClient.GetDLL().FindProc("findNodes")
-	if err != nil {
-		return nil, fmt.Errorf("查找findNodes函数失败: %v", err)
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		wait := time.Until(deadline)
+		if wait > findSlice {
+			wait = findSlice
+		}
+		if wait < 0 {
+			wait = 0
+		}
+
+		start := time.Now()
+		node, err := s.findOnce(wait)
+		if err != nil || node != nil {
+			return node, err
+		}
+		if !time.Now().Before(deadline) {
+			return nil, rpc.ErrNotFound
+		}
+
+		// 原生调用未等满就返回时稍作等待，避免空转
+		if elapsed := time.Since(start); elapsed < findPollInterval {
+			if err := sleep(ctx, findPollInterval-elapsed); err != nil {
+				return nil, err
+			}
+		}
 	}
+}
+
+// findOnce 调用一次 findNodes 查找第一个节点
+func (s *Selector) findOnce(timeout time.Duration) (*Node, error) {
+	backend := s.rpcClient.Backend()
 
-	nodesHandle, _, _ := proc.Call(
+	// 使用 findNodes 函数查找节点
+	nodesHandle, err := backend.FindNodes(
 		s.handle,
-		uintptr(1), // maxNode = 1
-		uintptr(timeout.Milliseconds()),
+		1, // maxNode = 1
+		int(timeout.Milliseconds()),
 	)
+	if err != nil {
+		return nil, fmt.Errorf("find nodes failed (查找节点失败): %w", err)
+	}
 
 	if nodesHandle == 0 {
 		return nil, nil
 	}
+	// 节点句柄属于节点集合，集合交给返回的 Node 持有，其他情况在返回前释放
+	var node *Node
+	defer func() {
+		if node == nil {
+			backend.FreeNodes(nodesHandle)
+		}
+	}()
 
 	// 获取节点数量
-	proc, err = s.rpcClient.GetDLL().FindProc("getNodesSize")
+	size, err := backend.GetNodesSize(nodesHandle)
 	if err != nil {
-		return nil, fmt.Errorf("查找getNodesSize函数失败: %v", err)
+		return nil, fmt.Errorf("get nodes size failed (获取节点数量失败): %w", err)
 	}
-
-	size, _, _ := proc.Call(nodesHandle)
 	if size == 0 {
 		return nil, nil
 	}
 
 	// 获取第一个节点
-	proc, err = s.rpcClient.GetDLL().FindProc("getNodeByIndex")
+	nodeHandle, err := backend.GetNodeByIndex(nodesHandle, 0)
 	if err != nil {
-		return nil, fmt.Errorf("查找getNodeByIndex函数失败: %v", err)
+		return nil, fmt.Errorf("get node failed (获取节点失败): %w", err)
 	}
-
-	nodeHandle, _, _ := proc.Call(nodesHandle, 0)
 	if nodeHandle == 0 {
 		return nil, nil
 	}
 
-	// 清除查询条件
-	if proc, err := s.rpcClient.GetDLL().FindProc("clearSelector"); err == nil {
-		proc.Call(s.handle)
+	node, err = s.copyNode(backend, nodesHandle, nodeHandle)
+	if err != nil {
+		return nil, err
 	}
 
-	return &Node{
-		handle:    nodeHandle,
+	// 清除查询条件
+	backend.ClearSelector(s.handle)
+
+	return node, nil
+}
+
+// copyNode 读取节点的位置、文本和JSON快照，返回持有原生节点集合的 Node
+func (s *Selector) copyNode(backend rpc.Backend, nodes, handle uintptr) (*Node, error) {
+	left, top, right, bottom, err := backend.GetNodeBound(handle)
+	if err != nil {
+		return nil, fmt.Errorf("get node bounds failed (获取节点位置失败): %w", err)
+	}
+	node := &Node{
 		rpcClient: s.rpcClient,
-	}, nil
+		nodes:     nodes,
+		handle:    handle,
+		bounds:    Rect{Left: left, Top: top, Right: right, Bottom: bottom},
+	}
+	// 文本和JSON获取失败不影响查找结果，JSON 的错误留到 GetJSON 时返回
+	node.text, _ = backend.GetNodeText(handle)
+	node.json, node.jsonErr = backend.GetNodeJson(handle)
+	return node, nil
 }
 
 // AddTextQuery 添加文本查询条件
@@ -85,16 +147,19 @@ func (s *Selector) AddTextQuery(text string) {
 		return
 	}
 
-	proc, err := s.rpcClient.GetDLL().FindProc("TextContainWith")
-	if err != nil {
-		return
-	}
-
-	textBytes := []byte(text + "\x00")
-	proc.Call(
-		s.handle,
-		uintptr(unsafe.Pointer(&textBytes[0])),
-	)
+	s.rpcClient.Backend().AddStringQuery(s.handle, rpc.QueryTextContainWith, text)
 }
 
 // 其他查询条件方法...
+
+// sleep 等待 d 或直到 ctx 取消
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
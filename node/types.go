@@ -1,16 +1,34 @@
 package node
 
-import "mytrpc/rpc"
+import (
+	"sync"
+
+	"mytrpc/rpc"
+)
 
 // Rect 表示节点的位置信息
 type Rect struct {
 	Left, Top, Right, Bottom int
 }
 
-// Node 表示一个UI节点
+// Center 返回区域中心
+func (r Rect) Center() (x, y int) {
+	return (r.Left + r.Right) / 2, (r.Top + r.Bottom) / 2
+}
+
+// Node 表示一个UI节点。位置、文本和JSON为查找时的快照；原生节点句柄保留到 Release，
+// 期间 Click、LongClick 通过原生 clickNode/longClickNode 操作节点。使用完毕后须调用 Release。
 type Node struct {
-	handle    uintptr
 	rpcClient *rpc.Client
+
+	mu sync.Mutex
+	// nodes 为节点所属的原生节点集合，handle 为原生节点句柄，Release 后均为0
+	nodes, handle uintptr
+
+	bounds  Rect
+	text    string
+	json    string
+	jsonErr error
 }
 
 // Selector 用于查找节点
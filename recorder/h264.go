@@ -0,0 +1,80 @@
+package recorder
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"mytrpc/h264"
+)
+
+var startCode = []byte{0, 0, 0, 1}
+
+// H264Writer 把帧写为裸 H.264 Annex-B 码流（.h264），可直接用 ffplay 等播放。
+// 第一个关键帧之前的帧被丢弃，关键帧缺少参数集时补上最近一次收到的 SPS/PPS，
+// 保证文件可以从头解码。
+type H264Writer struct {
+	w        io.Writer
+	sps, pps []byte
+	started  bool
+	written  int64
+	err      error
+}
+
+// NewH264Writer 创建裸码流写入器
+func NewH264Writer(w io.Writer) *H264Writer {
+	return &H264Writer{w: w}
+}
+
+// WriteFrame 写入一帧 Annex-B 数据，裸码流不保存时间戳，pts 被忽略
+func (h *H264Writer) WriteFrame(data []byte, pts time.Duration) error {
+	if h.err != nil {
+		return h.err
+	}
+	au := h264.ParseAccessUnit(data)
+	if au.SPS != nil {
+		h.sps = append(h.sps[:0], au.SPS...)
+	}
+	if au.PPS != nil {
+		h.pps = append(h.pps[:0], au.PPS...)
+	}
+	if !h.started {
+		if !au.Key || h.sps == nil || h.pps == nil {
+			return nil
+		}
+		h.started = true
+		if au.SPS == nil || au.PPS == nil {
+			h.writeNAL(h.sps)
+			h.writeNAL(h.pps)
+		}
+	}
+	for _, nal := range au.NALUs {
+		h.writeNAL(nal)
+	}
+	return h.err
+}
+
+// writeNAL 统一使用 4 字节起始码写出
+func (h *H264Writer) writeNAL(nal []byte) {
+	if h.err != nil {
+		return
+	}
+	for _, p := range [][]byte{startCode, nal} {
+		n, err := h.w.Write(p)
+		h.written += int64(n)
+		if err != nil {
+			h.err = fmt.Errorf("recorder: write h264 failed (写入H264失败): %w", err)
+			return
+		}
+	}
+}
+
+// Size 返回已写出的字节数
+func (h *H264Writer) Size() int64 {
+	return h.written
+}
+
+// Close 不关闭底层的 io.Writer，仅返回之前的写入错误
+func (h *H264Writer) Close() error {
+	return h.err
+}
@@ -0,0 +1,375 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"mytrpc/h264"
+)
+
+const (
+	// mp4Timescale 为视频轨道的时间刻度，与 MPEG-TS 相同
+	mp4Timescale = 90000
+	// DefaultFragmentDuration 为 fMP4 每个分片的最长时长
+	DefaultFragmentDuration = time.Second
+	// defaultSampleDuration 用于无法从下一帧推算时长的最后一帧
+	defaultSampleDuration = mp4Timescale / 30
+
+	sampleFlagsKey   = 0x02000000 // sample_depends_on=2
+	sampleFlagsDelta = 0x01010000 // sample_depends_on=1, is_non_sync_sample
+)
+
+// MP4Writer 把 H.264 Annex-B 帧封装为分片 MP4（fMP4）写入 w。
+// 文件头在第一个带参数集的关键帧到达时写出，之前的帧被丢弃；之后每个关键帧或
+// 超过分片时长时写出一个 moof+mdat 分片，程序异常退出时已写出的分片仍可播放。
+// 样本的解码时间即 PTS，不支持 B 帧。
+type MP4Writer struct {
+	w        io.Writer
+	fragment time.Duration
+
+	sps, pps []byte
+	header   bool
+	seq      uint32
+	base     time.Duration
+	decode   uint64
+	lastDur  uint32
+	pending  []mp4Sample
+	buffered int64
+	written  int64
+	err      error
+}
+
+type mp4Sample struct {
+	pts  time.Duration
+	key  bool
+	data []byte
+}
+
+// NewMP4Writer 创建 fMP4 写入器，fragment 为0时使用 DefaultFragmentDuration
+func NewMP4Writer(w io.Writer, fragment time.Duration) *MP4Writer {
+	if fragment <= 0 {
+		fragment = DefaultFragmentDuration
+	}
+	return &MP4Writer{w: w, fragment: fragment}
+}
+
+// WriteFrame 写入一帧 Annex-B 数据，pts 须单调递增
+func (m *MP4Writer) WriteFrame(data []byte, pts time.Duration) error {
+	if m.err != nil {
+		return m.err
+	}
+	au := h264.ParseAccessUnit(data)
+	if au.SPS != nil {
+		m.sps = append(m.sps[:0], au.SPS...)
+	}
+	if au.PPS != nil {
+		m.pps = append(m.pps[:0], au.PPS...)
+	}
+	if !au.HasPicture() {
+		return nil
+	}
+	if !m.header {
+		if !au.Key || m.sps == nil || m.pps == nil {
+			return nil
+		}
+		if err := m.writeHeader(); err != nil {
+			return err
+		}
+		m.base = pts
+	}
+
+	if len(m.pending) > 0 && (au.Key || pts-m.pending[0].pts >= m.fragment) {
+		if err := m.flush(m.ticks(pts)); err != nil {
+			return err
+		}
+	}
+	s := mp4Sample{pts: pts, key: au.Key, data: avccSample(au.NALUs)}
+	m.pending = append(m.pending, s)
+	m.buffered += int64(len(s.data))
+	return nil
+}
+
+// Size 返回已写出和待写出的字节数
+func (m *MP4Writer) Size() int64 {
+	return m.written + m.buffered
+}
+
+// Close 写出剩余的帧，不关闭底层的 io.Writer
+func (m *MP4Writer) Close() error {
+	if m.err != nil {
+		return m.err
+	}
+	if len(m.pending) == 0 {
+		return nil
+	}
+	last := m.ticks(m.pending[len(m.pending)-1].pts)
+	dur := uint64(m.lastDur)
+	if dur == 0 {
+		dur = defaultSampleDuration
+	}
+	return m.flush(last + dur)
+}
+
+// ticks 把 PTS 换算为相对第一帧的时间刻度。
+// 整秒和余数分开换算，直接乘以 mp4Timescale 在录制约28小时后会溢出 int64。
+func (m *MP4Writer) ticks(pts time.Duration) uint64 {
+	if pts <= m.base {
+		return 0
+	}
+	d := pts - m.base
+	return uint64(d/time.Second)*mp4Timescale + uint64(d%time.Second)*mp4Timescale/uint64(time.Second)
+}
+
+func (m *MP4Writer) write(b []byte) error {
+	n, err := m.w.Write(b)
+	m.written += int64(n)
+	if err != nil {
+		m.err = fmt.Errorf("recorder: write mp4 failed (写入MP4失败): %w", err)
+	}
+	return m.err
+}
+
+func (m *MP4Writer) writeHeader() error {
+	info, err := h264.ParseSPS(m.sps)
+	if err != nil {
+		m.err = err
+		return err
+	}
+	var b boxBuilder
+	b.box("ftyp", func() {
+		b.str("isom")
+		b.u32(0x200)
+		b.str("isom", "iso6", "avc1", "mp41")
+	})
+	b.box("moov", func() {
+		b.fullBox("mvhd", 0, 0, func() {
+			b.u32(0, 0) // creation_time, modification_time
+			b.u32(1000) // timescale
+			b.u32(0)    // duration 由分片决定
+			b.u32(0x00010000)
+			b.u16(0x0100)
+			b.zero(10)
+			b.matrix()
+			b.zero(24)
+			b.u32(2) // next_track_ID
+		})
+		b.box("trak", func() {
+			b.fullBox("tkhd", 0, 3, func() {
+				b.u32(0, 0)
+				b.u32(1) // track_ID
+				b.u32(0)
+				b.u32(0) // duration
+				b.zero(8)
+				b.u16(0, 0, 0, 0) // layer, alternate_group, volume, reserved
+				b.matrix()
+				b.u32(uint32(info.Width)<<16, uint32(info.Height)<<16)
+			})
+			b.box("mdia", func() {
+				b.fullBox("mdhd", 0, 0, func() {
+					b.u32(0, 0)
+					b.u32(mp4Timescale)
+					b.u32(0)
+					b.u16(0x55c4, 0) // language "und"
+				})
+				b.fullBox("hdlr", 0, 0, func() {
+					b.u32(0)
+					b.str("vide")
+					b.zero(12)
+					b.str("VideoHandler\x00")
+				})
+				b.box("minf", func() {
+					b.fullBox("vmhd", 0, 1, func() { b.zero(8) })
+					b.box("dinf", func() {
+						b.fullBox("dref", 0, 0, func() {
+							b.u32(1)
+							b.fullBox("url ", 0, 1, func() {})
+						})
+					})
+					b.box("stbl", func() {
+						b.fullBox("stsd", 0, 0, func() {
+							b.u32(1)
+							m.avc1(&b, info)
+						})
+						b.fullBox("stts", 0, 0, func() { b.u32(0) })
+						b.fullBox("stsc", 0, 0, func() { b.u32(0) })
+						b.fullBox("stsz", 0, 0, func() { b.u32(0, 0) })
+						b.fullBox("stco", 0, 0, func() { b.u32(0) })
+					})
+				})
+			})
+		})
+		b.box("mvex", func() {
+			b.fullBox("trex", 0, 0, func() { b.u32(1, 1, 0, 0, 0) })
+		})
+	})
+	if err := m.write(b.buf); err != nil {
+		return err
+	}
+	m.header = true
+	return nil
+}
+
+// avc1 写出视频样本描述及 avcC 解码配置
+func (m *MP4Writer) avc1(b *boxBuilder, info h264.SPS) {
+	b.box("avc1", func() {
+		b.zero(6)
+		b.u16(1) // data_reference_index
+		b.zero(16)
+		b.u16(uint16(info.Width), uint16(info.Height))
+		b.u32(0x00480000, 0x00480000) // 72 dpi
+		b.u32(0)
+		b.u16(1) // frame_count
+		b.zero(32)
+		b.u16(0x0018, 0xffff)
+		b.box("avcC", func() {
+			b.u8(1, info.Profile, info.Compatibility, info.Level)
+			b.u8(0xfc|3, 0xe0|1) // 4 字节长度前缀，1 个 SPS
+			b.u16(uint16(len(m.sps)))
+			b.bytes(m.sps)
+			b.u8(1)
+			b.u16(uint16(len(m.pps)))
+			b.bytes(m.pps)
+			switch info.Profile {
+			case 100, 110, 122, 144:
+				b.u8(0xfc|uint8(info.ChromaFormat),
+					0xf8|uint8(info.BitDepthLumaMinus8),
+					0xf8|uint8(info.BitDepthChromaMin8),
+					0)
+			}
+		})
+	})
+}
+
+// flush 把待写出的帧写为一个分片，end 为下一帧的解码时间
+func (m *MP4Writer) flush(end uint64) error {
+	m.seq++
+	durations := make([]uint32, len(m.pending))
+	for i, s := range m.pending {
+		next := end
+		if i+1 < len(m.pending) {
+			next = m.ticks(m.pending[i+1].pts)
+		}
+		d := uint32(1)
+		if cur := m.ticks(s.pts); next > cur {
+			d = uint32(next - cur)
+		}
+		durations[i] = d
+	}
+
+	var b boxBuilder
+	var offsetAt int
+	b.box("moof", func() {
+		b.fullBox("mfhd", 0, 0, func() { b.u32(m.seq) })
+		b.box("traf", func() {
+			b.fullBox("tfhd", 0, 0x020000, func() { b.u32(1) }) // default-base-is-moof
+			b.fullBox("tfdt", 1, 0, func() { b.u64(m.decode) })
+			// data-offset, sample-duration, sample-size, sample-flags
+			b.fullBox("trun", 0, 0x000701, func() {
+				b.u32(uint32(len(m.pending)))
+				offsetAt = len(b.buf)
+				b.u32(0)
+				for i, s := range m.pending {
+					flags := uint32(sampleFlagsDelta)
+					if s.key {
+						flags = sampleFlagsKey
+					}
+					b.u32(durations[i], uint32(len(s.data)), flags)
+				}
+			})
+		})
+	})
+	binary.BigEndian.PutUint32(b.buf[offsetAt:], uint32(len(b.buf)+8))
+
+	size := 8
+	for _, s := range m.pending {
+		size += len(s.data)
+	}
+	b.u32(uint32(size))
+	b.str("mdat")
+	for _, s := range m.pending {
+		b.bytes(s.data)
+	}
+	if err := m.write(b.buf); err != nil {
+		return err
+	}
+
+	for _, d := range durations {
+		m.decode += uint64(d)
+	}
+	m.lastDur = durations[len(durations)-1]
+	m.pending = m.pending[:0]
+	m.buffered = 0
+	return nil
+}
+
+// avccSample 把 NAL 单元转换为 4 字节长度前缀格式，参数集和分隔符已在 avcC 中或无需保存
+func avccSample(nals [][]byte) []byte {
+	n := 0
+	for _, nal := range nals {
+		n += 4 + len(nal)
+	}
+	out := make([]byte, 0, n)
+	for _, nal := range nals {
+		switch h264.TypeOf(nal) {
+		case h264.NALSPS, h264.NALPPS, h264.NALAUD:
+			continue
+		}
+		out = binary.BigEndian.AppendUint32(out, uint32(len(nal)))
+		out = append(out, nal...)
+	}
+	return out
+}
+
+// boxBuilder 按 ISO BMFF 格式拼接 box
+type boxBuilder struct {
+	buf []byte
+}
+
+// box 写出类型为 typ 的 box，内容由 fn 写入后回填长度
+func (b *boxBuilder) box(typ string, fn func()) {
+	start := len(b.buf)
+	b.u32(0)
+	b.str(typ)
+	fn()
+	binary.BigEndian.PutUint32(b.buf[start:], uint32(len(b.buf)-start))
+}
+
+func (b *boxBuilder) fullBox(typ string, version uint8, flags uint32, fn func()) {
+	b.box(typ, func() {
+		b.u32(uint32(version)<<24 | flags&0xffffff)
+		fn()
+	})
+}
+
+func (b *boxBuilder) u8(v ...uint8) { b.buf = append(b.buf, v...) }
+
+func (b *boxBuilder) u16(v ...uint16) {
+	for _, x := range v {
+		b.buf = binary.BigEndian.AppendUint16(b.buf, x)
+	}
+}
+
+func (b *boxBuilder) u32(v ...uint32) {
+	for _, x := range v {
+		b.buf = binary.BigEndian.AppendUint32(b.buf, x)
+	}
+}
+
+func (b *boxBuilder) u64(v uint64) { b.buf = binary.BigEndian.AppendUint64(b.buf, v) }
+
+func (b *boxBuilder) str(s ...string) {
+	for _, x := range s {
+		b.buf = append(b.buf, x...)
+	}
+}
+
+func (b *boxBuilder) bytes(p []byte) { b.buf = append(b.buf, p...) }
+
+func (b *boxBuilder) zero(n int) { b.buf = append(b.buf, make([]byte, n)...) }
+
+// matrix 写出单位变换矩阵
+func (b *boxBuilder) matrix() {
+	b.u32(0x00010000, 0, 0, 0, 0x00010000, 0, 0, 0, 0x40000000)
+}
@@ -0,0 +1,181 @@
+package recorder_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mytrpc/recorder"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// x264 编码的 1920x1080 High@4.0 参数集，与 h264 包测试中的相同
+var (
+	spsX264 = mustHex("67640028acd940780227e584000003000400000300f03c60c658")
+	ppsX264 = mustHex("68ebe3cb22c0")
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// annexB 把 NAL 单元拼接为带 4 字节起始码的一帧
+func annexB(nals ...[]byte) []byte {
+	var out []byte
+	for _, nal := range nals {
+		out = append(append(out, 0, 0, 0, 1), nal...)
+	}
+	return out
+}
+
+// containers 为只包含子 box 的容器，值为子 box 之前的字节数
+var containers = map[string]int{
+	"moov": 0, "trak": 0, "mdia": 0, "minf": 0, "dinf": 0, "stbl": 0, "mvex": 0,
+	"moof": 0, "traf": 0, "dref": 8, "stsd": 8, "avc1": 78,
+}
+
+// dumpBoxes 按层级输出 box 的类型、大小和与封装相关的字段
+func dumpBoxes(t *testing.T, w *strings.Builder, data []byte, depth int) {
+	t.Helper()
+	for len(data) > 0 {
+		if len(data) < 8 {
+			t.Fatalf("truncated box header: %x", data)
+		}
+		size := int(binary.BigEndian.Uint32(data))
+		typ := string(data[4:8])
+		if size < 8 || size > len(data) {
+			t.Fatalf("box %q size %d, %d bytes left", typ, size, len(data))
+		}
+		body := data[8:size]
+		fmt.Fprintf(w, "%s%s %d%s\n", strings.Repeat("  ", depth), typ, size, boxFields(typ, body))
+		if skip, ok := containers[typ]; ok {
+			dumpBoxes(t, w, body[skip:], depth+1)
+		}
+		data = data[size:]
+	}
+}
+
+func boxFields(typ string, body []byte) string {
+	u32 := func(off int) uint32 { return binary.BigEndian.Uint32(body[off:]) }
+	switch typ {
+	case "ftyp":
+		return fmt.Sprintf(" brand=%s compatible=%s", body[:4], body[8:])
+	case "tkhd":
+		return fmt.Sprintf(" track=%d size=%dx%d", u32(12), u32(76)>>16, u32(80)>>16)
+	case "mdhd":
+		return fmt.Sprintf(" timescale=%d", u32(12))
+	case "avc1":
+		return fmt.Sprintf(" size=%dx%d", binary.BigEndian.Uint16(body[24:]), binary.BigEndian.Uint16(body[26:]))
+	case "avcC":
+		return fmt.Sprintf(" %x", body)
+	case "mfhd":
+		return fmt.Sprintf(" seq=%d", u32(4))
+	case "tfdt":
+		return fmt.Sprintf(" decode=%d", binary.BigEndian.Uint64(body[4:]))
+	case "trun":
+		var b strings.Builder
+		n := int(u32(4))
+		fmt.Fprintf(&b, " samples=%d offset=%d", n, u32(8))
+		for i := 0; i < n; i++ {
+			off := 12 + i*12
+			fmt.Fprintf(&b, " [%d %d %08x]", u32(off), u32(off+4), u32(off+8))
+		}
+		return b.String()
+	case "mdat":
+		return fmt.Sprintf(" %x", body)
+	}
+	return ""
+}
+
+func TestMP4WriterGolden(t *testing.T) {
+	var buf bytes.Buffer
+	m := recorder.NewMP4Writer(&buf, 0)
+	frames := []struct {
+		data []byte
+		pts  time.Duration
+	}{
+		// 第一个关键帧之前的帧被丢弃
+		{annexB([]byte{0x41, 0x9a, 0x01}), 0},
+		{annexB([]byte{0x09, 0xf0}, spsX264, ppsX264, []byte{0x65, 0x88, 0x84, 0x00}), 10 * time.Millisecond},
+		{annexB([]byte{0x41, 0x9a, 0x02}), 43333333 * time.Nanosecond},
+		{annexB([]byte{0x41, 0x9a, 0x03}), 76666666 * time.Nanosecond},
+		// 关键帧开始新的分片
+		{annexB(spsX264, ppsX264, []byte{0x65, 0x88, 0x85}), 110 * time.Millisecond},
+		{annexB([]byte{0x41, 0x9a, 0x04}), 150 * time.Millisecond},
+		// 超过分片时长开始新的分片
+		{annexB([]byte{0x41, 0x9a, 0x05}), 1200 * time.Millisecond},
+	}
+	for _, f := range frames {
+		if err := m.WriteFrame(f.data, f.pts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if m.Size() != int64(buf.Len()) {
+		t.Errorf("Size() = %d, wrote %d bytes", m.Size(), buf.Len())
+	}
+
+	var dump strings.Builder
+	dumpBoxes(t, &dump, buf.Bytes(), 0)
+	golden := filepath.Join("testdata", "fmp4.golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, []byte(dump.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dump.String() != string(want) {
+		t.Errorf("box dump mismatch (run with -update to regenerate)\ngot:\n%s\nwant:\n%s", dump.String(), want)
+	}
+}
+
+// TestMP4WriterLongRecording 确认录制超过28小时后解码时间不会溢出
+func TestMP4WriterLongRecording(t *testing.T) {
+	var buf bytes.Buffer
+	m := recorder.NewMP4Writer(&buf, time.Hour)
+	key := annexB(spsX264, ppsX264, []byte{0x65, 0x88, 0x84})
+	start := 5 * time.Second
+	// 每小时一个关键帧，每帧时长一小时
+	const hours = 30
+	for h := 0; h <= hours; h++ {
+		if err := m.WriteFrame(key, start+time.Duration(h)*time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var dump strings.Builder
+	dumpBoxes(t, &dump, buf.Bytes(), 0)
+	var last string
+	for _, line := range strings.Split(dump.String(), "\n") {
+		if strings.Contains(line, "tfdt") {
+			last = line
+		}
+	}
+	want := fmt.Sprintf("decode=%d", uint64(hours)*3600*90000)
+	if !strings.HasSuffix(last, want) {
+		t.Fatalf("last tfdt = %q, want %s", strings.TrimSpace(last), want)
+	}
+}
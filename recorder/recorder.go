@@ -0,0 +1,278 @@
+// Package recorder 把 device.StartVideoStream 输出的 H.264 码流录制为文件，
+// 支持裸 .h264 码流和分片 MP4（纯 Go 实现，无需 ffmpeg）。
+//
+// 录制按时长或大小切分为多个文件，新文件总是从关键帧开始并带有参数集，
+// 每个文件都可以单独播放。通道中出现丢帧时丢弃后续帧直到下一个关键帧，避免花屏。
+package recorder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"mytrpc/device"
+	"mytrpc/h264"
+)
+
+// Format 为录制的文件格式
+type Format int
+
+const (
+	// FormatMP4 为分片 MP4，异常退出时已写出的部分仍可播放
+	FormatMP4 Format = iota
+	// FormatH264 为裸 Annex-B 码流
+	FormatH264
+)
+
+func (f Format) String() string {
+	if f == FormatH264 {
+		return "h264"
+	}
+	return "mp4"
+}
+
+// Ext 返回文件扩展名
+func (f Format) Ext() string {
+	return "." + f.String()
+}
+
+// Options 为录制配置
+type Options struct {
+	// Dir 为输出目录，不存在时创建，默认当前目录
+	Dir string
+	// Prefix 为文件名前缀，默认 "record"。文件名为 <Prefix>-<开始时间>-<序号><扩展名>
+	Prefix string
+	Format Format
+	// MaxDuration、MaxBytes 为单个文件的时长和大小上限，为0时不限制。
+	// 超过上限后在下一个关键帧切换文件，因此实际值会略大于上限
+	MaxDuration time.Duration
+	MaxBytes    int64
+	// FragmentDuration 为 MP4 分片时长，默认 DefaultFragmentDuration
+	FragmentDuration time.Duration
+	// OnSegment 在每个文件写完并关闭后调用，可用于上传或清理
+	OnSegment func(Segment)
+}
+
+// Segment 为一个已录制的文件
+type Segment struct {
+	Path     string
+	Start    time.Time
+	Duration time.Duration
+	Bytes    int64
+	Frames   int
+}
+
+// frameWriter 为 H264Writer 和 MP4Writer 的公共方法
+type frameWriter interface {
+	WriteFrame(data []byte, pts time.Duration) error
+	Size() int64
+	Close() error
+}
+
+// ErrClosed 表示录制已结束
+var ErrClosed = errors.New("recorder: closed (录制已结束)")
+
+// Recorder 把视频帧按段写入文件，可并发调用
+type Recorder struct {
+	opts Options
+
+	mu       sync.Mutex
+	closed   bool
+	sps, pps []byte
+	file     *os.File
+	w        frameWriter
+	cur      Segment
+	startPTS time.Duration
+	count    int
+	lastSeq  uint64
+	haveSeq  bool
+	waitKey  bool
+	segments []Segment
+}
+
+// New 创建录制器，第一个文件在收到第一个关键帧时创建
+func New(opts Options) (*Recorder, error) {
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = "record"
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("recorder: create dir failed (创建录制目录失败): %w", err)
+	}
+	return &Recorder{opts: opts}, nil
+}
+
+// Record 从 frames 读取并写入，直到通道关闭（返回 nil）、ctx 取消或写入出错。
+// Record 不会关闭录制器，结束后需调用 Close 写完最后一个文件
+func (r *Recorder) Record(ctx context.Context, frames <-chan device.Frame) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case f, ok := <-frames:
+			if !ok {
+				return nil
+			}
+			if err := r.Write(f); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Write 写入一个视频帧。序号不连续（通道丢帧）时丢弃后续帧直到下一个关键帧
+func (r *Recorder) Write(f device.Frame) error {
+	if f.Codec != "" && f.Codec != device.CodecH264 {
+		return fmt.Errorf("recorder: unsupported codec %q (不支持的编码格式)", f.Codec)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.haveSeq && f.Seq != r.lastSeq+1 {
+		r.waitKey = true
+	}
+	r.lastSeq, r.haveSeq = f.Seq, true
+	at := f.Time
+	if at.IsZero() {
+		at = time.Now()
+	}
+	return r.write(f.Data, f.PTS, at)
+}
+
+// WriteFrame 写入一帧 Annex-B 数据，pts 须单调递增
+func (r *Recorder) WriteFrame(data []byte, pts time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.write(data, pts, time.Now())
+}
+
+func (r *Recorder) write(data []byte, pts time.Duration, at time.Time) error {
+	if r.closed {
+		return ErrClosed
+	}
+	au := h264.ParseAccessUnit(data)
+	if au.SPS != nil {
+		r.sps = append(r.sps[:0], au.SPS...)
+	}
+	if au.PPS != nil {
+		r.pps = append(r.pps[:0], au.PPS...)
+	}
+	if !au.HasPicture() {
+		// 只有参数集时交给当前文件的写入器缓存
+		if r.w != nil {
+			return r.w.WriteFrame(data, pts)
+		}
+		return nil
+	}
+
+	if au.Key {
+		r.waitKey = false
+		if r.w == nil || r.full(pts) {
+			if r.sps == nil || r.pps == nil {
+				return nil
+			}
+			if err := r.rotate(at, pts); err != nil {
+				return err
+			}
+			if au.SPS == nil || au.PPS == nil {
+				data = withParams(r.sps, r.pps, data)
+			}
+		}
+	}
+	if r.w == nil || r.waitKey {
+		return nil
+	}
+	if err := r.w.WriteFrame(data, pts); err != nil {
+		return err
+	}
+	r.count++
+	r.cur.Duration = pts - r.startPTS
+	return nil
+}
+
+// full 报告当前文件是否已超过上限
+func (r *Recorder) full(pts time.Duration) bool {
+	if r.opts.MaxDuration > 0 && pts-r.startPTS >= r.opts.MaxDuration {
+		return true
+	}
+	return r.opts.MaxBytes > 0 && r.w.Size() >= r.opts.MaxBytes
+}
+
+// rotate 关闭当前文件并创建新文件
+func (r *Recorder) rotate(at time.Time, pts time.Duration) error {
+	if err := r.finish(); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s-%03d%s", r.opts.Prefix, at.Format("20060102-150405"), len(r.segments)+1, r.opts.Format.Ext())
+	path := filepath.Join(r.opts.Dir, name)
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("recorder: create file failed (创建录制文件失败): %w", err)
+	}
+	r.file = file
+	if r.opts.Format == FormatH264 {
+		r.w = NewH264Writer(file)
+	} else {
+		r.w = NewMP4Writer(file, r.opts.FragmentDuration)
+	}
+	r.cur = Segment{Path: path, Start: at}
+	r.startPTS = pts
+	r.count = 0
+	return nil
+}
+
+// finish 写完并关闭当前文件
+func (r *Recorder) finish() error {
+	if r.w == nil {
+		return nil
+	}
+	err := r.w.Close()
+	if cerr := r.file.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("recorder: close file failed (关闭录制文件失败): %w", cerr)
+	}
+	seg := r.cur
+	seg.Bytes = r.w.Size()
+	seg.Frames = r.count
+	r.w, r.file = nil, nil
+	if err != nil {
+		return err
+	}
+	r.segments = append(r.segments, seg)
+	if r.opts.OnSegment != nil {
+		r.opts.OnSegment(seg)
+	}
+	return nil
+}
+
+// Segments 返回已写完的文件
+func (r *Recorder) Segments() []Segment {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Segment(nil), r.segments...)
+}
+
+// Close 写完当前文件，之后的写入返回 ErrClosed
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	return r.finish()
+}
+
+// withParams 在帧前补上参数集
+func withParams(sps, pps, data []byte) []byte {
+	out := make([]byte, 0, len(sps)+len(pps)+len(data)+8)
+	out = append(out, startCode...)
+	out = append(out, sps...)
+	out = append(out, startCode...)
+	out = append(out, pps...)
+	return append(out, data...)
+}
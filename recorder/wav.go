@@ -0,0 +1,144 @@
+package recorder
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"mytrpc/device"
+)
+
+const wavHeaderSize = 44
+
+// WAVWriter 把 PCM 数据写为 WAV 文件。w 实现 io.Seeker 时 Close 回填数据长度，
+// 否则长度字段保持最大值，大多数播放器会读到文件结尾
+type WAVWriter struct {
+	w       io.Writer
+	format  device.AudioFormat
+	written int64
+	err     error
+}
+
+// NewWAVWriter 写出 WAV 文件头，只支持 PCM 格式
+func NewWAVWriter(w io.Writer, format device.AudioFormat) (*WAVWriter, error) {
+	if format.Codec != device.AudioPCM || format.SampleRate <= 0 || format.Channels <= 0 || format.BitsPerSample <= 0 {
+		return nil, fmt.Errorf("recorder: unsupported wav format %v (WAV只支持PCM)", format)
+	}
+	ww := &WAVWriter{w: w, format: format}
+	if _, err := w.Write(wavHeader(format, 0xffffffff-wavHeaderSize+8)); err != nil {
+		return nil, fmt.Errorf("recorder: write wav failed (写入WAV失败): %w", err)
+	}
+	return ww, nil
+}
+
+// wavHeader 生成 RIFF/WAVE 文件头，size 为数据字节数
+func wavHeader(f device.AudioFormat, size uint32) []byte {
+	blockAlign := f.Channels * f.BitsPerSample / 8
+	b := make([]byte, 0, wavHeaderSize)
+	b = append(b, "RIFF"...)
+	b = binary.LittleEndian.AppendUint32(b, size+wavHeaderSize-8)
+	b = append(b, "WAVEfmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, 1) // PCM
+	b = binary.LittleEndian.AppendUint16(b, uint16(f.Channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(f.SampleRate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(f.SampleRate*blockAlign))
+	b = binary.LittleEndian.AppendUint16(b, uint16(blockAlign))
+	b = binary.LittleEndian.AppendUint16(b, uint16(f.BitsPerSample))
+	b = append(b, "data"...)
+	return binary.LittleEndian.AppendUint32(b, size)
+}
+
+// Write 写入 PCM 数据
+func (w *WAVWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(p)
+	w.written += int64(n)
+	if err != nil {
+		w.err = fmt.Errorf("recorder: write wav failed (写入WAV失败): %w", err)
+	}
+	return n, w.err
+}
+
+// WriteChunk 写入一段音频，格式须与文件头一致
+func (w *WAVWriter) WriteChunk(c device.AudioChunk) error {
+	if c.Format != w.format {
+		return fmt.Errorf("recorder: audio format changed from %v to %v (音频格式变化)", w.format, c.Format)
+	}
+	_, err := w.Write(c.Data)
+	return err
+}
+
+// Size 返回已写入的 PCM 字节数，不含文件头
+func (w *WAVWriter) Size() int64 {
+	return w.written
+}
+
+// Close 回填文件头中的长度，不关闭底层的 io.Writer
+func (w *WAVWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	seeker, ok := w.w.(io.Seeker)
+	if !ok {
+		return nil
+	}
+	size := uint32(min(w.written, 0xffffffff-wavHeaderSize+8))
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("recorder: seek wav failed (回填WAV文件头失败): %w", err)
+	}
+	if _, err := w.w.Write(wavHeader(w.format, size)); err != nil {
+		return fmt.Errorf("recorder: write wav failed (写入WAV失败): %w", err)
+	}
+	_, err := seeker.Seek(0, io.SeekEnd)
+	return err
+}
+
+// RecordWAV 把 chunks 中的 PCM 数据写入 path，格式取自第一段数据，
+// 直到通道关闭或 ctx 取消，返回时文件已写完。AAC 数据返回错误
+func RecordWAV(ctx context.Context, path string, chunks <-chan device.AudioChunk) (err error) {
+	var (
+		file *os.File
+		w    *WAVWriter
+	)
+	defer func() {
+		if w != nil {
+			if cerr := w.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if file != nil {
+			if cerr := file.Close(); err == nil && cerr != nil {
+				err = fmt.Errorf("recorder: close file failed (关闭录制文件失败): %w", cerr)
+			}
+		}
+	}()
+	for {
+		var c device.AudioChunk
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case v, ok := <-chunks:
+			if !ok {
+				return nil
+			}
+			c = v
+		}
+		if w == nil {
+			if file, err = os.Create(path); err != nil {
+				file = nil
+				return fmt.Errorf("recorder: create file failed (创建录制文件失败): %w", err)
+			}
+			if w, err = NewWAVWriter(file, c.Format); err != nil {
+				return err
+			}
+		}
+		if err := w.WriteChunk(c); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package recorder_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mytrpc/device"
+	"mytrpc/recorder"
+)
+
+var stereo = device.AudioFormat{Codec: device.AudioPCM, SampleRate: 48000, Channels: 2, BitsPerSample: 16}
+
+// wavFields 解析 44 字节的 WAV 文件头
+type wavFields struct {
+	RIFF, WAVE, Fmt, Data       string
+	RIFFSize, FmtSize, DataSize uint32
+	AudioFormat, Channels       uint16
+	SampleRate, ByteRate        uint32
+	BlockAlign, BitsPerSample   uint16
+}
+
+func parseWAVHeader(t *testing.T, b []byte) wavFields {
+	t.Helper()
+	if len(b) < 44 {
+		t.Fatalf("header is %d bytes, want 44", len(b))
+	}
+	le := binary.LittleEndian
+	return wavFields{
+		RIFF: string(b[0:4]), RIFFSize: le.Uint32(b[4:]), WAVE: string(b[8:12]),
+		Fmt: string(b[12:16]), FmtSize: le.Uint32(b[16:]), AudioFormat: le.Uint16(b[20:]),
+		Channels: le.Uint16(b[22:]), SampleRate: le.Uint32(b[24:]), ByteRate: le.Uint32(b[28:]),
+		BlockAlign: le.Uint16(b[32:]), BitsPerSample: le.Uint16(b[34:]),
+		Data: string(b[36:40]), DataSize: le.Uint32(b[40:]),
+	}
+}
+
+func TestWAVHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		format device.AudioFormat
+		want   wavFields
+	}{
+		{
+			name:   "48k stereo 16bit",
+			format: stereo,
+			want:   wavFields{Channels: 2, SampleRate: 48000, ByteRate: 192000, BlockAlign: 4, BitsPerSample: 16},
+		},
+		{
+			name:   "16k mono 16bit",
+			format: device.AudioFormat{Codec: device.AudioPCM, SampleRate: 16000, Channels: 1, BitsPerSample: 16},
+			want:   wavFields{Channels: 1, SampleRate: 16000, ByteRate: 32000, BlockAlign: 2, BitsPerSample: 16},
+		},
+		{
+			name:   "44.1k stereo 24bit",
+			format: device.AudioFormat{Codec: device.AudioPCM, SampleRate: 44100, Channels: 2, BitsPerSample: 24},
+			want:   wavFields{Channels: 2, SampleRate: 44100, ByteRate: 264600, BlockAlign: 6, BitsPerSample: 24},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "a.wav")
+			f, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w, err := recorder.NewWAVWriter(f, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pcm := bytes.Repeat([]byte{1, 2, 3, 4, 5, 6}, 1000)
+			if err := w.WriteChunk(device.AudioChunk{Format: tt.format, Data: pcm}); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+			if w.Size() != int64(len(pcm)) {
+				t.Errorf("Size() = %d, want %d", w.Size(), len(pcm))
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data) != 44+len(pcm) || !bytes.Equal(data[44:], pcm) {
+				t.Fatalf("file is %d bytes, want the header and %d bytes of pcm", len(data), len(pcm))
+			}
+			want := tt.want
+			want.RIFF, want.WAVE, want.Fmt, want.Data = "RIFF", "WAVE", "fmt ", "data"
+			want.FmtSize, want.AudioFormat = 16, 1
+			want.DataSize, want.RIFFSize = uint32(len(pcm)), uint32(36+len(pcm))
+			if got := parseWAVHeader(t, data); got != want {
+				t.Fatalf("header = %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestWAVWriterUnseekable(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := recorder.NewWAVWriter(&buf, stereo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(make([]byte, 400)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// 无法回填时长度字段为最大值，RIFF 长度不溢出
+	h := parseWAVHeader(t, buf.Bytes())
+	if h.DataSize != 0xffffffff-36 || h.RIFFSize != 0xffffffff {
+		t.Fatalf("sizes = riff %d, data %d, want the maximum", h.RIFFSize, h.DataSize)
+	}
+	if buf.Len() != 444 {
+		t.Fatalf("wrote %d bytes, want 444", buf.Len())
+	}
+}
+
+func TestWAVWriterRejects(t *testing.T) {
+	formats := []device.AudioFormat{
+		{Codec: device.AudioAAC, SampleRate: 48000, Channels: 2, BitsPerSample: 16},
+		{Codec: device.AudioPCM, Channels: 2, BitsPerSample: 16},
+		{Codec: device.AudioPCM, SampleRate: 48000, BitsPerSample: 16},
+		{Codec: device.AudioPCM, SampleRate: 48000, Channels: 2},
+	}
+	for _, f := range formats {
+		var buf bytes.Buffer
+		if _, err := recorder.NewWAVWriter(&buf, f); err == nil || buf.Len() != 0 {
+			t.Errorf("NewWAVWriter(%v) = %v after writing %d bytes, want an error", f, err, buf.Len())
+		}
+	}
+
+	var buf bytes.Buffer
+	w, err := recorder.NewWAVWriter(&buf, stereo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mono := stereo
+	mono.Channels = 1
+	if err := w.WriteChunk(device.AudioChunk{Format: mono, Data: make([]byte, 10)}); err == nil || !strings.Contains(err.Error(), "format changed") {
+		t.Fatalf("WriteChunk with another format = %v, want an error", err)
+	}
+	if w.Size() != 0 {
+		t.Fatalf("Size() = %d after a rejected chunk", w.Size())
+	}
+}
+
+func TestRecordWAV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rec.wav")
+	chunks := make(chan device.AudioChunk, 3)
+	for i := 0; i < 3; i++ {
+		chunks <- device.AudioChunk{Format: stereo, Data: bytes.Repeat([]byte{byte(i)}, 192)}
+	}
+	close(chunks)
+	if err := recorder.RecordWAV(context.Background(), path, chunks); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := parseWAVHeader(t, data); h.DataSize != 576 || len(data) != 44+576 {
+		t.Fatalf("data size %d in a %d byte file, want 576", h.DataSize, len(data))
+	}
+
+	// ctx 取消时也回填长度
+	path = filepath.Join(t.TempDir(), "cancel.wav")
+	chunks = make(chan device.AudioChunk, 1)
+	chunks <- device.AudioChunk{Format: stereo, Data: make([]byte, 100)}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := recorder.RecordWAV(ctx, path, chunks); err != context.DeadlineExceeded {
+		t.Fatalf("err = %v, want DeadlineExceeded", err)
+	}
+	if data, _ := os.ReadFile(path); len(data) != 144 || parseWAVHeader(t, data).DataSize != 100 {
+		t.Fatalf("cancelled recording is %d bytes", len(data))
+	}
+
+	// AAC 不能写为 WAV
+	path = filepath.Join(t.TempDir(), "aac.wav")
+	chunks = make(chan device.AudioChunk, 1)
+	chunks <- device.AudioChunk{Format: device.AudioFormat{Codec: device.AudioAAC, SampleRate: 48000, Channels: 2}}
+	close(chunks)
+	if err := recorder.RecordWAV(context.Background(), path, chunks); err == nil {
+		t.Fatal("recorded aac as wav")
+	}
+}
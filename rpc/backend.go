@@ -0,0 +1,164 @@
+package rpc
+
+// Backend 抽象了 libmytrpc 导出的原生操作。
+//
+// Device、Node、Selector 只通过该接口访问设备，原生动态库只是其中一种实现，
+// 也可以替换为其他传输方式或测试替身。所有句柄都以 uintptr 表示，与原生库保持一致；
+// 返回的字符串和数据均已复制到 Go 内存，原生内存由实现负责释放。
+type Backend interface {
+	// OpenDevice 对应 openDevice，连接设备并返回设备句柄
+	OpenDevice(host string, port int, timeout int) (uintptr, error)
+	// CloseDevice 对应 closeDevice
+	CloseDevice(handle uintptr) error
+	// CheckLive 对应 checkLive
+	CheckLive(handle uintptr) (bool, error)
+	// GetVersion 对应 getVersion
+	GetVersion() (int, error)
+
+	// UseNewNodeMode 对应 useNewNodeMode
+	UseNewNodeMode(handle uintptr, mode int) error
+	// TakeCaptrueCompress 对应 takeCaptrueCompress，返回压缩后的图片数据
+	TakeCaptrueCompress(handle uintptr, imgType int, quality int) ([]byte, error)
+	// TakeCaptrueCompressEx 对应 takeCaptrueCompressEx，截取 left、top、right、bottom 指定的区域并返回压缩后的图片数据
+	TakeCaptrueCompressEx(handle uintptr, left, top, right, bottom int, imgType int, quality int) ([]byte, error)
+	// TakeCaptrue 对应 takeCaptrue，返回未压缩的 RGBA 像素，stride 为每行字节数
+	TakeCaptrue(handle uintptr) (pix []byte, width, height, stride int, err error)
+	// TakeCaptrueEx 对应 takeCaptrueEx，截取指定区域并返回未压缩的 RGBA 像素
+	TakeCaptrueEx(handle uintptr, left, top, right, bottom int) (pix []byte, width, height, stride int, err error)
+	// ScreenshotEx 对应 screentshotEx，将截图直接保存到文件
+	ScreenshotEx(handle uintptr, left, top, right, bottom int, imgType int, quality int, path string) error
+	// StartVideoStream 对应 startVideoStream，按 width、height、bitrate 开始推流，
+	// 视频和音频数据分别通过 onVideo、onAudio 回调，为 nil 时不接收对应数据。
+	// 回调在原生库的线程中执行，不应阻塞；同一进程内同时只能有一路视频流。
+	StartVideoStream(handle uintptr, width, height, bitrate int, onVideo VideoCallback, onAudio AudioCallback) error
+	// StopVideoStream 对应 stopVideoStream，返回后不再调用回调
+	StopVideoStream(handle uintptr) error
+	// GetDisplayRotate 对应 getDisplayRotate
+	GetDisplayRotate(handle uintptr) (int, error)
+
+	// KeyPress 对应 keyPress
+	KeyPress(handle uintptr, code int) error
+	// TouchDown 对应 touchDown
+	TouchDown(handle uintptr, fingerID int, x, y int) error
+	// TouchUp 对应 touchUp
+	TouchUp(handle uintptr, fingerID int, x, y int) error
+	// TouchMove 对应 touchMove
+	TouchMove(handle uintptr, fingerID int, x, y int) error
+	// TouchClick 对应 touchClick
+	TouchClick(handle uintptr, fingerID int, x, y int) error
+	// Swipe 对应 swipe，duration 单位为毫秒
+	Swipe(handle uintptr, fingerID int, x0, y0, x1, y1 int, duration int, async bool) error
+	// SendText 对应 sendText
+	SendText(handle uintptr, text string) error
+
+	// ExecCmd 对应 execCmd，wait 为 true 时同步等待并返回命令输出
+	ExecCmd(handle uintptr, wait bool, cmd string) (string, error)
+	// OpenApp 对应 openApp
+	OpenApp(handle uintptr, packageName string) error
+	// StopApp 对应 stopApp
+	StopApp(handle uintptr, packageName string) error
+
+	// DumpNodeXml 对应 dumpNodeXml
+	DumpNodeXml(handle uintptr, dumpAll bool) (string, error)
+	// DumpNodeXmlEx 对应 dumpNodeXmlEx，timeout 单位为毫秒
+	DumpNodeXmlEx(handle uintptr, workMode bool, timeout int) (string, error)
+
+	// NewSelector 对应 newSelector，返回选择器句柄
+	NewSelector(handle uintptr) (uintptr, error)
+	// ClearSelector 对应 clearSelector
+	ClearSelector(selector uintptr) error
+	// FreeSelector 对应 freeSelector
+	FreeSelector(selector uintptr) error
+	// AddStringQuery 调用字符串类查询条件，如 TextContainWith、IdEqual
+	AddStringQuery(selector uintptr, query string, value string) error
+	// AddBoolQuery 调用布尔类查询条件，如 Clickable、Enable
+	AddBoolQuery(selector uintptr, query string, value bool) error
+	// AddIntQuery 调用整数类查询条件，如 Index
+	AddIntQuery(selector uintptr, query string, value int) error
+	// AddBoundsQuery 调用区域类查询条件，如 BoundsEqual、BoundsInside
+	AddBoundsQuery(selector uintptr, query string, left, top, right, bottom int) error
+	// FindNodes 对应 findNodes，返回节点集合句柄，timeout 单位为毫秒
+	FindNodes(selector uintptr, maxNode int, timeout int) (uintptr, error)
+	// GetNodesSize 对应 getNodesSize
+	GetNodesSize(nodes uintptr) (int, error)
+	// GetNodeByIndex 对应 getNodeByIndex
+	GetNodeByIndex(nodes uintptr, index int) (uintptr, error)
+	// FreeNodes 对应 freeNodes
+	FreeNodes(nodes uintptr) error
+
+	// ClickNode 对应 clickNode
+	ClickNode(node uintptr) error
+	// LongClickNode 对应 longClickNode
+	LongClickNode(node uintptr) error
+	// GetNodeBound 对应 getNodeNound
+	GetNodeBound(node uintptr) (left, top, right, bottom int, err error)
+	// GetNodeBoundCenter 对应 getNodeNoundCenter
+	GetNodeBoundCenter(node uintptr) (x, y int, err error)
+	// GetNodeText 对应 getNodeText
+	GetNodeText(node uintptr) (string, error)
+	// GetNodeDesc 对应 getNodeDesc
+	GetNodeDesc(node uintptr) (string, error)
+	// GetNodePackage 对应 getNodePackage
+	GetNodePackage(node uintptr) (string, error)
+	// GetNodeClass 对应 getNodeClass
+	GetNodeClass(node uintptr) (string, error)
+	// GetNodeId 对应 getNodeId
+	GetNodeId(node uintptr) (string, error)
+	// GetNodeJson 对应 getNodeJson
+	GetNodeJson(node uintptr) (string, error)
+	// GetNodeParent 对应 getNodeParent
+	GetNodeParent(node uintptr) (uintptr, error)
+	// GetNodeChildCount 对应 getNodeChildCount
+	GetNodeChildCount(node uintptr) (int, error)
+	// GetNodeChild 对应 getNodeChild
+	GetNodeChild(node uintptr, index int) (uintptr, error)
+}
+
+// 查询条件名称，与原生库导出的选择器函数同名
+const (
+	QueryTextEqual       = "TextEqual"
+	QueryTextStartWith   = "TextStartWith"
+	QueryTextEndWith     = "TextEndWith"
+	QueryTextContainWith = "TextContainWith"
+	QueryTextMatchWith   = "TextMatchWith"
+
+	QueryIdEqual       = "IdEqual"
+	QueryIdStartWith   = "IdStartWith"
+	QueryIdEndWith     = "IdEndWith"
+	QueryIdContainWith = "IdContainWith"
+	QueryIdMatchWith   = "IdMatchWith"
+
+	QueryClzEqual       = "ClzEqual"
+	QueryClzStartWith   = "ClzStartWith"
+	QueryClzEndWith     = "ClzEndWith"
+	QueryClzContainWith = "ClzContainWith"
+	QueryClzMatchWith   = "ClzMatchWith"
+
+	QueryDescEqual       = "DescEqual"
+	QueryDescStartWith   = "DescStartWith"
+	QueryDescEndWith     = "DescEndWith"
+	QueryDescContainWith = "DescContainWith"
+	QueryDescMatchWith   = "DescMatchWith"
+
+	QueryPackageEqual       = "PackageEqual"
+	QueryPackageStartWith   = "PackageStartWith"
+	QueryPackageEndWith     = "PackageEndWith"
+	QueryPackageContainWith = "PackageContainWith"
+	QueryPackageMatchWith   = "PackageMatchWith"
+
+	QueryCheckable     = "Checkable"
+	QueryClickable     = "Clickable"
+	QueryEnable        = "Enable"
+	QueryFocusable     = "Focusable"
+	QueryFocused       = "Focused"
+	QueryLongClickable = "LongClickable"
+	QueryPassword      = "Password"
+	QueryScrollable    = "Scrollable"
+	QuerySelected      = "Selected"
+	QueryVisible       = "Visible"
+
+	QueryIndex = "Index"
+
+	QueryBoundsEqual  = "BoundsEqual"
+	QueryBoundsInside = "BoundsInside"
+)
@@ -0,0 +1,140 @@
+package rpc
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// Version 是原生库的版本。
+// getVersion 返回一个整数，其编码方式（是否可拆分为主、次、修订版本号）没有文档说明，
+// 因此只保留原始值，按数值大小比较新旧。
+type Version struct {
+	// Raw 为 getVersion 的原始返回值
+	Raw int
+}
+
+// ParseVersion 解析 getVersion 的返回值
+func ParseVersion(raw int) Version {
+	return Version{Raw: raw}
+}
+
+func (v Version) String() string {
+	return strconv.Itoa(v.Raw)
+}
+
+// Compare 按原始值比较两个版本，v 较低时返回 -1，相同返回 0，较高返回 1
+func (v Version) Compare(other Version) int {
+	return cmp.Compare(v.Raw, other.Raw)
+}
+
+// AtLeast 判断版本的原始值是否不低于 raw
+func (v Version) AtLeast(raw int) bool {
+	return v.Raw >= raw
+}
+
+// Capabilities 是当前后端支持的功能，由 Client.Capabilities 探测
+type Capabilities struct {
+	Version Version
+
+	// 以下字段表示对应的导出函数是否存在
+	VideoStream       bool // startVideoStream / stopVideoStream
+	Capture           bool // takeCaptrue
+	CaptureEx         bool // takeCaptrueEx
+	CaptureCompressEx bool // takeCaptrueCompressEx
+	ScreenshotEx      bool // screentshotEx 及其他拼写
+	DumpNodeXmlEx     bool // dumpNodeXmlEx
+	NewNodeMode       bool // useNewNodeMode
+	DisplayRotate     bool // getDisplayRotate
+
+	// Missing 为缺失的可选导出函数
+	Missing []string
+}
+
+// Has 判断导出函数是否存在
+func (c Capabilities) Has(proc string) bool {
+	return !slices.Contains(c.Missing, proc)
+}
+
+// Require 在任一导出函数缺失时返回 ErrUnsupported
+func (c Capabilities) Require(procs ...string) error {
+	for _, proc := range procs {
+		if !c.Has(proc) {
+			return c.unsupported(proc, nil)
+		}
+	}
+	return nil
+}
+
+// unsupported 返回带版本信息的 ErrUnsupported，cause 通常为 ErrSymbolMissing
+func (c Capabilities) unsupported(proc string, cause error) error {
+	if cause == nil {
+		cause = symbolMissing(proc)
+	}
+	return fmt.Errorf("%w: %s unsupported by library version %s (当前库版本不支持): %w", ErrUnsupported, proc, c.Version, cause)
+}
+
+// newCapabilities 根据版本和缺失的导出函数构造 Capabilities
+func newCapabilities(raw int, missing []string) Capabilities {
+	c := Capabilities{Version: ParseVersion(raw), Missing: missing}
+	c.VideoStream = c.Has("startVideoStream") && c.Has("stopVideoStream")
+	c.Capture = c.Has("takeCaptrue")
+	c.CaptureEx = c.Has("takeCaptrueEx")
+	c.CaptureCompressEx = c.Has("takeCaptrueCompressEx")
+	c.ScreenshotEx = c.Has("screentshotEx")
+	c.DumpNodeXmlEx = c.Has("dumpNodeXmlEx")
+	c.NewNodeMode = c.Has("useNewNodeMode")
+	c.DisplayRotate = c.Has("getDisplayRotate")
+	return c
+}
+
+// Capabilities 探测后端支持的功能和库版本，结果在后端不变时缓存。
+// 后端实现了 MissingSymbols() []string 时据此判断导出函数是否存在，否则视为全部支持。
+func (c *Client) Capabilities() (Capabilities, error) {
+	c.mu.Lock()
+	if c.caps != nil {
+		caps := *c.caps
+		c.mu.Unlock()
+		return caps, nil
+	}
+	backend, direct, closed := c.backend, c.direct, c.closed
+	c.mu.Unlock()
+
+	switch {
+	case closed:
+		return Capabilities{}, ErrClosed
+	case backend == nil:
+		return Capabilities{}, fmt.Errorf("%w: library not loaded (原生库未加载)", ErrNotConnected)
+	}
+
+	raw, err := direct.GetVersion()
+	if err != nil {
+		return Capabilities{}, err
+	}
+	var missing []string
+	if m, ok := backend.(interface{ MissingSymbols() []string }); ok {
+		missing = m.MissingSymbols()
+	}
+	caps := newCapabilities(raw, missing)
+
+	c.mu.Lock()
+	if c.backend == backend {
+		c.caps = &caps
+	}
+	c.mu.Unlock()
+	return caps, nil
+}
+
+// explain 把缺失导出函数的错误转换为带库版本的 ErrUnsupported
+func (c *Client) explain(proc string, err error) error {
+	if !errors.Is(err, ErrSymbolMissing) || errors.Is(err, ErrUnsupported) {
+		return err
+	}
+	caps, capsErr := c.Capabilities()
+	if capsErr != nil {
+		return err
+	}
+	return caps.unsupported(proc, err)
+}
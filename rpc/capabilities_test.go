@@ -0,0 +1,187 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// partialBackend 模拟缺少部分可选导出函数的原生库，未覆盖的方法不会被调用
+type partialBackend struct {
+	Backend
+
+	version  int
+	missing  []string
+	versions atomic.Int32
+}
+
+func (b *partialBackend) OpenDevice(host string, port int, timeout int) (uintptr, error) {
+	return 1, nil
+}
+
+func (b *partialBackend) CloseDevice(handle uintptr) error { return nil }
+
+func (b *partialBackend) CheckLive(handle uintptr) (bool, error) { return true, nil }
+
+func (b *partialBackend) GetVersion() (int, error) {
+	b.versions.Add(1)
+	return b.version, nil
+}
+
+func (b *partialBackend) MissingSymbols() []string { return b.missing }
+
+// ScreenshotEx 与原生库一样在导出函数缺失时返回 ErrSymbolMissing
+func (b *partialBackend) ScreenshotEx(handle uintptr, left, top, right, bottom int, imgType int, quality int, path string) error {
+	return symbolMissing("screentshotEx")
+}
+
+func (b *partialBackend) ExecCmd(handle uintptr, wait bool, cmd string) (string, error) {
+	return "", &NativeCallError{Proc: "execCmd"}
+}
+
+func TestVersionCompare(t *testing.T) {
+	tests := []struct {
+		a, b int
+		want int
+	}{
+		{10203, 10203, 0},
+		{10203, 10300, -1},
+		{10300, 10203, 1},
+		{0, 1, -1},
+	}
+	for _, tt := range tests {
+		a, b := ParseVersion(tt.a), ParseVersion(tt.b)
+		if got := a.Compare(b); got != tt.want {
+			t.Errorf("%v.Compare(%v) = %d, want %d", a, b, got, tt.want)
+		}
+		if got := a.AtLeast(tt.b); got != (tt.want >= 0) {
+			t.Errorf("%v.AtLeast(%d) = %v", a, tt.b, got)
+		}
+	}
+	if s := ParseVersion(10203).String(); s != "10203" {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestNewCapabilities(t *testing.T) {
+	tests := []struct {
+		name    string
+		missing []string
+		check   func(Capabilities) bool
+	}{
+		{name: "all", check: func(c Capabilities) bool {
+			return c.VideoStream && c.Capture && c.CaptureEx && c.CaptureCompressEx && c.ScreenshotEx &&
+				c.DumpNodeXmlEx && c.NewNodeMode && c.DisplayRotate
+		}},
+		// 视频流需要开始和停止两个函数
+		{name: "no stop", missing: []string{"stopVideoStream"}, check: func(c Capabilities) bool {
+			return !c.VideoStream && c.Capture && c.DumpNodeXmlEx
+		}},
+		{name: "old library", missing: []string{"takeCaptrueCompressEx", "dumpNodeXmlEx", "useNewNodeMode", "getDisplayRotate"}, check: func(c Capabilities) bool {
+			return c.VideoStream && c.CaptureEx && !c.CaptureCompressEx && !c.DumpNodeXmlEx && !c.NewNodeMode && !c.DisplayRotate
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCapabilities(10203, tt.missing)
+			if !tt.check(c) {
+				t.Fatalf("capabilities = %+v", c)
+			}
+			for _, proc := range tt.missing {
+				err := c.Require("getVersion", proc)
+				if !errors.Is(err, ErrUnsupported) || !errors.Is(err, ErrSymbolMissing) || CodeOf(err) != CodeUnsupported {
+					t.Fatalf("Require(%s) = %v, want ErrUnsupported", proc, err)
+				}
+				if want := proc + " unsupported by library version 10203"; !strings.Contains(err.Error(), want) {
+					t.Fatalf("Require(%s) = %v, want %q", proc, err, want)
+				}
+			}
+			if err := c.Require("getVersion", "execCmd"); err != nil {
+				t.Fatalf("Require of present functions = %v", err)
+			}
+		})
+	}
+}
+
+func TestMissingSymbolsUnsupported(t *testing.T) {
+	fake := &partialBackend{version: 10203, missing: []string{"screentshotEx", "dumpNodeXmlEx"}}
+	client := NewClientWithBackend(fake, WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	defer client.Close()
+	if err := client.Connect("sim", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := client.MissingSymbols(); !slices.Equal(got, fake.missing) {
+		t.Fatalf("MissingSymbols() = %v, want %v", got, fake.missing)
+	}
+	caps, err := client.Capabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caps.ScreenshotEx || caps.DumpNodeXmlEx || !caps.VideoStream || caps.Version.Raw != 10203 {
+		t.Fatalf("capabilities = %+v", caps)
+	}
+
+	// 经过客户端的调用把 ErrSymbolMissing 转换为带库版本的 ErrUnsupported，
+	// 探测结果只取一次
+	err = client.Backend().ScreenshotEx(client.GetHandle(), 0, 0, 10, 10, 0, 80, "a.jpg")
+	if !errors.Is(err, ErrUnsupported) || !errors.Is(err, ErrSymbolMissing) || CodeOf(err) != CodeUnsupported {
+		t.Fatalf("ScreenshotEx = %v, want ErrUnsupported wrapping ErrSymbolMissing", err)
+	}
+	if want := "screentshotEx unsupported by library version 10203"; !strings.Contains(err.Error(), want) {
+		t.Fatalf("ScreenshotEx = %v, want %q", err, want)
+	}
+	if n := fake.versions.Load(); n != 1 {
+		t.Fatalf("getVersion called %d times, want the capabilities cached", n)
+	}
+
+	// 其他错误原样返回
+	_, err = client.Backend().ExecCmd(client.GetHandle(), true, "ls")
+	var native *NativeCallError
+	if !errors.As(err, &native) || errors.Is(err, ErrUnsupported) {
+		t.Fatalf("ExecCmd = %v, want the native error unchanged", err)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	fake := &partialBackend{version: 7}
+	client := NewClientWithBackend(fake)
+	defer client.Close()
+	missing := symbolMissing("dumpNodeXmlEx")
+	already := fmt.Errorf("%w: dumpNodeXmlEx: %w", ErrUnsupported, missing)
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "missing", err: missing, want: "mytrpc: unsupported by native library (原生库不支持): dumpNodeXmlEx unsupported by library version 7 (当前库版本不支持): " + missing.Error()},
+		{name: "wrapped", err: fmt.Errorf("dump: %w", missing), want: "unsupported by library version 7"},
+		// 已经是 ErrUnsupported 时不再重复包装
+		{name: "already unsupported", err: already, want: already.Error()},
+		{name: "other", err: ErrTimeout, want: ErrTimeout.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := client.explain("dumpNodeXmlEx", tt.err)
+			if !errors.Is(got, tt.err) || !strings.Contains(got.Error(), tt.want) {
+				t.Fatalf("explain = %v, want %q", got, tt.want)
+			}
+			if strings.Count(got.Error(), "unsupported by library version") > 1 {
+				t.Fatalf("explain wrapped twice: %v", got)
+			}
+		})
+	}
+
+	// 无法探测版本时保留原来的错误
+	closed := NewClientWithBackend(fake)
+	closed.Close()
+	if got := closed.explain("dumpNodeXmlEx", missing); got != missing {
+		t.Fatalf("explain after Close = %v, want the original error", got)
+	}
+}
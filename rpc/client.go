@@ -1,145 +1,414 @@
 package rpc
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
-	"path/filepath"
-	"runtime"
-	"syscall"
+	"strconv"
+	"sync"
 	"time"
-	"unsafe"
 )
 
+// Client 是到一台设备的连接，可以被多个 goroutine 同时使用。
+//
+// 同一客户端上的原生调用按调用顺序逐个执行，不会并发进入原生库；
+// 由多次调用组成的操作（如长按的按下与抬起）之间仍可能穿插其他 goroutine 的调用。
+// Close 会等待正在执行的调用结束，之后的调用返回 ErrClosed。
 type Client struct {
-	dll    *syscall.DLL
-	handle uintptr
+	opts        options
+	backend     Backend
+	ownsBackend bool
+
+	// lifecycle 串行化 Connect 与 Close
+	lifecycle sync.Mutex
+
+	// mu 保护以下状态以及 inflight 的计数
+	mu       sync.Mutex
+	handle   uintptr
+	host     string
+	port     int
+	closed   bool
+	state    ConnState
+	calls    Backend
+	inflight sync.WaitGroup
+
+	// caps 为探测结果缓存，更换后端时清空
+	caps *Capabilities
+
+	// direct 只串行化调用，供 Connect、保活检查和重连使用，不受关闭与重连状态的限制，
+	// 经过的调用标记为 Call.Internal
+	direct Backend
+
+	// reconnected 在重连结束时关闭，stop 在 Close 时关闭
+	reconnected chan struct{}
+	stop        chan struct{}
+	kick        chan struct{}
+	supervising chan struct{}
+
+	// callMu 保证同一时间只有一个原生调用，同时保护 recorder
+	callMu   sync.Mutex
+	recorder *Recorder
 }
 
-// NewClient 创建新的RPC客户端
-func NewClient() *Client {
-	return &Client{}
+// NewClient 创建新的RPC客户端，未通过 WithBackend 指定后端时在连接时加载原生库
+//
+//	client := rpc.NewClient(rpc.WithLibraryPath("/opt/myt/libmytrpc.so"), rpc.WithConnectTimeout(5*time.Second))
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		opts: newOptions(opts),
+		stop: make(chan struct{}),
+		kick: make(chan struct{}, 1),
+	}
+	c.setBackend(c.opts.backend)
+	return c
+}
+
+// NewClientWithBackend 使用指定的后端创建RPC客户端，可用于替换传输方式或注入测试替身
+func NewClientWithBackend(backend Backend, opts ...Option) *Client {
+	return NewClient(append(opts, WithBackend(backend))...)
 }
 
-// getDLLPath 获取DLL文件路径
-func getDLLPath() string {
-	// 根据操作系统选择库文件
-	var libName string
-	switch runtime.GOOS {
-	case "windows":
-		libName = "libmytrpc.dll"
-	case "linux":
-		libName = "libmytrpc.so"
-	case "darwin":
-		libName = "libmytrpc.dylib"
-	default:
-		libName = "libmytrpc.dll"
+// setBackend 设置后端并构建对外的调用视图，所有调用都经过 guard
+func (c *Client) setBackend(backend Backend) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.backend = backend
+	c.calls, c.direct, c.caps = nil, nil, nil
+	if backend != nil {
+		c.calls = &hookedBackend{inner: backend, hook: c.guard}
+		c.direct = &hookedBackend{inner: backend, hook: c.internal}
 	}
+}
+
+// internal 标记客户端自己发起的调用后串行执行
+func (c *Client) internal(call *Call, invoke func() error) error {
+	call.Internal = true
+	return c.serial(call, invoke)
+}
 
-	// 尝试从当前目录的lib文件夹加载
-	libPath := filepath.Join("lib", libName)
-	fmt.Println("libPath:", libPath)
-	if _, err := os.Stat(libPath); err == nil {
-		return libPath
+// guard 拒绝关闭后的调用，重连期间按配置等待或拒绝，并串行执行原生调用
+func (c *Client) guard(call *Call, invoke func() error) error {
+	c.mu.Lock()
+	if err := c.waitUsable(); err != nil {
+		c.mu.Unlock()
+		return err
 	}
+	c.inflight.Add(1)
+	c.mu.Unlock()
+	defer c.inflight.Done()
 
-	// 获取当前执行文件所在目录
-	exePath, err := os.Executable()
-	fmt.Println("exePath:", exePath)
-	if err != nil {
-		return "./lib/libmytrpc.dll"
+	err := c.serial(call, invoke)
+	if errors.Is(err, ErrSymbolMissing) {
+		return c.explain(call.Proc, err)
+	}
+	if err != nil && !errors.Is(err, ErrClosed) {
+		// 调用失败可能是连接已断开，让监控尽快检查
+		select {
+		case c.kick <- struct{}{}:
+		default:
+		}
 	}
-	exeDir := filepath.Dir(exePath)
+	return err
+}
 
-	// 如果当前目录没有，则尝试从可执行文件目录加载
-	return filepath.Join(exeDir, "lib", libName)
+// waitUsable 在持有 mu 时调用，重连期间按配置等待重连结束，返回时仍持有 mu
+func (c *Client) waitUsable() error {
+	var timeout <-chan time.Time
+	for {
+		if c.closed {
+			return ErrClosed
+		}
+		if c.state != StateReconnecting {
+			return nil
+		}
+		if c.opts.reconnectWait <= 0 {
+			return fmt.Errorf("%w: reconnecting (正在重连)", ErrNotConnected)
+		}
+		if timeout == nil {
+			timer := time.NewTimer(c.opts.reconnectWait)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		reconnected := c.reconnected
+		c.mu.Unlock()
+		select {
+		case <-reconnected:
+		case <-c.stop:
+		case <-timeout:
+			c.mu.Lock()
+			return fmt.Errorf("%w: reconnect not finished in %v (等待重连超时)", ErrTimeout, c.opts.reconnectWait)
+		}
+		c.mu.Lock()
+	}
 }
 
-// Connect 连接到设备
-func (c *Client) Connect(host string, port int) error {
-	// 加载DLL
-	var err error
-	dllPath := getDLLPath()
-	fmt.Println("正在加载DLL:", dllPath)
-	c.dll, err = syscall.LoadDLL(dllPath)
-	if err != nil {
-		return fmt.Errorf("加载DLL失败(%s): %v", dllPath, err)
+// serial 串行执行原生调用，依次经过拦截器和记录器
+func (c *Client) serial(call *Call, invoke func() error) error {
+	c.mu.Lock()
+	call.Device = c.address()
+	c.mu.Unlock()
+
+	c.callMu.Lock()
+	defer c.callMu.Unlock()
+	if r := c.recorder; r != nil {
+		native := invoke
+		invoke = func() error { return r.hook(call, native) }
 	}
+	return chain(call, c.opts.interceptors, invoke)()
+}
 
-	// 获取openDevice函数
-	openDeviceProc, err := c.dll.FindProc("openDevice")
+// address 在持有 mu 时返回设备地址，尚未连接时为空
+func (c *Client) address() string {
+	if c.host == "" {
+		return ""
+	}
+	return net.JoinHostPort(c.host, strconv.Itoa(c.port))
+}
+
+// StartRecording 将之后的每次原生调用记录到文件，可通过 LoadReplay 回放。
+// 在 Connect 之前调用才能记录到 openDevice。
+func (c *Client) StartRecording(path string) error {
+	f, err := os.Create(path)
 	if err != nil {
-		return fmt.Errorf("查找openDevice函数失败: %v", err)
+		return fmt.Errorf("mytrpc: create recording file (创建记录文件失败): %w", err)
+	}
+	c.callMu.Lock()
+	defer c.callMu.Unlock()
+	if err := c.stopRecording(); err != nil {
+		f.Close()
+		return err
 	}
+	c.recorder = NewRecorder(f)
+	return nil
+}
 
-	// 转换host为UTF-8字节数组
-	hostBytes := []byte(host + "\x00") // 添加null终止符
+// StopRecording 停止记录并关闭记录文件
+func (c *Client) StopRecording() error {
+	c.callMu.Lock()
+	defer c.callMu.Unlock()
+	return c.stopRecording()
+}
 
-	// 调用openDevice函数
-	ret, _, err := openDeviceProc.Call(
-		uintptr(unsafe.Pointer(&hostBytes[0])), // 传递字节数组指针
-		uintptr(port),
-		uintptr(10), // timeout
-	)
+func (c *Client) stopRecording() error {
+	if c.recorder == nil {
+		return nil
+	}
+	r := c.recorder
+	c.recorder = nil
+	if err := r.Err(); err != nil {
+		r.Close()
+		return fmt.Errorf("mytrpc: write recording (写入记录失败): %w", err)
+	}
+	return r.Close()
+}
 
-	if ret == 0 {
-		return fmt.Errorf("连接设备失败")
+// Connect 连接到设备，并轮询 checkLive 直到设备就绪或超时。
+// 通过 WithKeepalive 开启保活时，连接成功后启动后台监控，断线后自动重连。
+func (c *Client) Connect(host string, port int) error {
+	c.lifecycle.Lock()
+	defer c.lifecycle.Unlock()
+	if c.isClosed() {
+		return ErrClosed
 	}
 
-	// 保存handle
-	c.handle = ret
+	// 未指定后端时加载原生库
+	if c.backend == nil {
+		backend, err := loadBackend(c.opts)
+		if err != nil {
+			return err
+		}
+		c.setBackend(backend)
+		c.ownsBackend = true
+	}
 
-	// 等待连接建立
-	fmt.Printf("正在连接设备 %s:%d...\n", host, port)
-	time.Sleep(1 * time.Second)
+	// 重复连接时先断开旧的连接
+	c.mu.Lock()
+	old := c.handle
+	c.handle = 0
+	c.mu.Unlock()
+	if old != 0 {
+		c.direct.CloseDevice(old)
+	}
 
+	c.mu.Lock()
+	c.host, c.port = host, port
+	device := c.address()
+	c.mu.Unlock()
+
+	c.setState(StateConnecting, nil, 0)
+	c.opts.logger.Info("正在连接设备", "device", device)
+	handle, err := c.dial(host, port)
+	if err != nil {
+		c.setState(StateDisconnected, err, 0)
+		return err
+	}
+
+	c.mu.Lock()
+	c.handle = handle
+	c.mu.Unlock()
+	c.setState(StateConnected, nil, 0)
+
+	c.mu.Lock()
+	if c.opts.keepalive > 0 && c.supervising == nil && !c.closed {
+		c.supervising = make(chan struct{})
+		go c.supervise(c.supervising)
+	}
+	c.mu.Unlock()
 	return nil
 }
 
+// dial 打开设备并轮询 checkLive 直到就绪，超时或客户端关闭时释放句柄
+func (c *Client) dial(host string, port int) (uintptr, error) {
+	deadline := time.Now().Add(c.opts.connectTimeout)
+
+	// openDevice 的超时参数以秒为单位
+	timeoutSec := int((c.opts.connectTimeout + time.Second - 1) / time.Second)
+	handle, err := c.direct.OpenDevice(host, port, timeoutSec)
+	if err != nil {
+		return 0, fmt.Errorf("%w: connect %s:%d (连接设备失败): %w", ErrNotConnected, host, port, err)
+	}
+
+	// 等待连接建立
+	for {
+		live, err := c.direct.CheckLive(handle)
+		if err == nil && live {
+			return handle, nil
+		}
+		if time.Now().After(deadline) {
+			c.direct.CloseDevice(handle)
+			return 0, fmt.Errorf("%w: device %s:%d not ready after %v (等待设备就绪超时)", ErrTimeout, host, port, c.opts.connectTimeout)
+		}
+		select {
+		case <-c.stop:
+			c.direct.CloseDevice(handle)
+			return 0, ErrClosed
+		case <-time.After(c.opts.readyInterval):
+		}
+	}
+}
+
 // GetSDKVersion 获取SDK版本
 func (c *Client) GetSDKVersion() (string, error) {
-	proc, err := c.dll.FindProc("getVersion")
-	if err != nil {
-		return "", fmt.Errorf("查找getVersion函数失败: %v", err)
+	c.mu.Lock()
+	backend := c.calls
+	switch {
+	case c.closed:
+		backend = closedClient
+	case backend == nil:
+		backend = notConnected
 	}
+	c.mu.Unlock()
 
-	ret, _, err := proc.Call()
+	ret, err := backend.GetVersion()
+	if err != nil {
+		return "", err
+	}
 	if ret == 0 {
-		return "", fmt.Errorf("获取版本失败")
+		return "", &NativeCallError{Proc: "getVersion"}
 	}
 
 	return fmt.Sprintf("%d", ret), nil
 }
 
-// CheckConnectState 检查连接状态
+// CheckConnectState 检查连接状态，未连接时返回 ErrNotConnected，关闭后返回 ErrClosed
 func (c *Client) CheckConnectState() (bool, error) {
-	proc, err := c.dll.FindProc("checkLive")
-	if err != nil {
-		return false, fmt.Errorf("查找checkLive函数失败: %v", err)
-	}
-
-	ret, _, err := proc.Call(uintptr(c.handle))
-	return ret != 0, nil
+	// 未连接和已关闭时 view 返回的后端会拒绝调用并给出对应的错误
+	backend, handle := c.view()
+	return backend.CheckLive(handle)
 }
 
-// Close 关闭客户端连接
+// Close 拒绝新的调用，等待正在执行的调用结束后关闭设备连接，
+// 并释放由客户端自己加载的原生库。重复调用 Close 是安全的。
 func (c *Client) Close() error {
-	if c.handle != 0 {
-		if closeProc, err := c.dll.FindProc("closeDevice"); err == nil {
-			closeProc.Call(uintptr(c.handle))
-		}
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.stop)
+	}
+	supervising := c.supervising
+	c.mu.Unlock()
+
+	// 先等待监控退出，重连过程会持有 lifecycle
+	if supervising != nil {
+		<-supervising
 	}
-	if c.dll != nil {
-		return c.dll.Release()
+	c.lifecycle.Lock()
+	defer c.lifecycle.Unlock()
+	c.inflight.Wait()
+	defer c.setState(StateClosed, nil, 0)
+
+	c.mu.Lock()
+	backend, direct, handle := c.backend, c.direct, c.handle
+	c.handle = 0
+	c.mu.Unlock()
+
+	if handle != 0 {
+		// 原生库的视频流回调是全局的，关闭设备前清除，避免之后的推流被占用
+		releaseStream(handle)
+		direct.CloseDevice(handle)
+	}
+	if err := c.StopRecording(); err != nil {
+		return err
+	}
+	if backend == nil {
+		return nil
+	}
+	// 只释放由客户端自己加载的原生库，且只释放一次
+	if closer, ok := backend.(io.Closer); ok && c.ownsBackend {
+		c.ownsBackend = false
+		return closer.Close()
 	}
 	return nil
 }
 
-// GetDLL 获取DLL实例
-func (c *Client) GetDLL() *syscall.DLL {
-	return c.dll
+// Done 返回在 Close 开始时关闭的通道，可用于结束依赖该客户端的后台任务
+func (c *Client) Done() <-chan struct{} {
+	return c.stop
+}
+
+// isClosed 返回客户端是否已关闭
+func (c *Client) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// view 返回当前的调用视图和设备句柄，配置了 WithReconnectWait 时会等待重连结束
+func (c *Client) view() (Backend, uintptr) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.waitUsable(); errors.Is(err, ErrClosed) {
+		return closedClient, 0
+	}
+	if c.handle == 0 {
+		return notConnected, 0
+	}
+	return c.calls, c.handle
+}
+
+// Backend 获取原生调用后端，可以被多个 goroutine 同时使用。
+// 未连接时返回的后端对所有调用返回 ErrNotConnected，关闭后返回 ErrClosed。
+func (c *Client) Backend() Backend {
+	backend, _ := c.view()
+	return backend
+}
+
+// MissingSymbols 返回原生库中缺失的可选导出函数，后端无法提供时返回nil
+func (c *Client) MissingSymbols() []string {
+	c.mu.Lock()
+	backend := c.backend
+	c.mu.Unlock()
+	if b, ok := backend.(interface{ MissingSymbols() []string }); ok {
+		return b.MissingSymbols()
+	}
+	return nil
 }
 
 // GetHandle 获取设备句柄
 func (c *Client) GetHandle() uintptr {
-	return c.handle
+	_, handle := c.view()
+	return handle
 }
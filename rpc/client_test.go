@@ -0,0 +1,293 @@
+package rpc_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mytrpc/rpc"
+	"mytrpc/sim"
+)
+
+// blockingBackend 在 execCmd 中记录并发数，block 不为空时等待其关闭后才返回
+type blockingBackend struct {
+	*sim.Device
+
+	active    atomic.Int32
+	maxActive atomic.Int32
+	entered   chan struct{}
+	block     chan struct{}
+	closed    atomic.Bool
+}
+
+func newBlockingBackend() *blockingBackend {
+	return &blockingBackend{Device: sim.New(), entered: make(chan struct{}, 1)}
+}
+
+func (b *blockingBackend) ExecCmd(handle uintptr, wait bool, cmd string) (string, error) {
+	n := b.active.Add(1)
+	defer b.active.Add(-1)
+	for {
+		m := b.maxActive.Load()
+		if n <= m || b.maxActive.CompareAndSwap(m, n) {
+			break
+		}
+	}
+	select {
+	case b.entered <- struct{}{}:
+	default:
+	}
+	if b.block != nil {
+		<-b.block
+	} else {
+		time.Sleep(time.Millisecond)
+	}
+	return b.Device.ExecCmd(handle, wait, cmd)
+}
+
+func (b *blockingBackend) CloseDevice(handle uintptr) error {
+	b.closed.Store(true)
+	return b.Device.CloseDevice(handle)
+}
+
+func connect(t *testing.T, backend rpc.Backend) *rpc.Client {
+	t.Helper()
+	client := rpc.NewClientWithBackend(backend)
+	if err := client.Connect("sim", 0); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestClientSerializesCalls(t *testing.T) {
+	fake := newBlockingBackend()
+	client := connect(t, fake)
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				if _, err := client.Backend().ExecCmd(client.GetHandle(), true, "echo"); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := fake.maxActive.Load(); n != 1 {
+		t.Fatalf("native calls overlapped: %d at once", n)
+	}
+}
+
+func TestClientCloseWaitsForInflightCall(t *testing.T) {
+	fake := newBlockingBackend()
+	fake.block = make(chan struct{})
+	client := connect(t, fake)
+	backend, handle := client.Backend(), client.GetHandle()
+
+	callErr := make(chan error, 1)
+	go func() {
+		_, err := backend.ExecCmd(handle, true, "sleep")
+		callErr <- err
+	}()
+	<-fake.entered
+
+	closed := make(chan error, 1)
+	go func() { closed <- client.Close() }()
+	select {
+	case err := <-closed:
+		t.Fatalf("Close returned %v while a call was in flight", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if fake.closed.Load() {
+		t.Fatal("closeDevice called while a call was in flight")
+	}
+
+	close(fake.block)
+	if err := <-callErr; err != nil {
+		t.Fatalf("in-flight call: %v", err)
+	}
+	if err := <-closed; err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !fake.closed.Load() {
+		t.Fatal("closeDevice not called")
+	}
+}
+
+func TestClientCallsAfterCloseReturnErrClosed(t *testing.T) {
+	fake := newBlockingBackend()
+	client := connect(t, fake)
+	// 关闭前取得的后端同样要拒绝调用
+	stale, handle := client.Backend(), client.GetHandle()
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := stale.ExecCmd(handle, true, "echo"); !errors.Is(err, rpc.ErrClosed) {
+		t.Errorf("stale backend: err = %v, want ErrClosed", err)
+	}
+	if _, err := client.Backend().ExecCmd(handle, true, "echo"); !errors.Is(err, rpc.ErrClosed) {
+		t.Errorf("Backend(): err = %v, want ErrClosed", err)
+	}
+	if _, err := client.CheckConnectState(); !errors.Is(err, rpc.ErrClosed) {
+		t.Errorf("CheckConnectState: err = %v, want ErrClosed", err)
+	}
+	if h := client.GetHandle(); h != 0 {
+		t.Errorf("GetHandle() = %d after Close", h)
+	}
+	if n := fake.maxActive.Load(); n != 0 {
+		t.Errorf("%d native calls reached the backend after Close", n)
+	}
+	if err := client.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+// slowStart 在前 notLive 次 checkLive 返回未就绪，并记录每次检查的时间和 closeDevice 次数
+type slowStart struct {
+	*sim.Device
+
+	notLive int
+	mu      sync.Mutex
+	checks  []time.Time
+	closes  int
+}
+
+func (s *slowStart) CheckLive(handle uintptr) (bool, error) {
+	s.mu.Lock()
+	s.checks = append(s.checks, time.Now())
+	n := len(s.checks)
+	s.mu.Unlock()
+	if n <= s.notLive {
+		return false, nil
+	}
+	return s.Device.CheckLive(handle)
+}
+
+func (s *slowStart) CloseDevice(handle uintptr) error {
+	s.mu.Lock()
+	s.closes++
+	s.mu.Unlock()
+	return s.Device.CloseDevice(handle)
+}
+
+func (s *slowStart) counts() (checks []time.Time, closes int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]time.Time(nil), s.checks...), s.closes
+}
+
+func TestConnectPollsUntilLive(t *testing.T) {
+	fake := &slowStart{Device: sim.New(), notLive: 3}
+	client := rpc.NewClientWithBackend(fake,
+		rpc.WithConnectTimeout(5*time.Second),
+		rpc.WithReadyPollInterval(20*time.Millisecond),
+		rpc.WithLogger(quietLogger))
+	t.Cleanup(func() { client.Close() })
+	if err := client.Connect("sim", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// 就绪前按轮询间隔检查，就绪后保留句柄
+	checks, closes := fake.counts()
+	if len(checks) != 4 {
+		t.Fatalf("checkLive called %d times, want 4", len(checks))
+	}
+	for i := 1; i < len(checks); i++ {
+		if gap := checks[i].Sub(checks[i-1]); gap < 20*time.Millisecond {
+			t.Errorf("check %d came %v after the previous one, want at least the poll interval", i, gap)
+		}
+	}
+	if closes != 0 || client.GetHandle() == 0 || client.State() != rpc.StateConnected {
+		t.Fatalf("closes %d, handle %d, state %v after connecting", closes, client.GetHandle(), client.State())
+	}
+}
+
+func TestConnectReadyTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		timeout time.Duration
+	}{
+		{name: "option", timeout: 100 * time.Millisecond},
+		// MYTRPC_CONNECT_TIMEOUT 覆盖选项中的超时
+		{name: "env", env: "100ms", timeout: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(rpc.EnvConnectTimeout, tt.env)
+			fake := &slowStart{Device: sim.New(), notLive: 1 << 30}
+			client := rpc.NewClientWithBackend(fake,
+				rpc.WithConnectTimeout(tt.timeout),
+				rpc.WithReadyPollInterval(10*time.Millisecond),
+				rpc.WithLogger(quietLogger))
+			t.Cleanup(func() { client.Close() })
+
+			start := time.Now()
+			err := client.Connect("sim", 0)
+			elapsed := time.Since(start)
+			if !errors.Is(err, rpc.ErrTimeout) {
+				t.Fatalf("err = %v, want ErrTimeout", err)
+			}
+			if elapsed < 100*time.Millisecond || elapsed > 2*time.Second {
+				t.Fatalf("Connect failed after %v, want about 100ms", elapsed)
+			}
+			// 超时后释放打开的句柄
+			checks, closes := fake.counts()
+			if len(checks) < 2 || closes != 1 {
+				t.Fatalf("checkLive called %d times, closeDevice %d times", len(checks), closes)
+			}
+			if client.GetHandle() != 0 || client.State() != rpc.StateDisconnected {
+				t.Fatalf("handle %d, state %v after the timeout", client.GetHandle(), client.State())
+			}
+		})
+	}
+}
+
+func TestConnectUnreachable(t *testing.T) {
+	fake := &slowStart{Device: sim.New()}
+	fake.SetReachable(false)
+	client := rpc.NewClientWithBackend(fake, rpc.WithConnectTimeout(time.Second), rpc.WithLogger(quietLogger))
+	t.Cleanup(func() { client.Close() })
+
+	// openDevice 失败时直接返回，不再轮询
+	start := time.Now()
+	if err := client.Connect("sim", 0); !errors.Is(err, rpc.ErrNotConnected) {
+		t.Fatalf("err = %v, want ErrNotConnected", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("Connect failed after %v, want immediately", d)
+	}
+	if checks, closes := fake.counts(); len(checks) != 0 || closes != 0 {
+		t.Fatalf("checkLive called %d times, closeDevice %d times", len(checks), closes)
+	}
+
+	// 恢复可达后可以重新连接
+	fake.SetReachable(true)
+	if err := client.Connect("sim", 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConnectCloseWhilePolling(t *testing.T) {
+	fake := &slowStart{Device: sim.New(), notLive: 1 << 30}
+	client := rpc.NewClientWithBackend(fake,
+		rpc.WithConnectTimeout(time.Minute),
+		rpc.WithReadyPollInterval(10*time.Millisecond),
+		rpc.WithLogger(quietLogger))
+	time.AfterFunc(50*time.Millisecond, func() { client.Close() })
+
+	if err := client.Connect("sim", 0); !errors.Is(err, rpc.ErrClosed) {
+		t.Fatalf("err = %v, want ErrClosed", err)
+	}
+	if _, closes := fake.counts(); closes != 1 {
+		t.Fatalf("closeDevice called %d times, want the polled handle released once", closes)
+	}
+}
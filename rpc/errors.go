@@ -0,0 +1,159 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// Code 是稳定的错误码，重试等逻辑应通过 CodeOf 或 errors.Is 判断，而不是匹配错误信息
+type Code int
+
+const (
+	CodeUnknown       Code = 0
+	CodeNotConnected  Code = 1001
+	CodeNotFound      Code = 1002
+	CodeSymbolMissing Code = 1003
+	CodeTimeout       Code = 1004
+	CodeNativeCall    Code = 1005
+	CodeLibraryLoad   Code = 1006
+	CodeInvalidHandle Code = 1007
+	CodeReplay        Code = 1008
+	CodeClosed        Code = 1009
+	CodeUnsupported   Code = 1010
+	CodeStreamBusy    Code = 1011
+)
+
+var codeNames = map[Code]string{
+	CodeUnknown:       "UNKNOWN",
+	CodeNotConnected:  "NOT_CONNECTED",
+	CodeNotFound:      "NOT_FOUND",
+	CodeSymbolMissing: "SYMBOL_MISSING",
+	CodeTimeout:       "TIMEOUT",
+	CodeNativeCall:    "NATIVE_CALL",
+	CodeLibraryLoad:   "LIBRARY_LOAD",
+	CodeInvalidHandle: "INVALID_HANDLE",
+	CodeReplay:        "REPLAY_MISMATCH",
+	CodeClosed:        "CLOSED",
+	CodeUnsupported:   "UNSUPPORTED",
+	CodeStreamBusy:    "STREAM_BUSY",
+}
+
+func (c Code) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("CODE_%d", int(c))
+}
+
+// Error 是带错误码的SDK错误，错误信息为中英双语
+//
+// 错误码相同的 *Error 之间 errors.Is 判定为相等，因此回放等场景重建的错误同样可以匹配哨兵错误。
+type Error struct {
+	Code    Code
+	Message string // 英文说明
+	Zh      string // 中文说明
+}
+
+func (e *Error) Error() string {
+	if e.Zh == "" {
+		return "mytrpc: " + e.Message
+	}
+	return "mytrpc: " + e.Message + " (" + e.Zh + ")"
+}
+
+// Is 按错误码匹配
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// ErrorCode 返回错误码
+func (e *Error) ErrorCode() Code {
+	return e.Code
+}
+
+// 哨兵错误，配合 errors.Is 使用
+var (
+	ErrNotConnected  = &Error{Code: CodeNotConnected, Message: "device not connected", Zh: "设备未连接"}
+	ErrNotFound      = &Error{Code: CodeNotFound, Message: "not found", Zh: "未找到"}
+	ErrSymbolMissing = &Error{Code: CodeSymbolMissing, Message: "native symbol missing", Zh: "原生库缺少函数"}
+	ErrTimeout       = &Error{Code: CodeTimeout, Message: "timed out", Zh: "超时"}
+	ErrNativeCall    = &Error{Code: CodeNativeCall, Message: "native call failed", Zh: "原生调用失败"}
+	ErrLibraryLoad   = &Error{Code: CodeLibraryLoad, Message: "failed to load native library", Zh: "加载原生库失败"}
+	ErrInvalidHandle = &Error{Code: CodeInvalidHandle, Message: "invalid handle", Zh: "无效的句柄"}
+	ErrReplay        = &Error{Code: CodeReplay, Message: "replay mismatch", Zh: "回放记录不匹配"}
+	ErrClosed        = &Error{Code: CodeClosed, Message: "client closed", Zh: "客户端已关闭"}
+	ErrUnsupported   = &Error{Code: CodeUnsupported, Message: "unsupported by native library", Zh: "原生库不支持"}
+	ErrStreamBusy    = &Error{Code: CodeStreamBusy, Message: "video stream already running", Zh: "视频流已被占用"}
+)
+
+// NativeCallError 表示原生函数返回了失败值
+type NativeCallError struct {
+	// Proc 为原生导出函数名
+	Proc string `json:"proc"`
+	// Ret 为原生函数的返回值
+	Ret uintptr `json:"ret"`
+	// Errno 为调用后的系统错误码，0 表示没有
+	Errno syscall.Errno `json:"errno,omitempty"`
+}
+
+func (e *NativeCallError) Error() string {
+	msg := fmt.Sprintf("mytrpc: native call %s failed (原生调用%s失败): ret=%d", e.Proc, e.Proc, e.Ret)
+	if e.Errno != 0 {
+		msg += fmt.Sprintf(", errno=%d(%v)", uintptr(e.Errno), e.Errno)
+	}
+	return msg
+}
+
+// Is 使 errors.Is(err, ErrNativeCall) 成立
+func (e *NativeCallError) Is(target error) bool {
+	return target == ErrNativeCall
+}
+
+// Unwrap 返回系统错误码（如果有）
+func (e *NativeCallError) Unwrap() error {
+	if e.Errno != 0 {
+		return e.Errno
+	}
+	return nil
+}
+
+// ErrorCode 返回错误码
+func (e *NativeCallError) ErrorCode() Code {
+	return CodeNativeCall
+}
+
+// CodeOf 返回错误链中第一个带错误码的错误的错误码，err 为 nil 或没有错误码时返回 CodeUnknown
+func CodeOf(err error) Code {
+	var coded interface{ ErrorCode() Code }
+	if errors.As(err, &coded) {
+		return coded.ErrorCode()
+	}
+	return CodeUnknown
+}
+
+// codedError 保留原始错误信息和错误码，用于回放时重建记录的错误
+type codedError struct {
+	code Code
+	msg  string
+}
+
+func (e *codedError) Error() string {
+	return e.msg
+}
+
+// Is 按错误码匹配哨兵错误
+func (e *codedError) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.code
+}
+
+func (e *codedError) ErrorCode() Code {
+	return e.code
+}
+
+// symbolMissing 返回缺少导出函数的错误
+func symbolMissing(name string) error {
+	return fmt.Errorf("%w: %s", ErrSymbolMissing, name)
+}
@@ -0,0 +1,157 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+)
+
+func TestErrorMatching(t *testing.T) {
+	native := &NativeCallError{Proc: "findNodes", Ret: 0}
+	withErrno := &NativeCallError{Proc: "openDevice", Ret: 0, Errno: syscall.ECONNREFUSED}
+
+	tests := []struct {
+		name  string
+		err   error
+		is    []error
+		isNot []error
+		code  Code
+		// asError 为 errors.As 取得的 *Error 的错误码，0 表示取不到
+		asError Code
+		// asNative 为 errors.As 取得的 *NativeCallError 的函数名，空表示取不到
+		asNative string
+	}{
+		{
+			name:    "sentinel",
+			err:     ErrNotFound,
+			is:      []error{ErrNotFound},
+			isNot:   []error{ErrTimeout, ErrNativeCall},
+			code:    CodeNotFound,
+			asError: CodeNotFound,
+		},
+		{
+			name:    "wrapped twice",
+			err:     fmt.Errorf("find node (查找节点失败): %w", fmt.Errorf("%w: after 3s", ErrTimeout)),
+			is:      []error{ErrTimeout},
+			isNot:   []error{ErrNotFound},
+			code:    CodeTimeout,
+			asError: CodeTimeout,
+		},
+		{
+			// 错误码相同即匹配，与信息无关
+			name:    "same code",
+			err:     fmt.Errorf("wrap: %w", &Error{Code: CodeNotConnected, Message: "link down"}),
+			is:      []error{ErrNotConnected},
+			isNot:   []error{ErrClosed},
+			code:    CodeNotConnected,
+			asError: CodeNotConnected,
+		},
+		{
+			// 多个 %w 时 CodeOf 取第一个
+			name:    "unsupported and missing",
+			err:     fmt.Errorf("%w: screentshotEx (当前库版本不支持): %w", ErrUnsupported, symbolMissing("screentshotEx")),
+			is:      []error{ErrUnsupported, ErrSymbolMissing},
+			isNot:   []error{ErrNativeCall},
+			code:    CodeUnsupported,
+			asError: CodeUnsupported,
+		},
+		{
+			name:     "native call",
+			err:      fmt.Errorf("find nodes (查找节点失败): %w", native),
+			is:       []error{ErrNativeCall, native},
+			isNot:    []error{ErrNotFound, ErrTimeout},
+			code:     CodeNativeCall,
+			asNative: "findNodes",
+		},
+		{
+			// 系统错误码可以通过 errors.Is 匹配
+			name:     "native call with errno",
+			err:      fmt.Errorf("%w", withErrno),
+			is:       []error{ErrNativeCall, syscall.ECONNREFUSED},
+			isNot:    []error{syscall.ENOENT},
+			code:     CodeNativeCall,
+			asNative: "openDevice",
+		},
+		{
+			// 外层的 *Error 先于内层的 NativeCallError
+			name:     "sentinel around native call",
+			err:      fmt.Errorf("%w: %w", ErrNotConnected, native),
+			is:       []error{ErrNotConnected, ErrNativeCall},
+			code:     CodeNotConnected,
+			asError:  CodeNotConnected,
+			asNative: "findNodes",
+		},
+		{
+			// 回放重建的错误只有信息和错误码
+			name:  "replayed",
+			err:   fmt.Errorf("replay: %w", &codedError{code: CodeInvalidHandle, msg: "mytrpc: invalid handle: node 7"}),
+			is:    []error{ErrInvalidHandle},
+			isNot: []error{ErrNotFound},
+			code:  CodeInvalidHandle,
+		},
+		{
+			name:  "plain",
+			err:   fmt.Errorf("wrap: %w", context.DeadlineExceeded),
+			is:    []error{context.DeadlineExceeded},
+			isNot: []error{ErrTimeout},
+			code:  CodeUnknown,
+		},
+		{
+			name: "nil",
+			code: CodeUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, target := range tt.is {
+				if !errors.Is(tt.err, target) {
+					t.Errorf("errors.Is(%v, %v) = false", tt.err, target)
+				}
+			}
+			for _, target := range tt.isNot {
+				if errors.Is(tt.err, target) {
+					t.Errorf("errors.Is(%v, %v) = true", tt.err, target)
+				}
+			}
+			if got := CodeOf(tt.err); got != tt.code {
+				t.Errorf("CodeOf = %v, want %v", got, tt.code)
+			}
+
+			var e *Error
+			if ok := errors.As(tt.err, &e); ok != (tt.asError != 0) || ok && e.Code != tt.asError {
+				t.Errorf("errors.As(*Error) = %v %v, want code %v", ok, e, tt.asError)
+			}
+			var nc *NativeCallError
+			if ok := errors.As(tt.err, &nc); ok != (tt.asNative != "") || ok && nc.Proc != tt.asNative {
+				t.Errorf("errors.As(*NativeCallError) = %v %v, want proc %q", ok, nc, tt.asNative)
+			}
+		})
+	}
+}
+
+func TestErrorStrings(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{ErrTimeout, "mytrpc: timed out (超时)"},
+		{&Error{Code: CodeNotFound, Message: "no such node"}, "mytrpc: no such node"},
+		{&NativeCallError{Proc: "keyPress", Ret: 0}, "mytrpc: native call keyPress failed (原生调用keyPress失败): ret=0"},
+		{&NativeCallError{Proc: "openDevice", Ret: 1, Errno: syscall.ENOENT}, fmt.Sprintf("mytrpc: native call openDevice failed (原生调用openDevice失败): ret=1, errno=%d(%v)", uintptr(syscall.ENOENT), syscall.ENOENT)},
+		{symbolMissing("screentshotEx"), "mytrpc: native symbol missing (原生库缺少函数): screentshotEx"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+
+	if got := CodeStreamBusy.String(); got != "STREAM_BUSY" {
+		t.Errorf("CodeStreamBusy = %s", got)
+	}
+	if got := Code(42).String(); got != "CODE_42" {
+		t.Errorf("Code(42) = %s", got)
+	}
+}
@@ -0,0 +1,450 @@
+package rpc
+
+import "time"
+
+// Call 描述一次经过客户端的原生调用
+type Call struct {
+	// Proc 为原生导出函数名，如 keyPress、TextContainWith
+	Proc string
+	// Device 为设备地址 host:port，不经过客户端的调用为空
+	Device string
+	// Args 为调用参数，查询条件类调用不含查询名称
+	Args []any
+	// Results 为除错误外的返回值
+	Results []any
+	// Err 为调用返回的错误
+	Err      error
+	Start    time.Time
+	Duration time.Duration
+	// Internal 表示调用由客户端自己发起（连接、保活检查、重连、关闭和功能探测），不是使用者的操作
+	Internal bool
+}
+
+// hookFunc 包裹一次原生调用，invoke 执行实际调用并填充 call 的结果与耗时
+type hookFunc func(call *Call, invoke func() error) error
+
+// hookedBackend 把每个 Backend 方法统一成 Call 交给 hook 处理，
+// 用于记录、回放等需要感知所有原生调用的场景
+type hookedBackend struct {
+	inner Backend
+	hook  hookFunc
+}
+
+// notConnected 在客户端未连接时代替后端，拒绝所有调用
+var notConnected Backend = &hookedBackend{
+	hook: func(*Call, func() error) error { return ErrNotConnected },
+}
+
+// closedClient 在客户端关闭后代替后端，拒绝所有调用
+var closedClient Backend = &hookedBackend{
+	hook: func(*Call, func() error) error { return ErrClosed },
+}
+
+func (b *hookedBackend) do(proc string, args []any, fn func(*Call) error) error {
+	call := &Call{Proc: proc, Args: args}
+	return b.hook(call, func() error {
+		call.Start = time.Now()
+		call.Err = fn(call)
+		call.Duration = time.Since(call.Start)
+		return call.Err
+	})
+}
+
+func (b *hookedBackend) OpenDevice(host string, port int, timeout int) (ret uintptr, err error) {
+	err = b.do("openDevice", []any{host, port, timeout}, func(c *Call) error {
+		var err error
+		ret, err = b.inner.OpenDevice(host, port, timeout)
+		c.Results = []any{ret}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) CloseDevice(handle uintptr) error {
+	return b.do("closeDevice", []any{handle}, func(*Call) error {
+		return b.inner.CloseDevice(handle)
+	})
+}
+
+func (b *hookedBackend) CheckLive(handle uintptr) (ok bool, err error) {
+	err = b.do("checkLive", []any{handle}, func(c *Call) error {
+		var err error
+		ok, err = b.inner.CheckLive(handle)
+		c.Results = []any{ok}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) GetVersion() (n int, err error) {
+	err = b.do("getVersion", nil, func(c *Call) error {
+		var err error
+		n, err = b.inner.GetVersion()
+		c.Results = []any{n}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) UseNewNodeMode(handle uintptr, mode int) error {
+	return b.do("useNewNodeMode", []any{handle, mode}, func(*Call) error {
+		return b.inner.UseNewNodeMode(handle, mode)
+	})
+}
+
+func (b *hookedBackend) TakeCaptrueCompress(handle uintptr, imgType int, quality int) (data []byte, err error) {
+	err = b.do("takeCaptrueCompress", []any{handle, imgType, quality}, func(c *Call) error {
+		var err error
+		data, err = b.inner.TakeCaptrueCompress(handle, imgType, quality)
+		c.Results = []any{data}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) TakeCaptrueCompressEx(handle uintptr, left int, top int, right int, bottom int, imgType int, quality int) (data []byte, err error) {
+	err = b.do("takeCaptrueCompressEx", []any{handle, left, top, right, bottom, imgType, quality}, func(c *Call) error {
+		var err error
+		data, err = b.inner.TakeCaptrueCompressEx(handle, left, top, right, bottom, imgType, quality)
+		c.Results = []any{data}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) TakeCaptrue(handle uintptr) (pix []byte, width int, height int, stride int, err error) {
+	err = b.do("takeCaptrue", []any{handle}, func(c *Call) error {
+		var err error
+		pix, width, height, stride, err = b.inner.TakeCaptrue(handle)
+		c.Results = []any{pix, width, height, stride}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) TakeCaptrueEx(handle uintptr, left int, top int, right int, bottom int) (pix []byte, width int, height int, stride int, err error) {
+	err = b.do("takeCaptrueEx", []any{handle, left, top, right, bottom}, func(c *Call) error {
+		var err error
+		pix, width, height, stride, err = b.inner.TakeCaptrueEx(handle, left, top, right, bottom)
+		c.Results = []any{pix, width, height, stride}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) ScreenshotEx(handle uintptr, left int, top int, right int, bottom int, imgType int, quality int, path string) error {
+	return b.do("screentshotEx", []any{handle, left, top, right, bottom, imgType, quality, path}, func(*Call) error {
+		return b.inner.ScreenshotEx(handle, left, top, right, bottom, imgType, quality, path)
+	})
+}
+
+func (b *hookedBackend) StartVideoStream(handle uintptr, width int, height int, bitrate int, onVideo VideoCallback, onAudio AudioCallback) error {
+	// 回调无法记录，参数只包含推流设置
+	return b.do("startVideoStream", []any{handle, width, height, bitrate}, func(*Call) error {
+		return b.inner.StartVideoStream(handle, width, height, bitrate, onVideo, onAudio)
+	})
+}
+
+func (b *hookedBackend) StopVideoStream(handle uintptr) error {
+	return b.do("stopVideoStream", []any{handle}, func(*Call) error {
+		return b.inner.StopVideoStream(handle)
+	})
+}
+
+func (b *hookedBackend) GetDisplayRotate(handle uintptr) (n int, err error) {
+	err = b.do("getDisplayRotate", []any{handle}, func(c *Call) error {
+		var err error
+		n, err = b.inner.GetDisplayRotate(handle)
+		c.Results = []any{n}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) KeyPress(handle uintptr, code int) error {
+	return b.do("keyPress", []any{handle, code}, func(*Call) error {
+		return b.inner.KeyPress(handle, code)
+	})
+}
+
+func (b *hookedBackend) TouchDown(handle uintptr, fingerID int, x int, y int) error {
+	return b.do("touchDown", []any{handle, fingerID, x, y}, func(*Call) error {
+		return b.inner.TouchDown(handle, fingerID, x, y)
+	})
+}
+
+func (b *hookedBackend) TouchUp(handle uintptr, fingerID int, x int, y int) error {
+	return b.do("touchUp", []any{handle, fingerID, x, y}, func(*Call) error {
+		return b.inner.TouchUp(handle, fingerID, x, y)
+	})
+}
+
+func (b *hookedBackend) TouchMove(handle uintptr, fingerID int, x int, y int) error {
+	return b.do("touchMove", []any{handle, fingerID, x, y}, func(*Call) error {
+		return b.inner.TouchMove(handle, fingerID, x, y)
+	})
+}
+
+func (b *hookedBackend) TouchClick(handle uintptr, fingerID int, x int, y int) error {
+	return b.do("touchClick", []any{handle, fingerID, x, y}, func(*Call) error {
+		return b.inner.TouchClick(handle, fingerID, x, y)
+	})
+}
+
+func (b *hookedBackend) Swipe(handle uintptr, fingerID int, x0 int, y0 int, x1 int, y1 int, duration int, async bool) error {
+	return b.do("swipe", []any{handle, fingerID, x0, y0, x1, y1, duration, async}, func(*Call) error {
+		return b.inner.Swipe(handle, fingerID, x0, y0, x1, y1, duration, async)
+	})
+}
+
+func (b *hookedBackend) SendText(handle uintptr, text string) error {
+	return b.do("sendText", []any{handle, text}, func(*Call) error {
+		return b.inner.SendText(handle, text)
+	})
+}
+
+func (b *hookedBackend) ExecCmd(handle uintptr, wait bool, cmd string) (s string, err error) {
+	err = b.do("execCmd", []any{handle, wait, cmd}, func(c *Call) error {
+		var err error
+		s, err = b.inner.ExecCmd(handle, wait, cmd)
+		c.Results = []any{s}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) OpenApp(handle uintptr, packageName string) error {
+	return b.do("openApp", []any{handle, packageName}, func(*Call) error {
+		return b.inner.OpenApp(handle, packageName)
+	})
+}
+
+func (b *hookedBackend) StopApp(handle uintptr, packageName string) error {
+	return b.do("stopApp", []any{handle, packageName}, func(*Call) error {
+		return b.inner.StopApp(handle, packageName)
+	})
+}
+
+func (b *hookedBackend) DumpNodeXml(handle uintptr, dumpAll bool) (s string, err error) {
+	err = b.do("dumpNodeXml", []any{handle, dumpAll}, func(c *Call) error {
+		var err error
+		s, err = b.inner.DumpNodeXml(handle, dumpAll)
+		c.Results = []any{s}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) DumpNodeXmlEx(handle uintptr, workMode bool, timeout int) (s string, err error) {
+	err = b.do("dumpNodeXmlEx", []any{handle, workMode, timeout}, func(c *Call) error {
+		var err error
+		s, err = b.inner.DumpNodeXmlEx(handle, workMode, timeout)
+		c.Results = []any{s}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) NewSelector(handle uintptr) (ret uintptr, err error) {
+	err = b.do("newSelector", []any{handle}, func(c *Call) error {
+		var err error
+		ret, err = b.inner.NewSelector(handle)
+		c.Results = []any{ret}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) ClearSelector(selector uintptr) error {
+	return b.do("clearSelector", []any{selector}, func(*Call) error {
+		return b.inner.ClearSelector(selector)
+	})
+}
+
+func (b *hookedBackend) FreeSelector(selector uintptr) error {
+	return b.do("freeSelector", []any{selector}, func(*Call) error {
+		return b.inner.FreeSelector(selector)
+	})
+}
+
+func (b *hookedBackend) AddStringQuery(selector uintptr, query string, value string) error {
+	return b.do(query, []any{selector, value}, func(*Call) error {
+		return b.inner.AddStringQuery(selector, query, value)
+	})
+}
+
+func (b *hookedBackend) AddBoolQuery(selector uintptr, query string, value bool) error {
+	return b.do(query, []any{selector, value}, func(*Call) error {
+		return b.inner.AddBoolQuery(selector, query, value)
+	})
+}
+
+func (b *hookedBackend) AddIntQuery(selector uintptr, query string, value int) error {
+	return b.do(query, []any{selector, value}, func(*Call) error {
+		return b.inner.AddIntQuery(selector, query, value)
+	})
+}
+
+func (b *hookedBackend) AddBoundsQuery(selector uintptr, query string, left int, top int, right int, bottom int) error {
+	return b.do(query, []any{selector, left, top, right, bottom}, func(*Call) error {
+		return b.inner.AddBoundsQuery(selector, query, left, top, right, bottom)
+	})
+}
+
+func (b *hookedBackend) FindNodes(selector uintptr, maxNode int, timeout int) (ret uintptr, err error) {
+	err = b.do("findNodes", []any{selector, maxNode, timeout}, func(c *Call) error {
+		var err error
+		ret, err = b.inner.FindNodes(selector, maxNode, timeout)
+		c.Results = []any{ret}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) GetNodesSize(nodes uintptr) (n int, err error) {
+	err = b.do("getNodesSize", []any{nodes}, func(c *Call) error {
+		var err error
+		n, err = b.inner.GetNodesSize(nodes)
+		c.Results = []any{n}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) GetNodeByIndex(nodes uintptr, index int) (ret uintptr, err error) {
+	err = b.do("getNodeByIndex", []any{nodes, index}, func(c *Call) error {
+		var err error
+		ret, err = b.inner.GetNodeByIndex(nodes, index)
+		c.Results = []any{ret}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) FreeNodes(nodes uintptr) error {
+	return b.do("freeNodes", []any{nodes}, func(*Call) error {
+		return b.inner.FreeNodes(nodes)
+	})
+}
+
+func (b *hookedBackend) ClickNode(node uintptr) error {
+	return b.do("clickNode", []any{node}, func(*Call) error {
+		return b.inner.ClickNode(node)
+	})
+}
+
+func (b *hookedBackend) LongClickNode(node uintptr) error {
+	return b.do("longClickNode", []any{node}, func(*Call) error {
+		return b.inner.LongClickNode(node)
+	})
+}
+
+func (b *hookedBackend) GetNodeBound(node uintptr) (left int, top int, right int, bottom int, err error) {
+	err = b.do("getNodeNound", []any{node}, func(c *Call) error {
+		var err error
+		left, top, right, bottom, err = b.inner.GetNodeBound(node)
+		c.Results = []any{left, top, right, bottom}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) GetNodeBoundCenter(node uintptr) (x int, y int, err error) {
+	err = b.do("getNodeNoundCenter", []any{node}, func(c *Call) error {
+		var err error
+		x, y, err = b.inner.GetNodeBoundCenter(node)
+		c.Results = []any{x, y}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) GetNodeText(node uintptr) (s string, err error) {
+	err = b.do("getNodeText", []any{node}, func(c *Call) error {
+		var err error
+		s, err = b.inner.GetNodeText(node)
+		c.Results = []any{s}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) GetNodeDesc(node uintptr) (s string, err error) {
+	err = b.do("getNodeDesc", []any{node}, func(c *Call) error {
+		var err error
+		s, err = b.inner.GetNodeDesc(node)
+		c.Results = []any{s}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) GetNodePackage(node uintptr) (s string, err error) {
+	err = b.do("getNodePackage", []any{node}, func(c *Call) error {
+		var err error
+		s, err = b.inner.GetNodePackage(node)
+		c.Results = []any{s}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) GetNodeClass(node uintptr) (s string, err error) {
+	err = b.do("getNodeClass", []any{node}, func(c *Call) error {
+		var err error
+		s, err = b.inner.GetNodeClass(node)
+		c.Results = []any{s}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) GetNodeId(node uintptr) (s string, err error) {
+	err = b.do("getNodeId", []any{node}, func(c *Call) error {
+		var err error
+		s, err = b.inner.GetNodeId(node)
+		c.Results = []any{s}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) GetNodeJson(node uintptr) (s string, err error) {
+	err = b.do("getNodeJson", []any{node}, func(c *Call) error {
+		var err error
+		s, err = b.inner.GetNodeJson(node)
+		c.Results = []any{s}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) GetNodeParent(node uintptr) (ret uintptr, err error) {
+	err = b.do("getNodeParent", []any{node}, func(c *Call) error {
+		var err error
+		ret, err = b.inner.GetNodeParent(node)
+		c.Results = []any{ret}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) GetNodeChildCount(node uintptr) (n int, err error) {
+	err = b.do("getNodeChildCount", []any{node}, func(c *Call) error {
+		var err error
+		n, err = b.inner.GetNodeChildCount(node)
+		c.Results = []any{n}
+		return err
+	})
+	return
+}
+
+func (b *hookedBackend) GetNodeChild(node uintptr, index int) (ret uintptr, err error) {
+	err = b.do("getNodeChild", []any{node, index}, func(c *Call) error {
+		var err error
+		ret, err = b.inner.GetNodeChild(node, index)
+		c.Results = []any{ret}
+		return err
+	})
+	return
+}
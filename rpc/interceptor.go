@@ -0,0 +1,181 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Interceptor 包裹经过客户端的每次原生调用，next 执行后续拦截器及实际调用，
+// 并填充 call 的返回值、错误和耗时。拦截器可以观察调用，也可以替换返回的错误。
+//
+// 通过 WithInterceptors 注册的拦截器按注册顺序由外向内执行，
+// 调用在客户端的调用锁内进行，拦截器不应阻塞过久。
+type Interceptor func(call *Call, next func() error) error
+
+// chain 把拦截器依次包在 invoke 外层
+func chain(call *Call, interceptors []Interceptor, invoke func() error) func() error {
+	next := invoke
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		ic, inner := interceptors[i], next
+		next = func() error { return ic(call, inner) }
+	}
+	return next
+}
+
+// LoggingInterceptor 使用 slog 记录每次调用，成功时为 Debug 级别，失败时为 Warn 级别。
+// 截图数据等字节切片只记录长度，过长的字符串会被截断。
+func LoggingInterceptor(logger *slog.Logger) Interceptor {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(call *Call, next func() error) error {
+		err := next()
+		level := slog.LevelDebug
+		if err != nil {
+			level = slog.LevelWarn
+		}
+		logger.LogAttrs(context.Background(), level, "mytrpc call",
+			slog.String("proc", call.Proc),
+			slog.String("device", call.Device),
+			slog.Any("args", logValues(call.Args)),
+			slog.Any("results", logValues(call.Results)),
+			slog.Duration("duration", call.Duration),
+			slog.Any("error", err),
+		)
+		return err
+	}
+}
+
+// maxLogString 为日志中字符串参数的最大长度
+const maxLogString = 256
+
+// logValues 把参数转换为适合写入日志的形式
+func logValues(values []any) []any {
+	if len(values) == 0 {
+		return nil
+	}
+	out := make([]any, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case []byte:
+			out[i] = fmt.Sprintf("<%d bytes>", len(v))
+		case string:
+			if len(v) > maxLogString {
+				v = v[:maxLogString] + "..."
+			}
+			out[i] = v
+		default:
+			out[i] = v
+		}
+	}
+	return out
+}
+
+// DefaultLatencyBuckets 为 LatencyHistogram 默认的分桶上界
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 500 * time.Millisecond, time.Second, 5 * time.Second, 10 * time.Second,
+}
+
+// LatencyHistogram 按原生函数统计调用耗时分布，可以被多个客户端共享
+type LatencyHistogram struct {
+	bounds []time.Duration
+
+	mu    sync.Mutex
+	procs map[string]*HistogramSnapshot
+}
+
+// HistogramSnapshot 是一个原生函数的耗时统计
+type HistogramSnapshot struct {
+	// Bounds 为分桶上界，Counts[i] 为耗时不超过 Bounds[i] 的调用数（不累计），
+	// Counts 的最后一项为超过所有上界的调用数
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Errors uint64
+	Sum    time.Duration
+	Max    time.Duration
+}
+
+// Mean 返回平均耗时
+func (s HistogramSnapshot) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / time.Duration(s.Count)
+}
+
+// NewLatencyHistogram 使用给定的分桶上界创建耗时统计，未指定时使用 DefaultLatencyBuckets
+func NewLatencyHistogram(bounds ...time.Duration) *LatencyHistogram {
+	if len(bounds) == 0 {
+		bounds = DefaultLatencyBuckets
+	}
+	bounds = append([]time.Duration(nil), bounds...)
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	return &LatencyHistogram{bounds: bounds, procs: make(map[string]*HistogramSnapshot)}
+}
+
+// Intercept 统计一次调用，可作为拦截器注册：rpc.WithInterceptors(hist.Intercept)
+func (h *LatencyHistogram) Intercept(call *Call, next func() error) error {
+	err := next()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.procs[call.Proc]
+	if !ok {
+		s = &HistogramSnapshot{Bounds: h.bounds, Counts: make([]uint64, len(h.bounds)+1)}
+		h.procs[call.Proc] = s
+	}
+	i := sort.Search(len(h.bounds), func(i int) bool { return call.Duration <= h.bounds[i] })
+	s.Counts[i]++
+	s.Count++
+	s.Sum += call.Duration
+	s.Max = max(s.Max, call.Duration)
+	if err != nil {
+		s.Errors++
+	}
+	return err
+}
+
+// Snapshot 返回各原生函数当前的耗时统计副本
+func (h *LatencyHistogram) Snapshot() map[string]HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make(map[string]HistogramSnapshot, len(h.procs))
+	for proc, s := range h.procs {
+		c := *s
+		c.Counts = append([]uint64(nil), s.Counts...)
+		out[proc] = c
+	}
+	return out
+}
+
+// Tracer 创建追踪片段，接口形式参照 OpenTelemetry，便于适配到实际的追踪系统
+type Tracer interface {
+	// Start 开始名为 name 的片段，attrs 包含原生函数名和设备地址
+	Start(name string, attrs ...slog.Attr) Span
+}
+
+// Span 是一次调用对应的追踪片段
+type Span interface {
+	// End 结束片段，err 为调用返回的错误
+	End(err error)
+}
+
+// TracingInterceptor 为每次调用创建名为 "mytrpc.<函数名>" 的片段，
+// 带有 mytrpc.proc、mytrpc.device 属性
+func TracingInterceptor(tracer Tracer) Interceptor {
+	return func(call *Call, next func() error) error {
+		span := tracer.Start("mytrpc."+call.Proc,
+			slog.String("mytrpc.proc", call.Proc),
+			slog.String("mytrpc.device", call.Device),
+		)
+		err := next()
+		span.End(err)
+		return err
+	}
+}
@@ -0,0 +1,260 @@
+package rpc_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mytrpc/rpc"
+	"mytrpc/sim"
+)
+
+var errBoom = errors.New("boom")
+
+// slowCmd 让 execCmd 的 slow 命令耗时 50ms，fail 命令返回 errBoom
+type slowCmd struct {
+	*sim.Device
+}
+
+func (s *slowCmd) ExecCmd(handle uintptr, wait bool, cmd string) (string, error) {
+	switch cmd {
+	case "slow":
+		time.Sleep(50 * time.Millisecond)
+	case "fail":
+		return "", errBoom
+	}
+	return s.Device.ExecCmd(handle, wait, cmd)
+}
+
+// trace 按发生顺序记录拦截器和追踪片段的事件
+type trace struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (tr *trace) add(format string, args ...any) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.events = append(tr.events, fmt.Sprintf(format, args...))
+}
+
+func (tr *trace) take() []string {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	out := tr.events
+	tr.events = nil
+	return out
+}
+
+// mark 返回在调用前后记录事件的拦截器
+func (tr *trace) mark(name string) rpc.Interceptor {
+	return func(call *rpc.Call, next func() error) error {
+		tr.add("%s>%s", name, call.Proc)
+		err := next()
+		tr.add("%s<%s", name, call.Proc)
+		return err
+	}
+}
+
+func (tr *trace) Start(name string, attrs ...slog.Attr) rpc.Span {
+	var kv []string
+	for _, a := range attrs {
+		kv = append(kv, a.String())
+	}
+	tr.add("start %s %s", name, strings.Join(kv, " "))
+	return &span{tr: tr, name: name}
+}
+
+type span struct {
+	tr   *trace
+	name string
+}
+
+func (s *span) End(err error) {
+	s.tr.add("end %s %v", s.name, err)
+}
+
+// logEntries 解析 JSON 格式的日志
+func logEntries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	sc := bufio.NewScanner(buf)
+	for sc.Scan() {
+		var m map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatalf("log line %q: %v", sc.Text(), err)
+		}
+		out = append(out, m)
+	}
+	buf.Reset()
+	return out
+}
+
+func TestInterceptorChain(t *testing.T) {
+	tr := &trace{}
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	hist := rpc.NewLatencyHistogram(time.Second, 10*time.Millisecond, 40*time.Millisecond)
+	fake := &slowCmd{Device: sim.New()}
+	fake.SetScreenshot(make([]byte, 1234))
+
+	// 最外层把错误替换为带前缀的错误，内层的拦截器看到的是原始错误
+	replace := func(call *rpc.Call, next func() error) error {
+		if err := next(); err != nil {
+			return fmt.Errorf("outer: %w", err)
+		}
+		return nil
+	}
+	client := rpc.NewClientWithBackend(fake,
+		rpc.WithLogger(quietLogger),
+		rpc.WithInterceptors(replace, tr.mark("first"), rpc.LoggingInterceptor(logger)),
+		rpc.WithInterceptors(hist.Intercept, rpc.TracingInterceptor(tr), tr.mark("last")),
+	)
+	t.Cleanup(func() { client.Close() })
+	if err := client.Connect("sim", 0); err != nil {
+		t.Fatal(err)
+	}
+	// 连接过程中的内部调用同样经过拦截器
+	if events := tr.take(); len(events) == 0 || events[0] != "first>openDevice" {
+		t.Fatalf("connect events = %q, want openDevice first", events)
+	}
+	logEntries(t, &logs)
+	backend, handle := client.Backend(), client.GetHandle()
+
+	t.Run("order", func(t *testing.T) {
+		if _, err := backend.ExecCmd(handle, true, "echo"); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{
+			"first>execCmd",
+			"start mytrpc.execCmd mytrpc.proc=execCmd mytrpc.device=sim:0",
+			"last>execCmd",
+			"last<execCmd",
+			"end mytrpc.execCmd <nil>",
+			"first<execCmd",
+		}
+		if got := tr.take(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Fatalf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+		if entries := logEntries(t, &logs); len(entries) != 1 {
+			t.Fatalf("got %d log entries, want 1", len(entries))
+		}
+	})
+
+	t.Run("success log", func(t *testing.T) {
+		long := strings.Repeat("x", 300)
+		if _, err := backend.ExecCmd(handle, true, long); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := backend.TakeCaptrueCompress(handle, 0, 80); err != nil {
+			t.Fatal(err)
+		}
+		tr.take()
+		entries := logEntries(t, &logs)
+		if len(entries) != 2 {
+			t.Fatalf("got %d log entries, want 2", len(entries))
+		}
+		for _, e := range entries {
+			if e["level"] != "DEBUG" || e["msg"] != "mytrpc call" || e["device"] != "sim:0" || e["error"] != nil {
+				t.Errorf("entry = %v, want a debug entry without error", e)
+			}
+		}
+		// 过长的字符串截断，字节切片只记录长度
+		args := entries[0]["args"].([]any)
+		if e := entries[0]; e["proc"] != "execCmd" || len(args) != 3 || args[2] != strings.Repeat("x", 256)+"..." {
+			t.Errorf("execCmd entry = %v, want the command truncated", e)
+		}
+		if e := entries[1]; e["proc"] != "takeCaptrueCompress" || fmt.Sprint(e["results"]) != "[<1234 bytes>]" {
+			t.Errorf("screenshot entry = %v, want the length of the image", e)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := backend.ExecCmd(handle, true, "fail")
+		if !errors.Is(err, errBoom) || !strings.HasPrefix(err.Error(), "outer: ") {
+			t.Fatalf("err = %v, want the replaced error", err)
+		}
+		if events := tr.take(); len(events) != 6 || events[4] != "end mytrpc.execCmd boom" {
+			t.Fatalf("events = %q, want the span ended with the original error", events)
+		}
+		entries := logEntries(t, &logs)
+		if len(entries) != 1 {
+			t.Fatalf("got %d log entries, want 1", len(entries))
+		}
+		if e := entries[0]; e["level"] != "WARN" || e["proc"] != "execCmd" || e["error"] != "boom" {
+			t.Fatalf("entry = %v, want a warning with the original error", e)
+		}
+	})
+
+	t.Run("latency", func(t *testing.T) {
+		if _, err := backend.ExecCmd(handle, true, "slow"); err != nil {
+			t.Fatal(err)
+		}
+		tr.take()
+		entries := logEntries(t, &logs)
+		if d := time.Duration(entries[0]["duration"].(float64)); d < 50*time.Millisecond {
+			t.Fatalf("logged duration %v, want at least 50ms", d)
+		}
+
+		// 前面的 execCmd：echo、长命令、fail 都很快，slow 落在 (40ms, 1s]
+		s := hist.Snapshot()["execCmd"]
+		wantBounds := []time.Duration{10 * time.Millisecond, 40 * time.Millisecond, time.Second}
+		if fmt.Sprint(s.Bounds) != fmt.Sprint(wantBounds) {
+			t.Fatalf("bounds = %v, want sorted %v", s.Bounds, wantBounds)
+		}
+		if fmt.Sprint(s.Counts) != "[3 0 1 0]" || s.Count != 4 || s.Errors != 1 {
+			t.Fatalf("execCmd counts = %v, count %d, errors %d", s.Counts, s.Count, s.Errors)
+		}
+		if s.Max < 50*time.Millisecond || s.Sum < s.Max || s.Mean() != s.Sum/4 {
+			t.Fatalf("max %v, sum %v, mean %v", s.Max, s.Sum, s.Mean())
+		}
+		if n := hist.Snapshot()["takeCaptrueCompress"].Count; n != 1 {
+			t.Fatalf("takeCaptrueCompress counted %d times", n)
+		}
+	})
+}
+
+func TestLatencyHistogramBuckets(t *testing.T) {
+	hist := rpc.NewLatencyHistogram(10*time.Millisecond, time.Millisecond)
+	record := func(proc string, d time.Duration, err error) {
+		call := &rpc.Call{Proc: proc, Duration: d}
+		if got := hist.Intercept(call, func() error { return err }); got != err {
+			t.Fatalf("Intercept returned %v, want %v", got, err)
+		}
+	}
+	// 等于上界的耗时计入该桶，超过所有上界的计入最后一桶
+	record("a", 0, nil)
+	record("a", time.Millisecond, nil)
+	record("a", time.Millisecond+1, errBoom)
+	record("a", 10*time.Millisecond, nil)
+	record("a", time.Minute, errBoom)
+	record("b", 5*time.Millisecond, nil)
+
+	snap := hist.Snapshot()
+	a := snap["a"]
+	if fmt.Sprint(a.Counts) != "[2 2 1]" || a.Count != 5 || a.Errors != 2 || a.Max != time.Minute {
+		t.Fatalf("a = %+v", a)
+	}
+	if want := time.Minute + 12*time.Millisecond + 1; a.Sum != want {
+		t.Fatalf("a.Sum = %v, want %v", a.Sum, want)
+	}
+	if b := snap["b"]; fmt.Sprint(b.Counts) != "[0 1 0]" || b.Count != 1 {
+		t.Fatalf("b = %+v", b)
+	}
+
+	// 快照是副本，之后的调用不影响已取得的快照
+	record("a", 0, nil)
+	if a.Counts[0] != 2 || hist.Snapshot()["a"].Counts[0] != 3 {
+		t.Fatal("snapshot shares counts with the histogram")
+	}
+	if (rpc.HistogramSnapshot{}).Mean() != 0 {
+		t.Fatal("mean of an empty snapshot is not zero")
+	}
+}
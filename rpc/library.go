@@ -0,0 +1,96 @@
+package rpc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// library 表示已加载的原生动态库
+//
+// Windows 使用 syscall.LoadDLL，Linux/macOS 使用 dlopen，对上层提供同样的查找与调用方式。
+type library interface {
+	// findProc 按名称查找导出函数
+	findProc(name string) (proc, error)
+	// release 卸载动态库
+	release() error
+}
+
+// proc 表示原生库中的一个导出函数
+type proc interface {
+	// call 以整数/指针参数调用导出函数，返回值和调用后的系统错误码
+	call(args ...uintptr) (uintptr, error)
+}
+
+// libraryName 返回当前平台的原生库文件名
+func libraryName() string {
+	switch runtime.GOOS {
+	case "windows":
+		return "libmytrpc.dll"
+	case "darwin":
+		return "libmytrpc.dylib"
+	default:
+		return "libmytrpc.so"
+	}
+}
+
+// libraryCandidates 按优先级返回候选的原生库路径
+func libraryCandidates(o options) []string {
+	name := libraryName()
+	dirs := append([]string(nil), o.libraryDirs...)
+	dirs = append(dirs, "lib")
+	if exePath, err := os.Executable(); err == nil {
+		exeDir := filepath.Dir(exePath)
+		dirs = append(dirs, filepath.Join(exeDir, "lib"), exeDir)
+	}
+
+	candidates := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		candidates = append(candidates, filepath.Join(dir, name))
+	}
+	return candidates
+}
+
+// findLibrary 查找原生库：指定了路径时只使用该路径；否则依次搜索候选目录，
+// 都不存在时交给系统加载器按库名搜索（PATH、LD_LIBRARY_PATH 等）
+func findLibrary(o options) (string, error) {
+	if o.libraryPath != "" {
+		if _, err := os.Stat(o.libraryPath); err != nil {
+			return "", fmt.Errorf("%w: %s: %w", ErrLibraryLoad, o.libraryPath, err)
+		}
+		return o.libraryPath, nil
+	}
+
+	for _, path := range libraryCandidates(o) {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return libraryName(), nil
+}
+
+// LoadBackend 按 WithLibraryPath、WithLibraryDirs 查找并加载原生库。
+// 返回的后端可以通过 WithBackend 在多个客户端之间共享，避免每个客户端各自加载一次；
+// 后端实现 io.Closer，由调用方在所有使用它的客户端关闭后释放。
+func LoadBackend(opts ...Option) (Backend, error) {
+	return loadBackend(newOptions(opts))
+}
+
+// loadBackend 查找并加载原生库，缺少可选导出函数时记录警告
+func loadBackend(o options) (*nativeBackend, error) {
+	libPath, err := findLibrary(o)
+	if err != nil {
+		return nil, err
+	}
+	o.logger.Info("正在加载原生库", "path", libPath)
+	backend, err := loadNativeBackend(libPath)
+	if err != nil {
+		return nil, err
+	}
+	if missing := backend.MissingSymbols(); len(missing) > 0 {
+		o.logger.Warn("原生库缺少以下函数，相关功能不可用", "path", libPath, "missing", strings.Join(missing, ", "))
+	}
+	return backend, nil
+}
@@ -0,0 +1,23 @@
+//go:build !windows && !((linux || darwin) && cgo)
+
+package rpc
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// openLibrary 当前平台无法加载原生库，可通过 NewClientWithBackend 使用其他后端
+func openLibrary(path string) (library, error) {
+	return nil, fmt.Errorf("cgo is required on %s/%s (当前平台需要启用cgo)", runtime.GOOS, runtime.GOARCH)
+}
+
+func nativePath(path string) (unsafe.Pointer, error) {
+	return unsafe.Pointer(cString(path)), nil
+}
+
+// streamCallbacks 当前平台无法加载原生库，也就没有视频流回调
+func streamCallbacks() (video, audio uintptr, err error) {
+	return 0, 0, fmt.Errorf("%w: video stream requires cgo on %s/%s (当前平台需要启用cgo)", ErrUnsupported, runtime.GOOS, runtime.GOARCH)
+}
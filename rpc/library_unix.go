@@ -0,0 +1,92 @@
+//go:build (linux || darwin) && cgo
+
+package rpc
+
+/*
+#cgo linux LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <errno.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+#define MYTRPC_MAX_ARGS 12
+
+typedef uintptr_t (*mytrpc_fn)(uintptr_t, uintptr_t, uintptr_t, uintptr_t,
+                               uintptr_t, uintptr_t, uintptr_t, uintptr_t,
+                               uintptr_t, uintptr_t, uintptr_t, uintptr_t);
+
+// 导出函数的参数均为整数或指针，按最大参数个数调用，多余参数由调用方清理
+static uintptr_t mytrpc_call(void *fn, uintptr_t *a) {
+	errno = 0;
+	return ((mytrpc_fn)fn)(a[0], a[1], a[2], a[3], a[4], a[5],
+	                       a[6], a[7], a[8], a[9], a[10], a[11]);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// dlLibrary 基于 dlopen 的动态库实现
+type dlLibrary struct {
+	handle unsafe.Pointer
+}
+
+// openLibrary 加载动态库
+func openLibrary(path string) (library, error) {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	handle := C.dlopen(cpath, C.RTLD_NOW|C.RTLD_LOCAL)
+	if handle == nil {
+		return nil, errors.New(C.GoString(C.dlerror()))
+	}
+	return &dlLibrary{handle: handle}, nil
+}
+
+func (l *dlLibrary) findProc(name string) (proc, error) {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+
+	C.dlerror()
+	sym := C.dlsym(l.handle, cname)
+	if sym == nil {
+		if msg := C.dlerror(); msg != nil {
+			return nil, errors.New(C.GoString(msg))
+		}
+		return nil, fmt.Errorf("symbol %s not found", name)
+	}
+	return dlProc{fn: sym}, nil
+}
+
+func (l *dlLibrary) release() error {
+	if C.dlclose(l.handle) != 0 {
+		return errors.New(C.GoString(C.dlerror()))
+	}
+	return nil
+}
+
+type dlProc struct {
+	fn unsafe.Pointer
+}
+
+//go:uintptrescapes
+func (p dlProc) call(args ...uintptr) (uintptr, error) {
+	if len(args) > C.MYTRPC_MAX_ARGS {
+		return 0, fmt.Errorf("too many arguments: %d", len(args))
+	}
+	var a [C.MYTRPC_MAX_ARGS]C.uintptr_t
+	for i, arg := range args {
+		a[i] = C.uintptr_t(arg)
+	}
+	ret, err := C.mytrpc_call(p.fn, &a[0])
+	return uintptr(ret), err
+}
+
+// nativePath 转换文件路径参数，非 Windows 平台使用 UTF-8 路径
+func nativePath(path string) (unsafe.Pointer, error) {
+	return unsafe.Pointer(cString(path)), nil
+}
@@ -0,0 +1,57 @@
+//go:build windows
+
+package rpc
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// dllLibrary 基于 syscall.DLL 的动态库实现
+type dllLibrary struct {
+	dll *syscall.DLL
+}
+
+// openLibrary 加载动态库
+func openLibrary(path string) (library, error) {
+	dll, err := syscall.LoadDLL(path)
+	if err != nil {
+		return nil, err
+	}
+	return &dllLibrary{dll: dll}, nil
+}
+
+func (l *dllLibrary) findProc(name string) (proc, error) {
+	p, err := l.dll.FindProc(name)
+	if err != nil {
+		return nil, err
+	}
+	return dllProc{p: p}, nil
+}
+
+func (l *dllLibrary) release() error {
+	return l.dll.Release()
+}
+
+type dllProc struct {
+	p *syscall.Proc
+}
+
+//go:uintptrescapes
+func (p dllProc) call(args ...uintptr) (uintptr, error) {
+	ret, _, err := p.p.Call(args...)
+	// Proc.Call 总是返回非nil的错误，只有错误码非0时才有意义
+	if errno, ok := err.(syscall.Errno); ok && errno == 0 {
+		err = nil
+	}
+	return ret, err
+}
+
+// nativePath 转换文件路径参数，Windows 下原生库使用 UTF-16 路径
+func nativePath(path string) (unsafe.Pointer, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	return unsafe.Pointer(p), nil
+}
@@ -0,0 +1,420 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// nativeBackend 通过 libmytrpc 动态库实现 Backend
+type nativeBackend struct {
+	lib     library
+	sym     *symbolTable
+	missing []string
+}
+
+// loadNativeBackend 加载指定路径的动态库并解析全部导出函数
+func loadNativeBackend(path string) (*nativeBackend, error) {
+	lib, err := openLibrary(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrLibraryLoad, path, err)
+	}
+	sym, missing, err := resolveSymbols(lib)
+	if err != nil {
+		lib.release()
+		return nil, fmt.Errorf("%w: %s: %w", ErrLibraryLoad, path, err)
+	}
+	return &nativeBackend{lib: lib, sym: sym, missing: missing}, nil
+}
+
+// MissingSymbols 返回加载时未找到的可选导出函数
+func (b *nativeBackend) MissingSymbols() []string {
+	return append([]string(nil), b.missing...)
+}
+
+// Close 释放动态库
+func (b *nativeBackend) Close() error {
+	return b.lib.release()
+}
+
+// invoke 调用预先解析的导出函数，返回值之外还返回调用后的系统错误码
+//
+//go:uintptrescapes
+func (b *nativeBackend) invoke(sym *symbol, args ...uintptr) (uintptr, syscall.Errno, error) {
+	if sym.p == nil {
+		return 0, 0, symbolMissing(sym.name)
+	}
+	ret, err := sym.p.call(args...)
+	var errno syscall.Errno
+	errors.As(err, &errno)
+	return ret, errno, nil
+}
+
+// call 调用导出函数，只关心返回值
+//
+//go:uintptrescapes
+func (b *nativeBackend) call(sym *symbol, args ...uintptr) (uintptr, error) {
+	ret, _, err := b.invoke(sym, args...)
+	return ret, err
+}
+
+// callNonZero 调用返回值为0表示失败的导出函数，失败时返回 *NativeCallError
+//
+//go:uintptrescapes
+func (b *nativeBackend) callNonZero(sym *symbol, args ...uintptr) (uintptr, error) {
+	ret, errno, err := b.invoke(sym, args...)
+	if err != nil {
+		return 0, err
+	}
+	if ret == 0 {
+		return 0, &NativeCallError{Proc: sym.name, Ret: ret, Errno: errno}
+	}
+	return ret, nil
+}
+
+// callBool 调用返回值非0表示成功的导出函数
+//
+//go:uintptrescapes
+func (b *nativeBackend) callBool(sym *symbol, args ...uintptr) error {
+	_, err := b.callNonZero(sym, args...)
+	return err
+}
+
+// callString 调用返回字符串指针的导出函数，复制结果后释放原生内存
+//
+//go:uintptrescapes
+func (b *nativeBackend) callString(sym *symbol, args ...uintptr) (string, error) {
+	ptr, err := b.callNonZero(sym, args...)
+	if err != nil {
+		return "", err
+	}
+	s := goString(ptr)
+	b.free(ptr)
+	return s, nil
+}
+
+// callBytes 调用返回数据指针和长度的导出函数，复制结果后释放原生内存
+//
+//go:uintptrescapes
+func (b *nativeBackend) callBytes(sym *symbol, args ...uintptr) ([]byte, error) {
+	// 长度输出参数分配在堆上，保证调用期间地址不变
+	dataLen := new(int32)
+	ptr, err := b.callNonZero(sym, append(args, uintptr(unsafe.Pointer(dataLen)))...)
+	runtime.KeepAlive(dataLen)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, *dataLen)
+	if *dataLen > 0 {
+		copy(data, unsafe.Slice((*byte)(nativePtr(ptr)), *dataLen))
+	}
+	b.free(ptr)
+	return data, nil
+}
+
+// callPixels 调用返回像素指针的导出函数，宽、高和每行字节数通过输出参数返回，复制结果后释放原生内存
+//
+//go:uintptrescapes
+func (b *nativeBackend) callPixels(sym *symbol, args ...uintptr) ([]byte, int, int, int, error) {
+	// 输出参数分配在堆上，保证调用期间地址不变
+	dims := new([3]int32)
+	ptr, err := b.callNonZero(sym, append(args,
+		uintptr(unsafe.Pointer(&dims[0])),
+		uintptr(unsafe.Pointer(&dims[1])),
+		uintptr(unsafe.Pointer(&dims[2])),
+	)...)
+	runtime.KeepAlive(dims)
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+	defer b.free(ptr)
+
+	width, height, stride := int(dims[0]), int(dims[1]), int(dims[2])
+	if stride == 0 {
+		stride = width * 4
+	}
+	if width <= 0 || height <= 0 || stride < width*4 {
+		return nil, 0, 0, 0, fmt.Errorf("%w: %s returned invalid image %dx%d stride %d (截图尺寸无效)", ErrNativeCall, sym.name, width, height, stride)
+	}
+	pix := make([]byte, stride*height)
+	copy(pix, unsafe.Slice((*byte)(nativePtr(ptr)), len(pix)))
+	return pix, width, height, stride, nil
+}
+
+// free 释放原生库分配的内存
+func (b *nativeBackend) free(ptr uintptr) {
+	b.call(&b.sym.freeRpcPtr, ptr)
+}
+
+// cString 转换为以null结尾的字节数组
+func cString(s string) *byte {
+	bytes := []byte(s + "\x00")
+	return &bytes[0]
+}
+
+// goString 读取以null结尾的C字符串
+func goString(ptr uintptr) string {
+	p := (*byte)(nativePtr(ptr))
+	n := 0
+	for *(*byte)(unsafe.Add(unsafe.Pointer(p), n)) != 0 {
+		n++
+	}
+	return string(unsafe.Slice(p, n))
+}
+
+// nativePtr 将原生库返回的地址转换为指针，该内存不受Go GC管理
+func nativePtr(ptr uintptr) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&ptr))
+}
+
+func boolArg(v bool) uintptr {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+func (b *nativeBackend) OpenDevice(host string, port int, timeout int) (uintptr, error) {
+	return b.callNonZero(&b.sym.openDevice, uintptr(unsafe.Pointer(cString(host))), uintptr(port), uintptr(timeout))
+}
+
+func (b *nativeBackend) CloseDevice(handle uintptr) error {
+	_, err := b.call(&b.sym.closeDevice, handle)
+	return err
+}
+
+func (b *nativeBackend) CheckLive(handle uintptr) (bool, error) {
+	ret, err := b.call(&b.sym.checkLive, handle)
+	return ret != 0, err
+}
+
+func (b *nativeBackend) GetVersion() (int, error) {
+	ret, err := b.call(&b.sym.getVersion)
+	return int(ret), err
+}
+
+func (b *nativeBackend) UseNewNodeMode(handle uintptr, mode int) error {
+	return b.callBool(&b.sym.useNewNodeMode, handle, uintptr(mode))
+}
+
+func (b *nativeBackend) TakeCaptrueCompress(handle uintptr, imgType int, quality int) ([]byte, error) {
+	return b.callBytes(&b.sym.takeCaptrueCompress, handle, uintptr(imgType), uintptr(quality))
+}
+
+func (b *nativeBackend) TakeCaptrueCompressEx(handle uintptr, left, top, right, bottom int, imgType int, quality int) ([]byte, error) {
+	return b.callBytes(&b.sym.takeCaptrueCompressEx, handle,
+		uintptr(left), uintptr(top), uintptr(right), uintptr(bottom),
+		uintptr(imgType), uintptr(quality),
+	)
+}
+
+func (b *nativeBackend) TakeCaptrue(handle uintptr) ([]byte, int, int, int, error) {
+	return b.callPixels(&b.sym.takeCaptrue, handle)
+}
+
+func (b *nativeBackend) TakeCaptrueEx(handle uintptr, left, top, right, bottom int) ([]byte, int, int, int, error) {
+	return b.callPixels(&b.sym.takeCaptrueEx, handle, uintptr(left), uintptr(top), uintptr(right), uintptr(bottom))
+}
+
+func (b *nativeBackend) ScreenshotEx(handle uintptr, left, top, right, bottom int, imgType int, quality int, path string) error {
+	pathPtr, err := nativePath(path)
+	if err != nil {
+		return err
+	}
+	return b.callBool(&b.sym.screentshotEx, handle,
+		uintptr(left), uintptr(top), uintptr(right), uintptr(bottom),
+		uintptr(imgType), uintptr(quality),
+		uintptr(pathPtr),
+	)
+}
+
+func (b *nativeBackend) GetDisplayRotate(handle uintptr) (int, error) {
+	ret, err := b.call(&b.sym.getDisplayRotate, handle)
+	return int(ret), err
+}
+
+func (b *nativeBackend) KeyPress(handle uintptr, code int) error {
+	return b.callBool(&b.sym.keyPress, handle, uintptr(code))
+}
+
+func (b *nativeBackend) TouchDown(handle uintptr, fingerID int, x, y int) error {
+	return b.callBool(&b.sym.touchDown, handle, uintptr(fingerID), uintptr(x), uintptr(y))
+}
+
+func (b *nativeBackend) TouchUp(handle uintptr, fingerID int, x, y int) error {
+	return b.callBool(&b.sym.touchUp, handle, uintptr(fingerID), uintptr(x), uintptr(y))
+}
+
+func (b *nativeBackend) TouchMove(handle uintptr, fingerID int, x, y int) error {
+	return b.callBool(&b.sym.touchMove, handle, uintptr(fingerID), uintptr(x), uintptr(y))
+}
+
+func (b *nativeBackend) TouchClick(handle uintptr, fingerID int, x, y int) error {
+	return b.callBool(&b.sym.touchClick, handle, uintptr(fingerID), uintptr(x), uintptr(y))
+}
+
+func (b *nativeBackend) Swipe(handle uintptr, fingerID int, x0, y0, x1, y1 int, duration int, async bool) error {
+	return b.callBool(&b.sym.swipe, handle, uintptr(fingerID),
+		uintptr(x0), uintptr(y0), uintptr(x1), uintptr(y1),
+		uintptr(duration), boolArg(async),
+	)
+}
+
+func (b *nativeBackend) SendText(handle uintptr, text string) error {
+	return b.callBool(&b.sym.sendText, handle, uintptr(unsafe.Pointer(cString(text))))
+}
+
+func (b *nativeBackend) ExecCmd(handle uintptr, wait bool, cmd string) (string, error) {
+	return b.callString(&b.sym.execCmd, handle, boolArg(wait), uintptr(unsafe.Pointer(cString(cmd))))
+}
+
+func (b *nativeBackend) OpenApp(handle uintptr, packageName string) error {
+	return b.callBool(&b.sym.openApp, handle, uintptr(unsafe.Pointer(cString(packageName))))
+}
+
+func (b *nativeBackend) StopApp(handle uintptr, packageName string) error {
+	return b.callBool(&b.sym.stopApp, handle, uintptr(unsafe.Pointer(cString(packageName))))
+}
+
+func (b *nativeBackend) DumpNodeXml(handle uintptr, dumpAll bool) (string, error) {
+	return b.callString(&b.sym.dumpNodeXml, handle, boolArg(dumpAll))
+}
+
+func (b *nativeBackend) DumpNodeXmlEx(handle uintptr, workMode bool, timeout int) (string, error) {
+	return b.callString(&b.sym.dumpNodeXmlEx, handle, boolArg(workMode), uintptr(timeout))
+}
+
+func (b *nativeBackend) NewSelector(handle uintptr) (uintptr, error) {
+	return b.callNonZero(&b.sym.newSelector, handle)
+}
+
+func (b *nativeBackend) ClearSelector(selector uintptr) error {
+	_, err := b.call(&b.sym.clearSelector, selector)
+	return err
+}
+
+func (b *nativeBackend) FreeSelector(selector uintptr) error {
+	_, err := b.call(&b.sym.freeSelector, selector)
+	return err
+}
+
+func (b *nativeBackend) AddStringQuery(selector uintptr, query string, value string) error {
+	sym, err := b.sym.query(query)
+	if err != nil {
+		return err
+	}
+	_, err = b.call(sym, selector, uintptr(unsafe.Pointer(cString(value))))
+	return err
+}
+
+func (b *nativeBackend) AddBoolQuery(selector uintptr, query string, value bool) error {
+	sym, err := b.sym.query(query)
+	if err != nil {
+		return err
+	}
+	_, err = b.call(sym, selector, boolArg(value))
+	return err
+}
+
+func (b *nativeBackend) AddIntQuery(selector uintptr, query string, value int) error {
+	sym, err := b.sym.query(query)
+	if err != nil {
+		return err
+	}
+	_, err = b.call(sym, selector, uintptr(value))
+	return err
+}
+
+func (b *nativeBackend) AddBoundsQuery(selector uintptr, query string, left, top, right, bottom int) error {
+	sym, err := b.sym.query(query)
+	if err != nil {
+		return err
+	}
+	_, err = b.call(sym, selector, uintptr(left), uintptr(top), uintptr(right), uintptr(bottom))
+	return err
+}
+
+func (b *nativeBackend) FindNodes(selector uintptr, maxNode int, timeout int) (uintptr, error) {
+	return b.call(&b.sym.findNodes, selector, uintptr(maxNode), uintptr(timeout))
+}
+
+func (b *nativeBackend) GetNodesSize(nodes uintptr) (int, error) {
+	ret, err := b.call(&b.sym.getNodesSize, nodes)
+	return int(ret), err
+}
+
+func (b *nativeBackend) GetNodeByIndex(nodes uintptr, index int) (uintptr, error) {
+	return b.call(&b.sym.getNodeByIndex, nodes, uintptr(index))
+}
+
+func (b *nativeBackend) FreeNodes(nodes uintptr) error {
+	_, err := b.call(&b.sym.freeNodes, nodes)
+	return err
+}
+
+func (b *nativeBackend) ClickNode(node uintptr) error {
+	return b.callBool(&b.sym.clickNode, node)
+}
+
+func (b *nativeBackend) LongClickNode(node uintptr) error {
+	return b.callBool(&b.sym.longClickNode, node)
+}
+
+func (b *nativeBackend) GetNodeBound(node uintptr) (left, top, right, bottom int, err error) {
+	var l, t, r, bt int32
+	err = b.callBool(&b.sym.getNodeNound, node,
+		uintptr(unsafe.Pointer(&l)),
+		uintptr(unsafe.Pointer(&t)),
+		uintptr(unsafe.Pointer(&r)),
+		uintptr(unsafe.Pointer(&bt)),
+	)
+	return int(l), int(t), int(r), int(bt), err
+}
+
+func (b *nativeBackend) GetNodeBoundCenter(node uintptr) (x, y int, err error) {
+	var cx, cy int32
+	err = b.callBool(&b.sym.getNodeNoundCenter, node,
+		uintptr(unsafe.Pointer(&cx)),
+		uintptr(unsafe.Pointer(&cy)),
+	)
+	return int(cx), int(cy), err
+}
+
+func (b *nativeBackend) GetNodeText(node uintptr) (string, error) {
+	return b.callString(&b.sym.getNodeText, node)
+}
+
+func (b *nativeBackend) GetNodeDesc(node uintptr) (string, error) {
+	return b.callString(&b.sym.getNodeDesc, node)
+}
+
+func (b *nativeBackend) GetNodePackage(node uintptr) (string, error) {
+	return b.callString(&b.sym.getNodePackage, node)
+}
+
+func (b *nativeBackend) GetNodeClass(node uintptr) (string, error) {
+	return b.callString(&b.sym.getNodeClass, node)
+}
+
+func (b *nativeBackend) GetNodeId(node uintptr) (string, error) {
+	return b.callString(&b.sym.getNodeId, node)
+}
+
+func (b *nativeBackend) GetNodeJson(node uintptr) (string, error) {
+	return b.callString(&b.sym.getNodeJson, node)
+}
+
+func (b *nativeBackend) GetNodeParent(node uintptr) (uintptr, error) {
+	return b.call(&b.sym.getNodeParent, node)
+}
+
+func (b *nativeBackend) GetNodeChildCount(node uintptr) (int, error) {
+	ret, err := b.call(&b.sym.getNodeChildCount, node)
+	return int(ret), err
+}
+
+func (b *nativeBackend) GetNodeChild(node uintptr, index int) (uintptr, error) {
+	return b.call(&b.sym.getNodeChild, node, uintptr(index))
+}
@@ -0,0 +1,170 @@
+package rpc
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// 环境变量，设置后覆盖代码中的对应选项，便于部署时调整而无需重新编译
+const (
+	// EnvLibraryPath 指定原生库文件的完整路径
+	EnvLibraryPath = "MYTRPC_LIB"
+	// EnvLibraryDir 指定优先搜索原生库的目录
+	EnvLibraryDir = "MYTRPC_LIB_DIR"
+	// EnvConnectTimeout 指定连接超时，支持 "15s" 形式或整数秒
+	EnvConnectTimeout = "MYTRPC_CONNECT_TIMEOUT"
+)
+
+const (
+	defaultConnectTimeout = 10 * time.Second
+	defaultReadyInterval  = 100 * time.Millisecond
+	defaultBackoffMin     = 500 * time.Millisecond
+	defaultBackoffMax     = 30 * time.Second
+)
+
+// options 为客户端配置
+type options struct {
+	backend        Backend
+	libraryPath    string
+	libraryDirs    []string
+	connectTimeout time.Duration
+	readyInterval  time.Duration
+
+	keepalive     time.Duration
+	backoffMin    time.Duration
+	backoffMax    time.Duration
+	reconnectWait time.Duration
+	stateListener func(StateChange)
+
+	logger       *slog.Logger
+	interceptors []Interceptor
+}
+
+// Option 配置 Client
+type Option func(*options)
+
+// WithBackend 使用指定的后端代替原生库
+func WithBackend(backend Backend) Option {
+	return func(o *options) {
+		o.backend = backend
+	}
+}
+
+// WithLibraryPath 指定原生库文件路径，不再搜索其他位置
+func WithLibraryPath(path string) Option {
+	return func(o *options) {
+		o.libraryPath = path
+	}
+}
+
+// WithLibraryDirs 追加优先搜索原生库的目录，先于 ./lib 和可执行文件目录
+func WithLibraryDirs(dirs ...string) Option {
+	return func(o *options) {
+		o.libraryDirs = append(o.libraryDirs, dirs...)
+	}
+}
+
+// WithConnectTimeout 设置连接超时，包括 openDevice 以及等待设备就绪的时间
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.connectTimeout = timeout
+	}
+}
+
+// WithReadyPollInterval 设置连接后轮询 checkLive 的间隔
+func WithReadyPollInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.readyInterval = interval
+	}
+}
+
+// WithKeepalive 开启连接监控，每隔 interval 调用一次 checkLive，
+// 检测到断线（或调用失败后确认断线）时按退避策略重连原来的地址
+func WithKeepalive(interval time.Duration) Option {
+	return func(o *options) {
+		o.keepalive = interval
+	}
+}
+
+// WithReconnectBackoff 设置重连的退避时间，每次失败后翻倍，最长不超过 max
+func WithReconnectBackoff(min, max time.Duration) Option {
+	return func(o *options) {
+		o.backoffMin = min
+		o.backoffMax = max
+	}
+}
+
+// WithReconnectWait 设置重连期间调用的最长等待时间，等待结束后使用新的连接继续执行；
+// 为0（默认）时重连期间的调用立即返回 ErrNotConnected
+func WithReconnectWait(wait time.Duration) Option {
+	return func(o *options) {
+		o.reconnectWait = wait
+	}
+}
+
+// WithStateListener 设置连接状态变化的监听函数。
+// 监听函数在状态变化的 goroutine 中同步调用，不应阻塞或调用 Close。
+func WithStateListener(fn func(StateChange)) Option {
+	return func(o *options) {
+		o.stateListener = fn
+	}
+}
+
+// WithLogger 设置客户端的日志记录器，默认使用 slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithInterceptors 追加原生调用拦截器，先注册的在外层，
+// 内置的有 LoggingInterceptor、LatencyHistogram.Intercept 和 TracingInterceptor
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(o *options) {
+		o.interceptors = append(o.interceptors, interceptors...)
+	}
+}
+
+// newOptions 依次应用默认值、选项和环境变量
+func newOptions(opts []Option) options {
+	o := options{
+		connectTimeout: defaultConnectTimeout,
+		readyInterval:  defaultReadyInterval,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if v := os.Getenv(EnvLibraryPath); v != "" {
+		o.libraryPath = v
+	}
+	if v := os.Getenv(EnvLibraryDir); v != "" {
+		o.libraryDirs = append([]string{v}, o.libraryDirs...)
+	}
+	if v := os.Getenv(EnvConnectTimeout); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			o.connectTimeout = d
+		} else if n, err := strconv.Atoi(v); err == nil {
+			o.connectTimeout = time.Duration(n) * time.Second
+		}
+	}
+
+	if o.connectTimeout <= 0 {
+		o.connectTimeout = defaultConnectTimeout
+	}
+	if o.readyInterval <= 0 {
+		o.readyInterval = defaultReadyInterval
+	}
+	if o.logger == nil {
+		o.logger = slog.Default()
+	}
+	if o.backoffMin <= 0 {
+		o.backoffMin = defaultBackoffMin
+	}
+	if o.backoffMax < o.backoffMin {
+		o.backoffMax = max(defaultBackoffMax, o.backoffMin)
+	}
+	return o
+}
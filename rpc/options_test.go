@@ -0,0 +1,110 @@
+package rpc
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNewOptionsEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		opts        []Option
+		wantPath    string
+		wantDirs    []string
+		wantTimeout time.Duration
+	}{
+		{
+			name:        "defaults",
+			wantTimeout: defaultConnectTimeout,
+		},
+		{
+			name:        "options only",
+			opts:        []Option{WithLibraryPath("/opt/a.so"), WithLibraryDirs("d1", "d2"), WithConnectTimeout(3 * time.Second)},
+			wantPath:    "/opt/a.so",
+			wantDirs:    []string{"d1", "d2"},
+			wantTimeout: 3 * time.Second,
+		},
+		{
+			// 环境变量优先于选项，目录放在最前面
+			name:        "env overrides options",
+			env:         map[string]string{EnvLibraryPath: "/env/b.so", EnvLibraryDir: "envdir", EnvConnectTimeout: "15s"},
+			opts:        []Option{WithLibraryPath("/opt/a.so"), WithLibraryDirs("d1"), WithConnectTimeout(3 * time.Second)},
+			wantPath:    "/env/b.so",
+			wantDirs:    []string{"envdir", "d1"},
+			wantTimeout: 15 * time.Second,
+		},
+		{
+			name:        "timeout in seconds",
+			env:         map[string]string{EnvConnectTimeout: "7"},
+			wantTimeout: 7 * time.Second,
+		},
+		{
+			name:        "timeout with unit",
+			env:         map[string]string{EnvConnectTimeout: "1m30s"},
+			wantTimeout: 90 * time.Second,
+		},
+		{
+			// 无法解析时保留选项的值
+			name:        "invalid timeout",
+			env:         map[string]string{EnvConnectTimeout: "soon"},
+			opts:        []Option{WithConnectTimeout(3 * time.Second)},
+			wantTimeout: 3 * time.Second,
+		},
+		{
+			// 非正数的超时回退到默认值
+			name:        "zero timeout",
+			env:         map[string]string{EnvConnectTimeout: "0"},
+			opts:        []Option{WithConnectTimeout(3 * time.Second)},
+			wantTimeout: defaultConnectTimeout,
+		},
+		{
+			name:        "empty env ignored",
+			env:         map[string]string{EnvLibraryPath: "", EnvLibraryDir: "", EnvConnectTimeout: ""},
+			opts:        []Option{WithLibraryPath("/opt/a.so"), WithConnectTimeout(3 * time.Second)},
+			wantPath:    "/opt/a.so",
+			wantTimeout: 3 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{EnvLibraryPath, EnvLibraryDir, EnvConnectTimeout} {
+				t.Setenv(key, tt.env[key])
+			}
+			o := newOptions(tt.opts)
+			if o.libraryPath != tt.wantPath {
+				t.Errorf("libraryPath = %q, want %q", o.libraryPath, tt.wantPath)
+			}
+			if !slices.Equal(o.libraryDirs, tt.wantDirs) {
+				t.Errorf("libraryDirs = %q, want %q", o.libraryDirs, tt.wantDirs)
+			}
+			if o.connectTimeout != tt.wantTimeout {
+				t.Errorf("connectTimeout = %v, want %v", o.connectTimeout, tt.wantTimeout)
+			}
+			if o.readyInterval != defaultReadyInterval || o.logger == nil {
+				t.Errorf("readyInterval = %v, logger = %v, want the defaults", o.readyInterval, o.logger)
+			}
+		})
+	}
+}
+
+func TestFindLibraryEnv(t *testing.T) {
+	// MYTRPC_LIB_DIR 的目录排在候选路径最前面
+	dir := t.TempDir()
+	t.Setenv(EnvLibraryPath, "")
+	t.Setenv(EnvLibraryDir, dir)
+	candidates := libraryCandidates(newOptions([]Option{WithLibraryDirs("other")}))
+	if want := filepath.Join(dir, libraryName()); len(candidates) < 3 || candidates[0] != want || candidates[1] != filepath.Join("other", libraryName()) {
+		t.Fatalf("candidates = %q, want %s first", candidates, want)
+	}
+
+	// MYTRPC_LIB 指定的文件不存在时不再搜索其他目录
+	missing := filepath.Join(dir, "missing.so")
+	t.Setenv(EnvLibraryPath, missing)
+	if _, err := findLibrary(newOptions(nil)); !errors.Is(err, ErrLibraryLoad) {
+		t.Fatalf("findLibrary = %v, want ErrLibraryLoad", err)
+	}
+}
@@ -0,0 +1,105 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// recordEntry 是记录文件中的一行，每行对应一次原生调用
+type recordEntry struct {
+	Seq      int               `json:"seq"`
+	Proc     string            `json:"proc"`
+	Args     json.RawMessage   `json:"args"`
+	Results  []json.RawMessage `json:"results,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Code     Code              `json:"code,omitempty"`
+	Native   *NativeCallError  `json:"native,omitempty"`
+	Start    time.Time         `json:"start"`
+	Duration time.Duration     `json:"duration"`
+	// Internal 表示客户端自己发起的调用，次数和时机取决于连接轮询与保活的计时，回放时可以跳过
+	Internal bool `json:"internal,omitempty"`
+}
+
+// Recorder 将经过的每次原生调用以 JSON Lines 格式写入，
+// 包含函数名、参数、返回值（字符串与数据原样保存）、错误和耗时。
+type Recorder struct {
+	mu  sync.Mutex
+	w   io.Writer
+	seq int
+	err error
+}
+
+// NewRecorder 创建写入 w 的记录器
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: w}
+}
+
+// Wrap 返回记录所有调用的后端
+func (r *Recorder) Wrap(backend Backend) Backend {
+	return &hookedBackend{inner: backend, hook: r.hook}
+}
+
+func (r *Recorder) hook(call *Call, invoke func() error) error {
+	err := invoke()
+	r.write(call)
+	return err
+}
+
+func (r *Recorder) write(call *Call) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+
+	r.seq++
+	entry := recordEntry{
+		Seq:      r.seq,
+		Proc:     call.Proc,
+		Start:    call.Start,
+		Duration: call.Duration,
+		Internal: call.Internal,
+	}
+	if call.Err != nil {
+		entry.Error = call.Err.Error()
+		entry.Code = CodeOf(call.Err)
+		errors.As(call.Err, &entry.Native)
+	}
+	if entry.Args, r.err = json.Marshal(call.Args); r.err != nil {
+		return
+	}
+	for _, v := range call.Results {
+		var raw json.RawMessage
+		if raw, r.err = json.Marshal(v); r.err != nil {
+			return
+		}
+		entry.Results = append(entry.Results, raw)
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		r.err = err
+		return
+	}
+	_, r.err = r.w.Write(append(line, '\n'))
+}
+
+// Err 返回写入过程中遇到的第一个错误，出错后不再继续记录
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close 关闭底层写入对象（如果支持）
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if closer, ok := r.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
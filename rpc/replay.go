@@ -0,0 +1,373 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// ReplayBackend 按顺序回放 Recorder 记录的原生调用，实现 Backend
+//
+// 每次调用的函数名和参数必须与记录一致，否则返回错误，
+// 因此可以把一次真机会话转换为无需硬件的回归测试。
+// 客户端内部发起的调用（连接时的就绪轮询、保活检查等）次数取决于计时，
+// 回放时跳过多出的这类记录，因此回放的客户端不需要开启保活。
+type ReplayBackend struct {
+	mu      sync.Mutex
+	entries []recordEntry
+	pos     int
+}
+
+var _ Backend = (*ReplayBackend)(nil)
+
+// NewReplayBackend 从 JSON Lines 格式的记录中创建回放后端
+func NewReplayBackend(r io.Reader) (*ReplayBackend, error) {
+	var entries []recordEntry
+	scanner := bufio.NewScanner(r)
+	// 截图等数据可能较大
+	scanner.Buffer(make([]byte, 64*1024), 256*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry recordEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%w: parse line %d (解析记录失败): %w", ErrReplay, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &ReplayBackend{entries: entries}, nil
+}
+
+// LoadReplay 从记录文件创建回放后端
+func LoadReplay(path string) (*ReplayBackend, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewReplayBackend(f)
+}
+
+// Remaining 返回尚未回放的调用数，不含客户端内部发起的调用，可用于断言会话已完整重现
+func (r *ReplayBackend) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, entry := range r.entries[r.pos:] {
+		if !entry.Internal {
+			n++
+		}
+	}
+	return n
+}
+
+// matches 判断记录是否为以 args（已编码为 JSON）调用 proc
+func (e recordEntry) matches(proc string, args []byte) bool {
+	if e.Proc != proc {
+		return false
+	}
+	var want bytes.Buffer
+	return json.Compact(&want, e.Args) == nil && bytes.Equal(args, want.Bytes())
+}
+
+// seek 在持有 mu 时返回当前调用对应的记录位置，跳过次数取决于计时的内部调用：
+// 下一条使用者调用的记录与当前调用一致时跳过其间全部内部调用，
+// 否则使用其间第一条一致的内部调用；都不一致时返回下一条使用者调用的位置，由调用方报告不匹配
+func (r *ReplayBackend) seek(proc string, args []byte) int {
+	internal := -1
+	for i := r.pos; i < len(r.entries); i++ {
+		e := r.entries[i]
+		switch {
+		case !e.Internal && (internal < 0 || e.matches(proc, args)):
+			return i
+		case !e.Internal:
+			return internal
+		case internal < 0 && e.matches(proc, args):
+			internal = i
+		}
+	}
+	if internal >= 0 {
+		return internal
+	}
+	return len(r.entries)
+}
+
+// next 取出下一条记录，校验函数名与参数后把返回值解码到 results
+func (r *ReplayBackend) next(proc string, args []any, results ...any) error {
+	got, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pos := r.seek(proc, got)
+	if pos >= len(r.entries) {
+		return fmt.Errorf("%w: recording exhausted, unexpected call %s (回放记录已用完)", ErrReplay, proc)
+	}
+	entry := r.entries[pos]
+	if entry.Proc != proc {
+		return fmt.Errorf("%w: entry %d expects %s, got %s", ErrReplay, entry.Seq, entry.Proc, proc)
+	}
+
+	var want bytes.Buffer
+	if err := json.Compact(&want, entry.Args); err != nil {
+		return fmt.Errorf("%w: entry %d has invalid args: %w", ErrReplay, entry.Seq, err)
+	}
+	if !bytes.Equal(got, want.Bytes()) {
+		return fmt.Errorf("%w: entry %d (%s) expects args %s, got %s", ErrReplay, entry.Seq, proc, want.Bytes(), got)
+	}
+	r.pos = pos + 1
+
+	if len(entry.Results) < len(results) && entry.Error == "" {
+		return fmt.Errorf("%w: entry %d (%s) has no results", ErrReplay, entry.Seq, proc)
+	}
+	for i, out := range results {
+		if i >= len(entry.Results) {
+			break
+		}
+		if err := json.Unmarshal(entry.Results[i], out); err != nil {
+			return fmt.Errorf("%w: entry %d (%s) has invalid results: %w", ErrReplay, entry.Seq, proc, err)
+		}
+	}
+	// 重建记录的错误，保留错误码和原生调用细节
+	switch {
+	case entry.Native != nil:
+		return entry.Native
+	case entry.Error != "":
+		return &codedError{code: entry.Code, msg: entry.Error}
+	}
+	return nil
+}
+
+func (r *ReplayBackend) OpenDevice(host string, port int, timeout int) (ret uintptr, err error) {
+	err = r.next("openDevice", []any{host, port, timeout}, &ret)
+	return
+}
+
+func (r *ReplayBackend) CloseDevice(handle uintptr) error {
+	return r.next("closeDevice", []any{handle})
+}
+
+func (r *ReplayBackend) CheckLive(handle uintptr) (ok bool, err error) {
+	err = r.next("checkLive", []any{handle}, &ok)
+	return
+}
+
+func (r *ReplayBackend) GetVersion() (n int, err error) {
+	err = r.next("getVersion", nil, &n)
+	return
+}
+
+func (r *ReplayBackend) UseNewNodeMode(handle uintptr, mode int) error {
+	return r.next("useNewNodeMode", []any{handle, mode})
+}
+
+func (r *ReplayBackend) TakeCaptrueCompress(handle uintptr, imgType int, quality int) (data []byte, err error) {
+	err = r.next("takeCaptrueCompress", []any{handle, imgType, quality}, &data)
+	return
+}
+
+func (r *ReplayBackend) TakeCaptrueCompressEx(handle uintptr, left int, top int, right int, bottom int, imgType int, quality int) (data []byte, err error) {
+	err = r.next("takeCaptrueCompressEx", []any{handle, left, top, right, bottom, imgType, quality}, &data)
+	return
+}
+
+func (r *ReplayBackend) TakeCaptrue(handle uintptr) (pix []byte, width int, height int, stride int, err error) {
+	err = r.next("takeCaptrue", []any{handle}, &pix, &width, &height, &stride)
+	return
+}
+
+func (r *ReplayBackend) TakeCaptrueEx(handle uintptr, left int, top int, right int, bottom int) (pix []byte, width int, height int, stride int, err error) {
+	err = r.next("takeCaptrueEx", []any{handle, left, top, right, bottom}, &pix, &width, &height, &stride)
+	return
+}
+
+func (r *ReplayBackend) ScreenshotEx(handle uintptr, left int, top int, right int, bottom int, imgType int, quality int, path string) error {
+	return r.next("screentshotEx", []any{handle, left, top, right, bottom, imgType, quality, path})
+}
+
+// StartVideoStream 只校验调用，回放时不会产生视频或音频数据
+func (r *ReplayBackend) StartVideoStream(handle uintptr, width int, height int, bitrate int, onVideo VideoCallback, onAudio AudioCallback) error {
+	return r.next("startVideoStream", []any{handle, width, height, bitrate})
+}
+
+func (r *ReplayBackend) StopVideoStream(handle uintptr) error {
+	return r.next("stopVideoStream", []any{handle})
+}
+
+func (r *ReplayBackend) GetDisplayRotate(handle uintptr) (n int, err error) {
+	err = r.next("getDisplayRotate", []any{handle}, &n)
+	return
+}
+
+func (r *ReplayBackend) KeyPress(handle uintptr, code int) error {
+	return r.next("keyPress", []any{handle, code})
+}
+
+func (r *ReplayBackend) TouchDown(handle uintptr, fingerID int, x int, y int) error {
+	return r.next("touchDown", []any{handle, fingerID, x, y})
+}
+
+func (r *ReplayBackend) TouchUp(handle uintptr, fingerID int, x int, y int) error {
+	return r.next("touchUp", []any{handle, fingerID, x, y})
+}
+
+func (r *ReplayBackend) TouchMove(handle uintptr, fingerID int, x int, y int) error {
+	return r.next("touchMove", []any{handle, fingerID, x, y})
+}
+
+func (r *ReplayBackend) TouchClick(handle uintptr, fingerID int, x int, y int) error {
+	return r.next("touchClick", []any{handle, fingerID, x, y})
+}
+
+func (r *ReplayBackend) Swipe(handle uintptr, fingerID int, x0 int, y0 int, x1 int, y1 int, duration int, async bool) error {
+	return r.next("swipe", []any{handle, fingerID, x0, y0, x1, y1, duration, async})
+}
+
+func (r *ReplayBackend) SendText(handle uintptr, text string) error {
+	return r.next("sendText", []any{handle, text})
+}
+
+func (r *ReplayBackend) ExecCmd(handle uintptr, wait bool, cmd string) (s string, err error) {
+	err = r.next("execCmd", []any{handle, wait, cmd}, &s)
+	return
+}
+
+func (r *ReplayBackend) OpenApp(handle uintptr, packageName string) error {
+	return r.next("openApp", []any{handle, packageName})
+}
+
+func (r *ReplayBackend) StopApp(handle uintptr, packageName string) error {
+	return r.next("stopApp", []any{handle, packageName})
+}
+
+func (r *ReplayBackend) DumpNodeXml(handle uintptr, dumpAll bool) (s string, err error) {
+	err = r.next("dumpNodeXml", []any{handle, dumpAll}, &s)
+	return
+}
+
+func (r *ReplayBackend) DumpNodeXmlEx(handle uintptr, workMode bool, timeout int) (s string, err error) {
+	err = r.next("dumpNodeXmlEx", []any{handle, workMode, timeout}, &s)
+	return
+}
+
+func (r *ReplayBackend) NewSelector(handle uintptr) (ret uintptr, err error) {
+	err = r.next("newSelector", []any{handle}, &ret)
+	return
+}
+
+func (r *ReplayBackend) ClearSelector(selector uintptr) error {
+	return r.next("clearSelector", []any{selector})
+}
+
+func (r *ReplayBackend) FreeSelector(selector uintptr) error {
+	return r.next("freeSelector", []any{selector})
+}
+
+func (r *ReplayBackend) AddStringQuery(selector uintptr, query string, value string) error {
+	return r.next(query, []any{selector, value})
+}
+
+func (r *ReplayBackend) AddBoolQuery(selector uintptr, query string, value bool) error {
+	return r.next(query, []any{selector, value})
+}
+
+func (r *ReplayBackend) AddIntQuery(selector uintptr, query string, value int) error {
+	return r.next(query, []any{selector, value})
+}
+
+func (r *ReplayBackend) AddBoundsQuery(selector uintptr, query string, left int, top int, right int, bottom int) error {
+	return r.next(query, []any{selector, left, top, right, bottom})
+}
+
+func (r *ReplayBackend) FindNodes(selector uintptr, maxNode int, timeout int) (ret uintptr, err error) {
+	err = r.next("findNodes", []any{selector, maxNode, timeout}, &ret)
+	return
+}
+
+func (r *ReplayBackend) GetNodesSize(nodes uintptr) (n int, err error) {
+	err = r.next("getNodesSize", []any{nodes}, &n)
+	return
+}
+
+func (r *ReplayBackend) GetNodeByIndex(nodes uintptr, index int) (ret uintptr, err error) {
+	err = r.next("getNodeByIndex", []any{nodes, index}, &ret)
+	return
+}
+
+func (r *ReplayBackend) FreeNodes(nodes uintptr) error {
+	return r.next("freeNodes", []any{nodes})
+}
+
+func (r *ReplayBackend) ClickNode(node uintptr) error {
+	return r.next("clickNode", []any{node})
+}
+
+func (r *ReplayBackend) LongClickNode(node uintptr) error {
+	return r.next("longClickNode", []any{node})
+}
+
+func (r *ReplayBackend) GetNodeBound(node uintptr) (left int, top int, right int, bottom int, err error) {
+	err = r.next("getNodeNound", []any{node}, &left, &top, &right, &bottom)
+	return
+}
+
+func (r *ReplayBackend) GetNodeBoundCenter(node uintptr) (x int, y int, err error) {
+	err = r.next("getNodeNoundCenter", []any{node}, &x, &y)
+	return
+}
+
+func (r *ReplayBackend) GetNodeText(node uintptr) (s string, err error) {
+	err = r.next("getNodeText", []any{node}, &s)
+	return
+}
+
+func (r *ReplayBackend) GetNodeDesc(node uintptr) (s string, err error) {
+	err = r.next("getNodeDesc", []any{node}, &s)
+	return
+}
+
+func (r *ReplayBackend) GetNodePackage(node uintptr) (s string, err error) {
+	err = r.next("getNodePackage", []any{node}, &s)
+	return
+}
+
+func (r *ReplayBackend) GetNodeClass(node uintptr) (s string, err error) {
+	err = r.next("getNodeClass", []any{node}, &s)
+	return
+}
+
+func (r *ReplayBackend) GetNodeId(node uintptr) (s string, err error) {
+	err = r.next("getNodeId", []any{node}, &s)
+	return
+}
+
+func (r *ReplayBackend) GetNodeJson(node uintptr) (s string, err error) {
+	err = r.next("getNodeJson", []any{node}, &s)
+	return
+}
+
+func (r *ReplayBackend) GetNodeParent(node uintptr) (ret uintptr, err error) {
+	err = r.next("getNodeParent", []any{node}, &ret)
+	return
+}
+
+func (r *ReplayBackend) GetNodeChildCount(node uintptr) (n int, err error) {
+	err = r.next("getNodeChildCount", []any{node}, &n)
+	return
+}
+
+func (r *ReplayBackend) GetNodeChild(node uintptr, index int) (ret uintptr, err error) {
+	err = r.next("getNodeChild", []any{node, index}, &ret)
+	return
+}
@@ -0,0 +1,310 @@
+package rpc_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"mytrpc/rpc"
+	"mytrpc/sim"
+)
+
+// outcome 为一次调用的返回值和错误
+type outcome struct {
+	proc    string
+	results []any
+	err     error
+}
+
+// session 在 b 上执行一组固定的调用，覆盖字符串、数据、句柄和错误返回
+func session(b rpc.Backend) []outcome {
+	var out []outcome
+	add := func(proc string, err error, results ...any) {
+		out = append(out, outcome{proc: proc, results: results, err: err})
+	}
+
+	handle, err := b.OpenDevice("sim", 9008, 5)
+	add("openDevice", err, handle)
+	live, err := b.CheckLive(handle)
+	add("checkLive", err, live)
+	s, err := b.ExecCmd(handle, true, "wm size")
+	add("execCmd", err, s)
+	data, err := b.TakeCaptrueCompress(handle, 0, 80)
+	add("takeCaptrueCompress", err, data)
+
+	selector, err := b.NewSelector(handle)
+	add("newSelector", err, selector)
+	add("addStringQuery", b.AddStringQuery(selector, rpc.QueryTextEqual, "Settings"))
+	nodes, err := b.FindNodes(selector, 0, 0)
+	add("findNodes", err, nodes)
+	n, err := b.GetNodesSize(nodes)
+	add("getNodesSize", err, n)
+	node, err := b.GetNodeByIndex(nodes, 0)
+	add("getNodeByIndex", err, node)
+	text, err := b.GetNodeText(node)
+	add("getNodeText", err, text)
+	l, t, r, bt, err := b.GetNodeBound(node)
+	add("getNodeBound", err, l, t, r, bt)
+	add("freeNodes", b.FreeNodes(nodes))
+	add("freeSelector", b.FreeSelector(selector))
+
+	// 失败的调用同样要原样回放
+	_, err = b.GetNodesSize(nodes)
+	add("getNodesSize", err)
+	add("addStringQuery", b.AddStringQuery(selector, "NoSuchQuery", "x"))
+
+	add("closeDevice", b.CloseDevice(handle))
+	add("closeDevice", b.CloseDevice(handle))
+	return out
+}
+
+func record(t *testing.T) (*bytes.Buffer, []outcome) {
+	t.Helper()
+	fake := sim.New()
+	if err := fake.AddScreenFile("home", "../nodes_ex.xml"); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	rec := rpc.NewRecorder(&buf)
+	want := session(rec.Wrap(fake))
+	if err := rec.Err(); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	return &buf, want
+}
+
+func TestReplayRoundTrip(t *testing.T) {
+	buf, want := record(t)
+	if want[len(want)-1].err == nil || want[len(want)-3].err == nil {
+		t.Fatal("session should include failing calls")
+	}
+	if text := want[9].results[0]; text != "Settings" {
+		t.Fatalf("getNodeText = %q, want Settings", text)
+	}
+
+	replay, err := rpc.NewReplayBackend(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := session(replay)
+	if len(got) != len(want) {
+		t.Fatalf("got %d calls, want %d", len(got), len(want))
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if !reflect.DeepEqual(g.results, w.results) {
+			t.Errorf("call %d (%s): results %v, want %v", i, w.proc, g.results, w.results)
+		}
+		switch {
+		case (g.err == nil) != (w.err == nil):
+			t.Errorf("call %d (%s): err %v, want %v", i, w.proc, g.err, w.err)
+		case w.err != nil:
+			if g.err.Error() != w.err.Error() {
+				t.Errorf("call %d (%s): err %q, want %q", i, w.proc, g.err, w.err)
+			}
+			if rpc.CodeOf(g.err) != rpc.CodeOf(w.err) {
+				t.Errorf("call %d (%s): code %v, want %v", i, w.proc, rpc.CodeOf(g.err), rpc.CodeOf(w.err))
+			}
+			if errors.Is(w.err, rpc.ErrInvalidHandle) && !errors.Is(g.err, rpc.ErrInvalidHandle) {
+				t.Errorf("call %d (%s): err %v should match ErrInvalidHandle", i, w.proc, g.err)
+			}
+		}
+	}
+	if n := replay.Remaining(); n != 0 {
+		t.Errorf("Remaining() = %d, want 0", n)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	buf, _ := record(t)
+	data := buf.Bytes()
+
+	tests := []struct {
+		name string
+		call func(b rpc.Backend, handle uintptr) error
+	}{
+		{"out of order", func(b rpc.Backend, handle uintptr) error {
+			_, err := b.ExecCmd(handle, true, "wm size")
+			return err
+		}},
+		{"different args", func(b rpc.Backend, handle uintptr) error {
+			_, err := b.CheckLive(handle + 1)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replay, err := rpc.NewReplayBackend(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			handle, err := replay.OpenDevice("sim", 9008, 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.call(replay, handle); !errors.Is(err, rpc.ErrReplay) {
+				t.Fatalf("err = %v, want ErrReplay", err)
+			}
+		})
+	}
+
+	t.Run("exhausted", func(t *testing.T) {
+		replay, err := rpc.NewReplayBackend(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		session(replay)
+		if _, err := replay.GetVersion(); !errors.Is(err, rpc.ErrReplay) {
+			t.Fatalf("err = %v, want ErrReplay", err)
+		}
+	})
+}
+
+// clientSession 通过客户端执行一组调用，每次调用之间留出保活检查的时间
+func clientSession(t *testing.T, client *rpc.Client, pause time.Duration) []outcome {
+	t.Helper()
+	if err := client.Connect("sim", 0); err != nil {
+		t.Fatal(err)
+	}
+	var out []outcome
+	b, handle := client.Backend(), client.GetHandle()
+	s, err := b.ExecCmd(handle, true, "wm size")
+	out = append(out, outcome{proc: "execCmd", results: []any{s}, err: err})
+	time.Sleep(pause)
+	data, err := b.TakeCaptrueCompress(handle, 0, 80)
+	out = append(out, outcome{proc: "takeCaptrueCompress", results: []any{data}, err: err})
+	time.Sleep(pause)
+	// 使用者主动检查连接不是内部调用，回放时必须出现
+	live, err := client.CheckConnectState()
+	out = append(out, outcome{proc: "checkLive", results: []any{live}, err: err})
+	time.Sleep(pause)
+	_, err = b.GetNodeText(12345)
+	out = append(out, outcome{proc: "getNodeText", err: err})
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestReplayClientRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	// 连接时前两次 checkLive 未就绪，保活每 5ms 检查一次，记录中夹杂着内部调用
+	fake := &slowStart{Device: sim.New(), notLive: 2}
+	if err := fake.AddScreenFile("home", "../nodes_ex.xml"); err != nil {
+		t.Fatal(err)
+	}
+	recording := rpc.NewClientWithBackend(fake,
+		rpc.WithKeepalive(5*time.Millisecond),
+		rpc.WithReadyPollInterval(time.Millisecond),
+		rpc.WithLogger(quietLogger))
+	if err := recording.StartRecording(path); err != nil {
+		t.Fatal(err)
+	}
+	want := clientSession(t, recording, 30*time.Millisecond)
+	if want[3].err == nil {
+		t.Fatal("session should include a failing call")
+	}
+
+	// 记录包含连接、保活和关闭的内部调用，使用者的调用不标记
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var internal, user []string
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		var e struct {
+			Proc     string `json:"proc"`
+			Internal bool   `json:"internal"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Internal {
+			internal = append(internal, e.Proc)
+		} else {
+			user = append(user, e.Proc)
+		}
+	}
+	if want := []string{"execCmd", "takeCaptrueCompress", "checkLive", "getNodeText"}; !reflect.DeepEqual(user, want) {
+		t.Fatalf("user calls = %v, want %v", user, want)
+	}
+	if len(internal) < 6 || internal[0] != "openDevice" || internal[len(internal)-1] != "closeDevice" {
+		t.Fatalf("internal calls = %v, want openDevice, polling and keepalive checks, closeDevice", internal)
+	}
+
+	// 回放时不开启保活，保活检查的记录被跳过
+	replay, err := rpc.LoadReplay(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := clientSession(t, rpc.NewClientWithBackend(replay,
+		rpc.WithReadyPollInterval(time.Millisecond),
+		rpc.WithLogger(quietLogger)), 0)
+	for i := range want {
+		g, w := got[i], want[i]
+		if !reflect.DeepEqual(g.results, w.results) || (g.err == nil) != (w.err == nil) ||
+			w.err != nil && (g.err.Error() != w.err.Error() || rpc.CodeOf(g.err) != rpc.CodeOf(w.err)) {
+			t.Errorf("call %d (%s): got %v %v, want %v %v", i, w.proc, g.results, g.err, w.results, w.err)
+		}
+	}
+	if n := replay.Remaining(); n != 0 {
+		t.Errorf("Remaining() = %d, want 0", n)
+	}
+}
+
+func TestReplaySkipsOnlyInternal(t *testing.T) {
+	lines := `{"seq":1,"proc":"openDevice","args":["sim",0,10],"results":[1],"internal":true}
+{"seq":2,"proc":"checkLive","args":[1],"results":[true],"internal":true}
+{"seq":3,"proc":"checkLive","args":[1],"results":[true],"internal":true}
+{"seq":4,"proc":"execCmd","args":[1,true,"ls"],"results":["a"]}
+{"seq":5,"proc":"checkLive","args":[1],"results":[true],"internal":true}
+{"seq":6,"proc":"checkLive","args":[1],"results":[false]}
+{"seq":7,"proc":"closeDevice","args":[1],"internal":true}
+`
+	replay, err := rpc.NewReplayBackend(bytes.NewReader([]byte(lines)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := replay.Remaining(); n != 2 {
+		t.Fatalf("Remaining() = %d, want only the user calls", n)
+	}
+	if h, err := replay.OpenDevice("sim", 0, 10); err != nil || h != 1 {
+		t.Fatalf("OpenDevice = %d, %v", h, err)
+	}
+	// 内部调用与记录一致时照常回放，不一致时跳过
+	if live, err := replay.CheckLive(1); err != nil || !live {
+		t.Fatalf("CheckLive = %v, %v", live, err)
+	}
+	if out, err := replay.ExecCmd(1, true, "ls"); err != nil || out != "a" {
+		t.Fatalf("ExecCmd = %q, %v", out, err)
+	}
+	// 与下一条使用者调用一致时优先回放它，而不是之前的内部调用
+	if live, err := replay.CheckLive(1); err != nil || live {
+		t.Fatalf("CheckLive = %v, %v, want the recorded user check", live, err)
+	}
+	if err := replay.CloseDevice(1); err != nil {
+		t.Fatal(err)
+	}
+	if n := replay.Remaining(); n != 0 {
+		t.Fatalf("Remaining() = %d", n)
+	}
+
+	// 使用者的调用不会被跳过
+	replay, err = rpc.NewReplayBackend(bytes.NewReader([]byte(lines)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := replay.ExecCmd(1, true, "pwd"); !errors.Is(err, rpc.ErrReplay) {
+		t.Fatalf("ExecCmd with other args = %v, want ErrReplay", err)
+	}
+	if _, err := replay.ExecCmd(1, true, "ls"); err != nil {
+		t.Fatalf("ExecCmd after a mismatch = %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package rpc
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// VideoCallback 接收一段视频数据，rotation 为推流时的屏幕方向（取值同 getDisplayRotate），
+// data 为 H.264 Annex-B 码流，已复制到 Go 内存，回调返回后仍可使用
+type VideoCallback func(rotation int, data []byte)
+
+// AudioCallback 接收一段音频数据，已复制到 Go 内存
+type AudioCallback func(data []byte)
+
+// nativeStream 为当前视频流的回调。原生库只保存一份全局回调且回调参数不含设备句柄，
+// 因此同一进程内同时只能有一路视频流，由这里记录其所属的设备句柄
+var nativeStream struct {
+	mu     sync.Mutex
+	active bool
+	handle uintptr
+	video  VideoCallback
+	audio  AudioCallback
+}
+
+// acquireStream 登记 handle 的回调，其他设备正在推流时返回 ErrStreamBusy
+func acquireStream(handle uintptr, video VideoCallback, audio AudioCallback) error {
+	nativeStream.mu.Lock()
+	defer nativeStream.mu.Unlock()
+	if nativeStream.active && nativeStream.handle != handle {
+		return fmt.Errorf("%w: device handle %#x is streaming (其他设备正在推流)", ErrStreamBusy, nativeStream.handle)
+	}
+	nativeStream.active = true
+	nativeStream.handle = handle
+	nativeStream.video = video
+	nativeStream.audio = audio
+	return nil
+}
+
+// releaseStream 在 handle 仍持有视频流时清除回调
+func releaseStream(handle uintptr) {
+	nativeStream.mu.Lock()
+	defer nativeStream.mu.Unlock()
+	if nativeStream.active && nativeStream.handle == handle {
+		nativeStream.active = false
+		nativeStream.video = nil
+		nativeStream.audio = nil
+	}
+}
+
+// dispatchVideo 由平台相关的原生回调调用，复制数据后交给当前的 VideoCallback
+func dispatchVideo(rotation int, data unsafe.Pointer, n int) {
+	nativeStream.mu.Lock()
+	fn := nativeStream.video
+	nativeStream.mu.Unlock()
+	if fn == nil || data == nil || n <= 0 {
+		return
+	}
+	fn(rotation, append([]byte(nil), unsafe.Slice((*byte)(data), n)...))
+}
+
+// dispatchAudio 由平台相关的原生回调调用，复制数据后交给当前的 AudioCallback
+func dispatchAudio(data unsafe.Pointer, n int) {
+	nativeStream.mu.Lock()
+	fn := nativeStream.audio
+	nativeStream.mu.Unlock()
+	if fn == nil || data == nil || n <= 0 {
+		return
+	}
+	fn(append([]byte(nil), unsafe.Slice((*byte)(data), n)...))
+}
+
+func (b *nativeBackend) StartVideoStream(handle uintptr, width, height, bitrate int, onVideo VideoCallback, onAudio AudioCallback) error {
+	if b.sym.startVideoStream.p == nil {
+		return symbolMissing(b.sym.startVideoStream.name)
+	}
+	videoFn, audioFn, err := streamCallbacks()
+	if err != nil {
+		return err
+	}
+	if onVideo == nil {
+		videoFn = 0
+	}
+	if onAudio == nil {
+		audioFn = 0
+	}
+	if err := acquireStream(handle, onVideo, onAudio); err != nil {
+		return err
+	}
+	err = b.callBool(&b.sym.startVideoStream, handle,
+		uintptr(width), uintptr(height), uintptr(bitrate),
+		videoFn, audioFn,
+	)
+	if err != nil {
+		releaseStream(handle)
+	}
+	return err
+}
+
+func (b *nativeBackend) StopVideoStream(handle uintptr) error {
+	// 先清除回调，停止过程中到达的数据直接丢弃
+	releaseStream(handle)
+	_, err := b.call(&b.sym.stopVideoStream, handle)
+	return err
+}
@@ -0,0 +1,27 @@
+//go:build (linux || darwin) && cgo
+
+package rpc
+
+/*
+// 原生库的回调：视频为 (rotation, data, len)，音频为 (data, len)
+extern void mytrpcOnVideo(int, void *, int);
+extern void mytrpcOnAudio(void *, int);
+*/
+import "C"
+
+import "unsafe"
+
+//export mytrpcOnVideo
+func mytrpcOnVideo(rotation C.int, data unsafe.Pointer, n C.int) {
+	dispatchVideo(int(rotation), data, int(n))
+}
+
+//export mytrpcOnAudio
+func mytrpcOnAudio(data unsafe.Pointer, n C.int) {
+	dispatchAudio(data, int(n))
+}
+
+// streamCallbacks 返回传给 startVideoStream 的回调函数地址
+func streamCallbacks() (video, audio uintptr, err error) {
+	return uintptr(unsafe.Pointer(C.mytrpcOnVideo)), uintptr(unsafe.Pointer(C.mytrpcOnAudio)), nil
+}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package rpc
+
+import (
+	"sync"
+	"syscall"
+)
+
+// Windows 的回调数量有上限且无法释放，因此只创建一次
+var windowsCallbacks = sync.OnceValues(func() (uintptr, uintptr) {
+	video := syscall.NewCallbackCDecl(func(rotation, data, n uintptr) uintptr {
+		dispatchVideo(int(int32(rotation)), nativePtr(data), int(int32(n)))
+		return 0
+	})
+	audio := syscall.NewCallbackCDecl(func(data, n uintptr) uintptr {
+		dispatchAudio(nativePtr(data), int(int32(n)))
+		return 0
+	})
+	return video, audio
+})
+
+// streamCallbacks 返回传给 startVideoStream 的回调函数地址
+func streamCallbacks() (video, audio uintptr, err error) {
+	video, audio = windowsCallbacks()
+	return video, audio, nil
+}
@@ -0,0 +1,153 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ConnState 是客户端的连接状态
+type ConnState int
+
+const (
+	StateDisconnected ConnState = iota
+	StateConnecting
+	StateConnected
+	StateReconnecting
+	StateClosed
+)
+
+var stateNames = [...]string{
+	StateDisconnected: "disconnected",
+	StateConnecting:   "connecting",
+	StateConnected:    "connected",
+	StateReconnecting: "reconnecting",
+	StateClosed:       "closed",
+}
+
+func (s ConnState) String() string {
+	if int(s) < len(stateNames) {
+		return stateNames[s]
+	}
+	return fmt.Sprintf("ConnState(%d)", int(s))
+}
+
+// StateChange 描述一次连接状态变化
+type StateChange struct {
+	From, To ConnState
+	// Err 为导致变化的错误，如检测到断线的原因或连接失败的错误
+	Err error
+	// Attempt 为重连成功时用掉的尝试次数
+	Attempt int
+	Time    time.Time
+}
+
+// State 返回当前连接状态
+func (c *Client) State() ConnState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// setState 切换连接状态并通知监听函数，状态未变化时不通知
+func (c *Client) setState(to ConnState, err error, attempt int) {
+	c.mu.Lock()
+	from := c.state
+	if from == to {
+		c.mu.Unlock()
+		return
+	}
+	c.state = to
+	if to == StateReconnecting {
+		c.reconnected = make(chan struct{})
+	}
+	if from == StateReconnecting {
+		close(c.reconnected)
+	}
+	c.mu.Unlock()
+
+	if c.opts.stateListener != nil {
+		c.opts.stateListener(StateChange{From: from, To: to, Err: err, Attempt: attempt, Time: time.Now()})
+	}
+}
+
+// supervise 定期检查连接，断线后重连，直到客户端关闭
+func (c *Client) supervise(done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(c.opts.keepalive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		case <-c.kick:
+		}
+
+		c.mu.Lock()
+		handle, state := c.handle, c.state
+		c.mu.Unlock()
+		if state != StateConnected {
+			continue
+		}
+
+		live, err := c.direct.CheckLive(handle)
+		if err == nil && live {
+			continue
+		}
+		if err == nil {
+			err = fmt.Errorf("%w: checkLive returned false (设备连接已断开)", ErrNotConnected)
+		}
+		c.reconnect(handle, err)
+	}
+}
+
+// reconnect 释放旧句柄后按指数退避重新连接原来的地址，直到成功或客户端关闭
+func (c *Client) reconnect(old uintptr, cause error) {
+	c.lifecycle.Lock()
+	defer c.lifecycle.Unlock()
+
+	c.mu.Lock()
+	if c.closed || c.handle != old {
+		// 检查期间已被关闭或重新 Connect
+		c.mu.Unlock()
+		return
+	}
+	c.handle = 0
+	host, port := c.host, c.port
+	device := c.address()
+	c.mu.Unlock()
+	c.opts.logger.Warn("设备连接已断开，开始重连", "device", device, "error", cause)
+	c.setState(StateReconnecting, cause, 0)
+
+	if old != 0 {
+		c.direct.CloseDevice(old)
+	}
+
+	backoff := c.opts.backoffMin
+	for attempt := 1; ; attempt++ {
+		handle, err := c.dial(host, port)
+		if err == nil {
+			c.mu.Lock()
+			c.handle = handle
+			c.mu.Unlock()
+			c.opts.logger.Info("设备已重新连接", "device", device, "attempts", attempt)
+			c.setState(StateConnected, nil, attempt)
+			return
+		}
+		if errors.Is(err, ErrClosed) {
+			return
+		}
+
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > c.opts.backoffMax {
+			backoff = c.opts.backoffMax
+		}
+	}
+}
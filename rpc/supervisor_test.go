@@ -0,0 +1,216 @@
+package rpc_test
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"mytrpc/rpc"
+	"mytrpc/sim"
+)
+
+var quietLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// dialRecorder 记录每次 openDevice 的时间
+type dialRecorder struct {
+	*sim.Device
+
+	mu    sync.Mutex
+	dials []time.Time
+}
+
+func (d *dialRecorder) OpenDevice(host string, port int, timeout int) (uintptr, error) {
+	d.mu.Lock()
+	d.dials = append(d.dials, time.Now())
+	d.mu.Unlock()
+	return d.Device.OpenDevice(host, port, timeout)
+}
+
+func (d *dialRecorder) times() []time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]time.Time(nil), d.dials...)
+}
+
+// supervised 返回开启保活的客户端和接收状态变化的通道
+func supervised(t *testing.T, backend rpc.Backend, opts ...rpc.Option) (*rpc.Client, <-chan rpc.StateChange) {
+	t.Helper()
+	changes := make(chan rpc.StateChange, 100)
+	opts = append([]rpc.Option{
+		rpc.WithKeepalive(10 * time.Millisecond),
+		rpc.WithReconnectBackoff(10*time.Millisecond, 40*time.Millisecond),
+		rpc.WithConnectTimeout(time.Second),
+		rpc.WithReadyPollInterval(time.Millisecond),
+		rpc.WithLogger(quietLogger),
+		rpc.WithStateListener(func(c rpc.StateChange) { changes <- c }),
+	}, opts...)
+	client := rpc.NewClientWithBackend(backend, opts...)
+	if err := client.Connect("sim", 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, changes
+}
+
+// expectStates 依次读取状态变化，与 want 不一致或超时时失败
+func expectStates(t *testing.T, changes <-chan rpc.StateChange, want ...rpc.ConnState) []rpc.StateChange {
+	t.Helper()
+	var got []rpc.StateChange
+	for _, to := range want {
+		select {
+		case c := <-changes:
+			got = append(got, c)
+			if c.To != to {
+				t.Fatalf("state change %d = %s -> %s, want -> %s", len(got), c.From, c.To, to)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for -> %s after %d changes", to, len(got))
+		}
+	}
+	return got
+}
+
+func waitState(t *testing.T, client *rpc.Client, state rpc.ConnState) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for client.State() != state {
+		if time.Now().After(deadline) {
+			t.Fatalf("state = %s, want %s", client.State(), state)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReconnectAfterDisconnect(t *testing.T) {
+	fake := sim.New()
+	client, changes := supervised(t, fake)
+	expectStates(t, changes, rpc.StateConnecting, rpc.StateConnected)
+	old := client.GetHandle()
+
+	fake.Disconnect()
+	got := expectStates(t, changes, rpc.StateReconnecting, rpc.StateConnected)
+	if got[0].From != rpc.StateConnected || !errors.Is(got[0].Err, rpc.ErrNotConnected) {
+		t.Errorf("reconnecting change = %+v, want from connected with ErrNotConnected", got[0])
+	}
+	if got[1].Attempt != 1 || got[1].Err != nil {
+		t.Errorf("reconnected change = %+v, want attempt 1 without error", got[1])
+	}
+
+	// 重连后使用新的句柄，旧句柄已失效
+	handle := client.GetHandle()
+	if handle == 0 || handle == old {
+		t.Fatalf("handle after reconnect = %d, old %d", handle, old)
+	}
+	if _, err := client.Backend().ExecCmd(handle, true, "echo hi"); err != nil {
+		t.Fatalf("call after reconnect: %v", err)
+	}
+	if _, err := fake.ExecCmd(old, true, "echo hi"); !errors.Is(err, rpc.ErrInvalidHandle) {
+		t.Fatalf("old handle still valid: %v", err)
+	}
+
+	client.Close()
+	expectStates(t, changes, rpc.StateClosed)
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	fake := &dialRecorder{Device: sim.New()}
+	client, changes := supervised(t, fake)
+	expectStates(t, changes, rpc.StateConnecting, rpc.StateConnected)
+
+	fake.SetReachable(false)
+	expectStates(t, changes, rpc.StateReconnecting)
+	// 首次连接1次，之后退避 10、20、40、40ms
+	deadline := time.Now().Add(5 * time.Second)
+	for len(fake.times()) < 6 {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d dials", len(fake.times()))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if s := client.State(); s != rpc.StateReconnecting {
+		t.Fatalf("state while unreachable = %s", s)
+	}
+	dials := fake.times()[1:]
+	want := []time.Duration{10, 20, 40, 40}
+	for i, w := range want {
+		gap := dials[i+1].Sub(dials[i])
+		w *= time.Millisecond
+		if gap < w || gap > w+200*time.Millisecond {
+			t.Errorf("gap before attempt %d = %v, want about %v", i+2, gap, w)
+		}
+	}
+
+	fake.SetReachable(true)
+	got := expectStates(t, changes, rpc.StateConnected)
+	if n := len(fake.times()) - 1; got[0].Attempt != n {
+		t.Errorf("reconnected after attempt %d, %d dials made", got[0].Attempt, n)
+	}
+}
+
+func TestReconnectWaitReleasesCallers(t *testing.T) {
+	for _, release := range []string{"reconnect", "close"} {
+		t.Run(release, func(t *testing.T) {
+			fake := sim.New()
+			client, changes := supervised(t, fake, rpc.WithReconnectWait(10*time.Second))
+			expectStates(t, changes, rpc.StateConnecting, rpc.StateConnected)
+			fake.SetReachable(false)
+			expectStates(t, changes, rpc.StateReconnecting)
+
+			type result struct {
+				handle uintptr
+				err    error
+			}
+			done := make(chan result, 1)
+			go func() {
+				backend, handle := client.Backend(), client.GetHandle()
+				_, err := backend.ExecCmd(handle, true, "echo hi")
+				done <- result{handle, err}
+			}()
+			select {
+			case r := <-done:
+				t.Fatalf("call returned during reconnect: %+v", r)
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			if release == "reconnect" {
+				fake.SetReachable(true)
+			} else {
+				client.Close()
+			}
+			var r result
+			select {
+			case r = <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("caller still blocked")
+			}
+			if release == "reconnect" && (r.err != nil || r.handle == 0) {
+				t.Fatalf("after reconnect: handle %d, err %v", r.handle, r.err)
+			}
+			if release == "close" && !errors.Is(r.err, rpc.ErrClosed) {
+				t.Fatalf("after close: err %v, want ErrClosed", r.err)
+			}
+		})
+	}
+}
+
+func TestReconnectWithoutWaitFailsFast(t *testing.T) {
+	fake := sim.New()
+	client, changes := supervised(t, fake)
+	expectStates(t, changes, rpc.StateConnecting, rpc.StateConnected)
+	handle := client.GetHandle()
+	backend := client.Backend()
+	fake.SetReachable(false)
+	expectStates(t, changes, rpc.StateReconnecting)
+
+	start := time.Now()
+	if _, err := backend.ExecCmd(handle, true, "echo hi"); !errors.Is(err, rpc.ErrNotConnected) {
+		t.Fatalf("err = %v, want ErrNotConnected", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("call blocked for %v without WithReconnectWait", d)
+	}
+	waitState(t, client, rpc.StateReconnecting)
+}
@@ -0,0 +1,203 @@
+package rpc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// symbol 是加载时解析好的导出函数，p 为 nil 表示库中不存在该函数
+type symbol struct {
+	name string
+	p    proc
+}
+
+// symbolTable 保存 libmytrpc 的全部已知导出函数，在加载时一次性解析
+type symbolTable struct {
+	openDevice  symbol
+	closeDevice symbol
+	checkLive   symbol
+	getVersion  symbol
+	freeRpcPtr  symbol
+
+	useNewNodeMode        symbol
+	takeCaptrue           symbol
+	takeCaptrueEx         symbol
+	takeCaptrueCompress   symbol
+	takeCaptrueCompressEx symbol
+	screentshotEx         symbol
+	getDisplayRotate      symbol
+	startVideoStream      symbol
+	stopVideoStream       symbol
+
+	keyPress   symbol
+	touchDown  symbol
+	touchUp    symbol
+	touchMove  symbol
+	touchClick symbol
+	swipe      symbol
+	sendText   symbol
+
+	execCmd       symbol
+	openApp       symbol
+	stopApp       symbol
+	dumpNodeXml   symbol
+	dumpNodeXmlEx symbol
+
+	newSelector    symbol
+	clearSelector  symbol
+	freeSelector   symbol
+	findNodes      symbol
+	getNodesSize   symbol
+	getNodeByIndex symbol
+	freeNodes      symbol
+
+	clickNode          symbol
+	longClickNode      symbol
+	getNodeNound       symbol
+	getNodeNoundCenter symbol
+	getNodeText        symbol
+	getNodeDesc        symbol
+	getNodePackage     symbol
+	getNodeClass       symbol
+	getNodeId          symbol
+	getNodeJson        symbol
+	getNodeParent      symbol
+	getNodeChildCount  symbol
+	getNodeChild       symbol
+
+	// queries 为选择器查询条件函数，键为导出名称
+	queries map[string]*symbol
+}
+
+// queryNames 为选择器查询条件函数的导出名称
+var queryNames = []string{
+	QueryTextEqual, QueryTextStartWith, QueryTextEndWith, QueryTextContainWith, QueryTextMatchWith,
+	QueryIdEqual, QueryIdStartWith, QueryIdEndWith, QueryIdContainWith, QueryIdMatchWith,
+	QueryClzEqual, QueryClzStartWith, QueryClzEndWith, QueryClzContainWith, QueryClzMatchWith,
+	QueryDescEqual, QueryDescStartWith, QueryDescEndWith, QueryDescContainWith, QueryDescMatchWith,
+	QueryPackageEqual, QueryPackageStartWith, QueryPackageEndWith, QueryPackageContainWith, QueryPackageMatchWith,
+	QueryCheckable, QueryClickable, QueryEnable, QueryFocusable, QueryFocused,
+	QueryLongClickable, QueryPassword, QueryScrollable, QuerySelected, QueryVisible,
+	QueryIndex,
+	QueryBoundsEqual, QueryBoundsInside,
+}
+
+// symbolEntry 关联导出名称与表项，required 表示缺失时无法使用该库
+type symbolEntry struct {
+	sym      *symbol
+	name     string
+	required bool
+}
+
+// entries 返回全部具名表项
+func (t *symbolTable) entries() []symbolEntry {
+	return []symbolEntry{
+		{&t.openDevice, "openDevice", true},
+		{&t.closeDevice, "closeDevice", true},
+		{&t.checkLive, "checkLive", true},
+		{&t.getVersion, "getVersion", true},
+		{&t.freeRpcPtr, "freeRpcPtr", true},
+
+		{&t.useNewNodeMode, "useNewNodeMode", false},
+		{&t.takeCaptrue, "takeCaptrue", false},
+		{&t.takeCaptrueEx, "takeCaptrueEx", false},
+		{&t.takeCaptrueCompress, "takeCaptrueCompress", false},
+		{&t.takeCaptrueCompressEx, "takeCaptrueCompressEx", false},
+		{&t.getDisplayRotate, "getDisplayRotate", false},
+		{&t.startVideoStream, "startVideoStream", false},
+		{&t.stopVideoStream, "stopVideoStream", false},
+
+		{&t.keyPress, "keyPress", false},
+		{&t.touchDown, "touchDown", false},
+		{&t.touchUp, "touchUp", false},
+		{&t.touchMove, "touchMove", false},
+		{&t.touchClick, "touchClick", false},
+		{&t.swipe, "swipe", false},
+		{&t.sendText, "sendText", false},
+
+		{&t.execCmd, "execCmd", false},
+		{&t.openApp, "openApp", false},
+		{&t.stopApp, "stopApp", false},
+		{&t.dumpNodeXml, "dumpNodeXml", false},
+		{&t.dumpNodeXmlEx, "dumpNodeXmlEx", false},
+
+		{&t.newSelector, "newSelector", false},
+		{&t.clearSelector, "clearSelector", false},
+		{&t.freeSelector, "freeSelector", false},
+		{&t.findNodes, "findNodes", false},
+		{&t.getNodesSize, "getNodesSize", false},
+		{&t.getNodeByIndex, "getNodeByIndex", false},
+		{&t.freeNodes, "freeNodes", false},
+
+		{&t.clickNode, "clickNode", false},
+		{&t.longClickNode, "longClickNode", false},
+		{&t.getNodeNound, "getNodeNound", false},
+		{&t.getNodeNoundCenter, "getNodeNoundCenter", false},
+		{&t.getNodeText, "getNodeText", false},
+		{&t.getNodeDesc, "getNodeDesc", false},
+		{&t.getNodePackage, "getNodePackage", false},
+		{&t.getNodeClass, "getNodeClass", false},
+		{&t.getNodeId, "getNodeId", false},
+		{&t.getNodeJson, "getNodeJson", false},
+		{&t.getNodeParent, "getNodeParent", false},
+		{&t.getNodeChildCount, "getNodeChildCount", false},
+		{&t.getNodeChild, "getNodeChild", false},
+	}
+}
+
+// resolveSymbols 解析全部已知导出函数，返回缺失的可选函数名称；
+// 缺少必需函数时返回错误
+func resolveSymbols(lib library) (*symbolTable, []string, error) {
+	t := &symbolTable{queries: make(map[string]*symbol, len(queryNames))}
+	var missing, missingRequired []string
+
+	for _, e := range t.entries() {
+		e.sym.name = e.name
+		p, err := lib.findProc(e.name)
+		if err != nil {
+			if e.required {
+				missingRequired = append(missingRequired, e.name)
+			} else {
+				missing = append(missing, e.name)
+			}
+			continue
+		}
+		e.sym.p = p
+	}
+
+	for _, name := range queryNames {
+		sym := &symbol{name: name}
+		if p, err := lib.findProc(name); err == nil {
+			sym.p = p
+		} else {
+			missing = append(missing, name)
+		}
+		t.queries[name] = sym
+	}
+
+	// 不同版本的库对截图保存函数的命名不一致
+	t.screentshotEx.name = "screentshotEx"
+	for _, name := range []string{"screentshotEx", "ScreentshotEx", "screentShotEx"} {
+		if p, err := lib.findProc(name); err == nil {
+			t.screentshotEx = symbol{name: name, p: p}
+			break
+		}
+	}
+	if t.screentshotEx.p == nil {
+		missing = append(missing, t.screentshotEx.name)
+	}
+
+	if len(missingRequired) > 0 {
+		return nil, nil, symbolMissing(strings.Join(missingRequired, ", "))
+	}
+	return t, missing, nil
+}
+
+// query 按名称查找选择器查询条件函数
+func (t *symbolTable) query(name string) (*symbol, error) {
+	sym, ok := t.queries[name]
+	if !ok {
+		return nil, fmt.Errorf("mytrpc: unknown selector query (未知的查询条件): %s", name)
+	}
+	return sym, nil
+}
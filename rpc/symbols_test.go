@@ -0,0 +1,170 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeLibrary 按名称提供导出函数，每个函数返回固定值并记录调用
+type fakeLibrary struct {
+	exports map[string]uintptr
+
+	mu    sync.Mutex
+	calls []string
+}
+
+// newFakeLibrary 返回包含全部已知导出函数（截图保存函数使用 screentshotEx 拼写）的库，
+// 去掉 without 中的函数
+func newFakeLibrary(without ...string) *fakeLibrary {
+	exports := map[string]uintptr{"screentshotEx": 1}
+	for _, e := range (&symbolTable{}).entries() {
+		exports[e.name] = 1
+	}
+	for _, name := range queryNames {
+		exports[name] = 1
+	}
+	exports["getVersion"] = 10203
+	for _, name := range without {
+		delete(exports, name)
+	}
+	return &fakeLibrary{exports: exports}
+}
+
+func (l *fakeLibrary) findProc(name string) (proc, error) {
+	ret, ok := l.exports[name]
+	if !ok {
+		return nil, fmt.Errorf("symbol %s not found", name)
+	}
+	return fakeProc{lib: l, name: name, ret: ret}, nil
+}
+
+func (l *fakeLibrary) release() error { return nil }
+
+func (l *fakeLibrary) called() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.calls...)
+}
+
+type fakeProc struct {
+	lib  *fakeLibrary
+	name string
+	ret  uintptr
+}
+
+func (p fakeProc) call(args ...uintptr) (uintptr, error) {
+	p.lib.mu.Lock()
+	p.lib.calls = append(p.lib.calls, p.name)
+	p.lib.mu.Unlock()
+	return p.ret, nil
+}
+
+func TestResolveSymbolsRequired(t *testing.T) {
+	tests := []struct {
+		name    string
+		without []string
+		want    string
+	}{
+		{name: "one", without: []string{"openDevice"}, want: "openDevice"},
+		// 同时缺少多个必需函数时全部列出
+		{name: "several", without: []string{"freeRpcPtr", "checkLive", "dumpNodeXmlEx"}, want: "checkLive, freeRpcPtr"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, missing, err := resolveSymbols(newFakeLibrary(tt.without...))
+			if !errors.Is(err, ErrSymbolMissing) || table != nil || missing != nil {
+				t.Fatalf("resolveSymbols = %v, %v, %v, want ErrSymbolMissing", table, missing, err)
+			}
+			if !strings.HasSuffix(err.Error(), ": "+tt.want) {
+				t.Fatalf("err = %v, want %q listed", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveSymbolsOptional(t *testing.T) {
+	lib := newFakeLibrary("dumpNodeXmlEx", QueryTextMatchWith, "screentshotEx")
+	table, missing, err := resolveSymbols(lib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"dumpNodeXmlEx", QueryTextMatchWith, "screentshotEx"}; !slices.Equal(missing, want) {
+		t.Fatalf("missing = %v, want %v", missing, want)
+	}
+	if table.dumpNodeXmlEx.p != nil || table.dumpNodeXml.p == nil || table.queries[QueryTextMatchWith].p != nil {
+		t.Fatal("symbol table does not match the library")
+	}
+
+	backend := &nativeBackend{lib: lib, sym: table, missing: missing}
+	client := NewClientWithBackend(backend, WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	defer client.Close()
+	if err := client.Connect("sim", 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := client.MissingSymbols(); !slices.Equal(got, missing) {
+		t.Fatalf("MissingSymbols() = %v, want %v", got, missing)
+	}
+	b, handle := client.Backend(), client.GetHandle()
+
+	// 缺失的可选函数在调用时返回带库版本的 ErrUnsupported，不会调用到原生库
+	before := len(lib.called())
+	unsupported := []struct {
+		proc string
+		call func() error
+	}{
+		{"dumpNodeXmlEx", func() error { _, err := b.DumpNodeXmlEx(handle, true, 1000); return err }},
+		{QueryTextMatchWith, func() error { return b.AddStringQuery(1, QueryTextMatchWith, "a.*") }},
+		{"screentshotEx", func() error { return b.ScreenshotEx(handle, 0, 0, 10, 10, 0, 80, "a.jpg") }},
+	}
+	for _, u := range unsupported {
+		err := u.call()
+		if !errors.Is(err, ErrUnsupported) || !errors.Is(err, ErrSymbolMissing) {
+			t.Errorf("%s: err = %v, want ErrUnsupported", u.proc, err)
+			continue
+		}
+		if want := u.proc + " unsupported by library version 10203"; !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v, want %q", u.proc, err, want)
+		}
+	}
+	// 只探测了一次版本
+	if calls := lib.called()[before:]; !slices.Equal(calls, []string{"getVersion"}) {
+		t.Fatalf("native calls = %v, want only the version probe", calls)
+	}
+
+	// 存在的函数正常调用
+	if err := b.AddStringQuery(1, QueryTextEqual, "OK"); err != nil {
+		t.Fatal(err)
+	}
+	if calls := lib.called(); calls[len(calls)-1] != QueryTextEqual {
+		t.Fatalf("last native call = %s, want %s", calls[len(calls)-1], QueryTextEqual)
+	}
+	// 未知的查询条件不是库版本的问题
+	if err := b.AddStringQuery(1, "TextLike", "OK"); err == nil || errors.Is(err, ErrUnsupported) {
+		t.Fatalf("unknown query: err = %v", err)
+	}
+}
+
+func TestResolveSymbolsScreenshotSpelling(t *testing.T) {
+	lib := newFakeLibrary("screentshotEx")
+	lib.exports["screentShotEx"] = 1
+	table, missing, err := resolveSymbols(lib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) != 0 || table.screentshotEx.name != "screentShotEx" {
+		t.Fatalf("missing = %v, screenshot symbol %q", missing, table.screentshotEx.name)
+	}
+	backend := &nativeBackend{lib: lib, sym: table}
+	if err := backend.ScreenshotEx(1, 0, 0, 10, 10, 0, 80, "a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if calls := lib.called(); !slices.Equal(calls, []string{"screentShotEx"}) {
+		t.Fatalf("native calls = %v", calls)
+	}
+}
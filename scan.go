@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"mytrpc/discovery"
+	"mytrpc/rpc"
+)
+
+// runScan 执行 scan 子命令：mytrpc scan [选项] <host|cidr> <ports>，backend 为 nil 时加载原生库
+func runScan(args []string, stdout io.Writer, backend rpc.Backend) error {
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	concurrency := fs.Int("c", 64, "并发探测数")
+	dialTimeout := fs.Duration("dial-timeout", 500*time.Millisecond, "TCP 探测超时")
+	connectTimeout := fs.Duration("connect-timeout", 5*time.Second, "连接设备超时")
+	fs.Usage = func() {
+		fmt.Fprintln(stdout, "用法: mytrpc scan [选项] <host|cidr> <ports>")
+		fmt.Fprintln(stdout, "示例: mytrpc scan 192.168.1.0/24 7100-7199")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	ports, err := discovery.ParsePorts(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	found, err := discovery.Scan(ctx, fs.Arg(0), ports, discovery.Options{
+		Concurrency:    *concurrency,
+		DialTimeout:    *dialTimeout,
+		ConnectTimeout: *connectTimeout,
+		Backend:        backend,
+	})
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "地址\tSDK版本\t屏幕\t方向\t耗时")
+	for _, ep := range found {
+		screen := "-"
+		if ep.Width > 0 {
+			screen = fmt.Sprintf("%dx%d", ep.Width, ep.Height)
+			if ep.Density > 0 {
+				screen += fmt.Sprintf(" %ddpi", ep.Density)
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%v\t%v\n", ep.Address(), ep.SDKVersion, screen, ep.Rotation, ep.Latency.Round(time.Millisecond))
+	}
+	tw.Flush()
+	fmt.Fprintf(stdout, "共发现 %d 台设备\n", len(found))
+	return err
+}
@@ -0,0 +1,849 @@
+// Package sim 提供一个基于层级XML的内存模拟设备，实现 rpc.Backend，
+// 用于在没有真实MYT设备的情况下测试 device、node 以及上层任务。
+package sim
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"mytrpc/rpc"
+)
+
+// EventKind 表示记录的操作类型
+type EventKind string
+
+const (
+	EventTouchDown  EventKind = "touchDown"
+	EventTouchUp    EventKind = "touchUp"
+	EventTouchMove  EventKind = "touchMove"
+	EventTouchClick EventKind = "touchClick"
+	EventSwipe      EventKind = "swipe"
+	EventKeyPress   EventKind = "keyPress"
+	EventSendText   EventKind = "sendText"
+	EventExecCmd    EventKind = "execCmd"
+	EventOpenApp    EventKind = "openApp"
+	EventStopApp    EventKind = "stopApp"
+	EventClickNode  EventKind = "clickNode"
+)
+
+// Event 是模拟设备记录的一次输入操作
+type Event struct {
+	Kind     EventKind
+	Screen   string // 操作发生时所在的画面
+	FingerID int
+	X, Y     int
+	EndX     int // 仅滑动
+	EndY     int // 仅滑动
+	Duration int // 滑动时长，毫秒
+	Code     int
+	Text     string // sendText 文本、execCmd 命令或应用包名
+	Time     time.Time
+}
+
+// transition 描述一次画面切换
+type transition struct {
+	from string
+	area image.Rectangle
+	key  int
+	to   string
+}
+
+// Device 是内存中的模拟设备
+//
+// 第一个加入的画面为初始画面。所有方法可以并发调用。
+type Device struct {
+	// Version 为 getVersion 的返回值
+	Version int
+
+	mu          sync.Mutex
+	screens     map[string]*Hierarchy
+	current     string
+	transitions []transition
+	commands    map[string]string
+	events      []Event
+	screenshot  []byte
+
+	connected   bool
+	unreachable bool
+	// handles 为 openDevice 返回且尚未关闭的设备句柄，断开后全部失效
+	handles map[uintptr]bool
+	host    string
+	port    int
+
+	nextHandle uintptr
+	selectors  map[uintptr][]predicate
+	nodeLists  map[uintptr][]*Element
+	nodes      map[uintptr]*Element
+	nodeIDs    map[*Element]uintptr
+	down       map[int]image.Point
+
+	stream *stream
+}
+
+var _ rpc.Backend = (*Device)(nil)
+
+// New 创建空的模拟设备
+func New() *Device {
+	return &Device{
+		Version:    1,
+		screens:    make(map[string]*Hierarchy),
+		commands:   make(map[string]string),
+		selectors:  make(map[uintptr][]predicate),
+		nodeLists:  make(map[uintptr][]*Element),
+		nodes:      make(map[uintptr]*Element),
+		nodeIDs:    make(map[*Element]uintptr),
+		down:       make(map[int]image.Point),
+		handles:    make(map[uintptr]bool),
+		nextHandle: 1,
+	}
+}
+
+// AddScreen 添加一个画面，xml 为层级XML内容
+func (d *Device) AddScreen(name string, xml []byte) error {
+	h, err := ParseHierarchy(bytes.NewReader(xml))
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.screens[name] = h
+	if d.current == "" {
+		d.current = name
+	}
+	return nil
+}
+
+// AddScreenFile 从文件添加一个画面
+func (d *Device) AddScreenFile(name, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return d.AddScreen(name, data)
+}
+
+// SetScreen 切换到指定画面
+func (d *Device) SetScreen(name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.screens[name]; !ok {
+		return fmt.Errorf("%w: screen %s (画面不存在)", rpc.ErrNotFound, name)
+	}
+	d.current = name
+	return nil
+}
+
+// Screen 返回当前画面名称
+func (d *Device) Screen() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.current
+}
+
+// OnTap 在画面 from 中点击 area 区域后切换到画面 to，from 为空表示任意画面
+//
+// 点击包括 touchDown/touchUp、touchClick 以及 clickNode（按节点中心点判断）。
+func (d *Device) OnTap(from string, area image.Rectangle, to string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.transitions = append(d.transitions, transition{from: from, area: area, key: -1, to: to})
+}
+
+// OnKey 在画面 from 中按下 code 后切换到画面 to，from 为空表示任意画面
+func (d *Device) OnKey(from string, code int, to string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.transitions = append(d.transitions, transition{from: from, key: code, to: to})
+}
+
+// SetCommandOutput 设置 execCmd 执行 cmd 时的输出
+func (d *Device) SetCommandOutput(cmd, output string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.commands[cmd] = output
+}
+
+// SetScreenshot 设置截图返回的数据（PNG或JPG），未设置时截图为与画面同尺寸的白色图片
+func (d *Device) SetScreenshot(data []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.screenshot = data
+}
+
+// Events 返回已记录的操作副本
+func (d *Device) Events() []Event {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Event(nil), d.events...)
+}
+
+// ResetEvents 清空已记录的操作
+func (d *Device) ResetEvents() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.events = nil
+}
+
+// Disconnect 模拟连接断开，之后 checkLive 返回 false，已发出的设备句柄全部失效
+func (d *Device) Disconnect() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.disconnect()
+}
+
+func (d *Device) disconnect() {
+	d.connected = false
+	clear(d.handles)
+}
+
+// SetReachable 设置设备是否可达，不可达时断开连接且 openDevice 失败，用于模拟网络中断
+func (d *Device) SetReachable(reachable bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.unreachable = !reachable
+	if !reachable {
+		d.disconnect()
+	}
+}
+
+func (d *Device) record(e Event) {
+	e.Screen = d.current
+	e.Time = time.Now()
+	d.events = append(d.events, e)
+}
+
+func (d *Device) hierarchy() *Hierarchy {
+	return d.screens[d.current]
+}
+
+// tap 处理点击引起的画面切换
+func (d *Device) tap(p image.Point) {
+	for _, t := range d.transitions {
+		if t.key < 0 && (t.from == "" || t.from == d.current) && p.In(t.area) {
+			d.current = t.to
+			return
+		}
+	}
+}
+
+// checkHandle 检查 handle 是否为仍然有效的设备句柄，重连前的旧句柄返回错误
+func (d *Device) checkHandle(handle uintptr) error {
+	if !d.connected || handle == 0 {
+		return rpc.ErrNotConnected
+	}
+	if !d.handles[handle] {
+		return fmt.Errorf("%w: device %d", rpc.ErrInvalidHandle, handle)
+	}
+	return nil
+}
+
+func (d *Device) allocHandle() uintptr {
+	h := d.nextHandle
+	d.nextHandle++
+	return h
+}
+
+func (d *Device) nodeHandle(e *Element) uintptr {
+	if h, ok := d.nodeIDs[e]; ok {
+		return h
+	}
+	h := d.allocHandle()
+	d.nodes[h] = e
+	d.nodeIDs[e] = h
+	return h
+}
+
+func (d *Device) node(handle uintptr) (*Element, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.nodes[handle]
+	if !ok {
+		return nil, fmt.Errorf("%w: node %d", rpc.ErrInvalidHandle, handle)
+	}
+	return e, nil
+}
+
+func (d *Device) OpenDevice(host string, port int, timeout int) (uintptr, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.unreachable {
+		return 0, fmt.Errorf("%w: %s:%d unreachable (设备不可达)", rpc.ErrNotConnected, host, port)
+	}
+	d.connected = true
+	d.host, d.port = host, port
+	h := d.allocHandle()
+	d.handles[h] = true
+	return h, nil
+}
+
+func (d *Device) CloseDevice(handle uintptr) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.handles[handle] {
+		return fmt.Errorf("%w: device %d", rpc.ErrInvalidHandle, handle)
+	}
+	delete(d.handles, handle)
+	d.connected = len(d.handles) > 0
+	d.stream = nil
+	return nil
+}
+
+func (d *Device) CheckLive(handle uintptr) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.connected && d.handles[handle], nil
+}
+
+func (d *Device) GetVersion() (int, error) {
+	return d.Version, nil
+}
+
+func (d *Device) UseNewNodeMode(handle uintptr, mode int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.checkHandle(handle)
+}
+
+func (d *Device) TakeCaptrueCompress(handle uintptr, imgType int, quality int) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkHandle(handle); err != nil {
+		return nil, err
+	}
+	if d.screenshot != nil {
+		return append([]byte(nil), d.screenshot...), nil
+	}
+	img, err := d.screenImage()
+	if err != nil {
+		return nil, err
+	}
+	return encodeImage(img, imgType, quality)
+}
+
+func (d *Device) TakeCaptrueCompressEx(handle uintptr, left, top, right, bottom int, imgType int, quality int) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkHandle(handle); err != nil {
+		return nil, err
+	}
+	img, err := d.screenImage()
+	if err != nil {
+		return nil, err
+	}
+	return encodeImage(crop(img, image.Rect(left, top, right, bottom)), imgType, quality)
+}
+
+func (d *Device) TakeCaptrue(handle uintptr) ([]byte, int, int, int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkHandle(handle); err != nil {
+		return nil, 0, 0, 0, err
+	}
+	img, err := d.screenImage()
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+	return img.Pix, img.Rect.Dx(), img.Rect.Dy(), img.Stride, nil
+}
+
+func (d *Device) TakeCaptrueEx(handle uintptr, left, top, right, bottom int) ([]byte, int, int, int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkHandle(handle); err != nil {
+		return nil, 0, 0, 0, err
+	}
+	img, err := d.screenImage()
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+	img = crop(img, image.Rect(left, top, right, bottom))
+	return img.Pix, img.Rect.Dx(), img.Rect.Dy(), img.Stride, nil
+}
+
+// screenImage 返回当前画面的截图：设置了截图数据时解码，否则为与画面同尺寸的白色图片
+func (d *Device) screenImage() (*image.RGBA, error) {
+	if d.screenshot != nil {
+		src, _, err := image.Decode(bytes.NewReader(d.screenshot))
+		if err != nil {
+			return nil, fmt.Errorf("decode screenshot failed (解码截图失败): %w", err)
+		}
+		img := image.NewRGBA(image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy()))
+		draw.Draw(img, img.Rect, src, src.Bounds().Min, draw.Src)
+		return img, nil
+	}
+
+	bounds := image.Rect(0, 0, 1, 1)
+	if h := d.hierarchy(); h != nil && !h.Root.Bounds.Empty() {
+		bounds = image.Rect(0, 0, h.Root.Bounds.Max.X, h.Root.Bounds.Max.Y)
+	}
+	img := image.NewRGBA(bounds)
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	return img, nil
+}
+
+// crop 复制 img 在 region 内的部分，结果从原点开始；region 为空时返回整张图片
+func crop(img *image.RGBA, region image.Rectangle) *image.RGBA {
+	region = region.Intersect(img.Rect)
+	if region.Empty() {
+		return img
+	}
+	out := image.NewRGBA(image.Rect(0, 0, region.Dx(), region.Dy()))
+	draw.Draw(out, out.Rect, img, region.Min, draw.Src)
+	return out
+}
+
+// encodeImage 按原生库的 imgType 编码：0 为PNG，1 为JPG
+func encodeImage(img image.Image, imgType int, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if imgType == 1 {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MissingSymbols 返回模拟设备未实现的原生函数，供 Client.Capabilities 使用
+func (d *Device) MissingSymbols() []string {
+	return []string{"screentshotEx"}
+}
+
+func (d *Device) ScreenshotEx(handle uintptr, left, top, right, bottom int, imgType int, quality int, path string) error {
+	return fmt.Errorf("%w: screentshotEx (模拟设备不支持)", rpc.ErrSymbolMissing)
+}
+
+func (d *Device) GetDisplayRotate(handle uintptr) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkHandle(handle); err != nil {
+		return 0, err
+	}
+	if h := d.hierarchy(); h != nil {
+		return h.Rotation, nil
+	}
+	return 0, nil
+}
+
+func (d *Device) KeyPress(handle uintptr, code int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkHandle(handle); err != nil {
+		return err
+	}
+	d.record(Event{Kind: EventKeyPress, Code: code})
+	for _, t := range d.transitions {
+		if t.key == code && (t.from == "" || t.from == d.current) {
+			d.current = t.to
+			break
+		}
+	}
+	return nil
+}
+
+func (d *Device) TouchDown(handle uintptr, fingerID int, x, y int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkHandle(handle); err != nil {
+		return err
+	}
+	d.record(Event{Kind: EventTouchDown, FingerID: fingerID, X: x, Y: y})
+	d.down[fingerID] = image.Point{X: x, Y: y}
+	return nil
+}
+
+func (d *Device) TouchUp(handle uintptr, fingerID int, x, y int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkHandle(handle); err != nil {
+		return err
+	}
+	d.record(Event{Kind: EventTouchUp, FingerID: fingerID, X: x, Y: y})
+	if _, ok := d.down[fingerID]; ok {
+		delete(d.down, fingerID)
+		d.tap(image.Point{X: x, Y: y})
+	}
+	return nil
+}
+
+func (d *Device) TouchMove(handle uintptr, fingerID int, x, y int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkHandle(handle); err != nil {
+		return err
+	}
+	d.record(Event{Kind: EventTouchMove, FingerID: fingerID, X: x, Y: y})
+	return nil
+}
+
+func (d *Device) TouchClick(handle uintptr, fingerID int, x, y int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkHandle(handle); err != nil {
+		return err
+	}
+	d.record(Event{Kind: EventTouchClick, FingerID: fingerID, X: x, Y: y})
+	d.tap(image.Point{X: x, Y: y})
+	return nil
+}
+
+func (d *Device) Swipe(handle uintptr, fingerID int, x0, y0, x1, y1 int, duration int, async bool) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkHandle(handle); err != nil {
+		return err
+	}
+	d.record(Event{Kind: EventSwipe, FingerID: fingerID, X: x0, Y: y0, EndX: x1, EndY: y1, Duration: duration})
+	return nil
+}
+
+func (d *Device) SendText(handle uintptr, text string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkHandle(handle); err != nil {
+		return err
+	}
+	d.record(Event{Kind: EventSendText, Text: text})
+	return nil
+}
+
+func (d *Device) ExecCmd(handle uintptr, wait bool, cmd string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkHandle(handle); err != nil {
+		return "", err
+	}
+	d.record(Event{Kind: EventExecCmd, Text: cmd})
+	if out, ok := d.commands[cmd]; ok {
+		return out, nil
+	}
+	return d.defaultOutput(cmd), nil
+}
+
+// defaultOutput 为未通过 SetCommandOutput 设置的常用命令生成输出：
+// wm size 为画面根节点的尺寸（横屏时换算为自然方向），wm density 按 360dp 宽度推算
+func (d *Device) defaultOutput(cmd string) string {
+	h := d.hierarchy()
+	if h == nil || h.Root.Bounds.Empty() {
+		return ""
+	}
+	w, ht := h.Root.Bounds.Max.X, h.Root.Bounds.Max.Y
+	if h.Rotation%2 == 1 {
+		w, ht = ht, w
+	}
+	switch strings.Join(strings.Fields(cmd), " ") {
+	case "wm size":
+		return fmt.Sprintf("Physical size: %dx%d\n", w, ht)
+	case "wm density":
+		return fmt.Sprintf("Physical density: %d\n", min(w, ht)*160/360)
+	}
+	return ""
+}
+
+func (d *Device) OpenApp(handle uintptr, packageName string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkHandle(handle); err != nil {
+		return err
+	}
+	d.record(Event{Kind: EventOpenApp, Text: packageName})
+	return nil
+}
+
+func (d *Device) StopApp(handle uintptr, packageName string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkHandle(handle); err != nil {
+		return err
+	}
+	d.record(Event{Kind: EventStopApp, Text: packageName})
+	return nil
+}
+
+func (d *Device) DumpNodeXml(handle uintptr, dumpAll bool) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkHandle(handle); err != nil {
+		return "", err
+	}
+	h := d.hierarchy()
+	if h == nil {
+		return "", fmt.Errorf("%w: no screen (模拟设备没有画面)", rpc.ErrNotFound)
+	}
+	return h.XML(), nil
+}
+
+func (d *Device) DumpNodeXmlEx(handle uintptr, workMode bool, timeout int) (string, error) {
+	return d.DumpNodeXml(handle, true)
+}
+
+func (d *Device) NewSelector(handle uintptr) (uintptr, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkHandle(handle); err != nil {
+		return 0, err
+	}
+	h := d.allocHandle()
+	d.selectors[h] = nil
+	return h, nil
+}
+
+func (d *Device) ClearSelector(selector uintptr) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.selectors[selector]; !ok {
+		return fmt.Errorf("%w: selector %d", rpc.ErrInvalidHandle, selector)
+	}
+	d.selectors[selector] = nil
+	return nil
+}
+
+func (d *Device) FreeSelector(selector uintptr) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.selectors, selector)
+	return nil
+}
+
+func (d *Device) addPredicate(selector uintptr, p predicate) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	preds, ok := d.selectors[selector]
+	if !ok {
+		return fmt.Errorf("%w: selector %d", rpc.ErrInvalidHandle, selector)
+	}
+	d.selectors[selector] = append(preds, p)
+	return nil
+}
+
+func (d *Device) AddStringQuery(selector uintptr, query string, value string) error {
+	p, err := stringPredicate(query, value)
+	if err != nil {
+		return err
+	}
+	return d.addPredicate(selector, p)
+}
+
+func (d *Device) AddBoolQuery(selector uintptr, query string, value bool) error {
+	p, err := boolPredicate(query, value)
+	if err != nil {
+		return err
+	}
+	return d.addPredicate(selector, p)
+}
+
+func (d *Device) AddIntQuery(selector uintptr, query string, value int) error {
+	if query != rpc.QueryIndex {
+		return fmt.Errorf("unknown selector query (未知的查询条件): %s", query)
+	}
+	return d.addPredicate(selector, func(e *Element) bool { return e.Index == value })
+}
+
+func (d *Device) AddBoundsQuery(selector uintptr, query string, left, top, right, bottom int) error {
+	r := image.Rect(left, top, right, bottom)
+	switch query {
+	case rpc.QueryBoundsEqual:
+		return d.addPredicate(selector, func(e *Element) bool { return e.Bounds == r })
+	case rpc.QueryBoundsInside:
+		return d.addPredicate(selector, func(e *Element) bool { return e.Bounds.In(r) })
+	}
+	return fmt.Errorf("unknown selector query (未知的查询条件): %s", query)
+}
+
+// FindNodes 在当前画面中按文档顺序查找，没有匹配时返回0
+func (d *Device) FindNodes(selector uintptr, maxNode int, timeout int) (uintptr, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	preds, ok := d.selectors[selector]
+	if !ok {
+		return 0, fmt.Errorf("%w: selector %d", rpc.ErrInvalidHandle, selector)
+	}
+	h := d.hierarchy()
+	if h == nil {
+		return 0, nil
+	}
+
+	var found []*Element
+	h.Walk(func(e *Element) bool {
+		for _, p := range preds {
+			if !p(e) {
+				return true
+			}
+		}
+		found = append(found, e)
+		return maxNode <= 0 || len(found) < maxNode
+	})
+	if len(found) == 0 {
+		return 0, nil
+	}
+
+	list := d.allocHandle()
+	d.nodeLists[list] = found
+	return list, nil
+}
+
+func (d *Device) GetNodesSize(nodes uintptr) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	list, ok := d.nodeLists[nodes]
+	if !ok {
+		return 0, fmt.Errorf("%w: nodes %d", rpc.ErrInvalidHandle, nodes)
+	}
+	return len(list), nil
+}
+
+func (d *Device) GetNodeByIndex(nodes uintptr, index int) (uintptr, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	list, ok := d.nodeLists[nodes]
+	if !ok {
+		return 0, fmt.Errorf("%w: nodes %d", rpc.ErrInvalidHandle, nodes)
+	}
+	if index < 0 || index >= len(list) {
+		return 0, nil
+	}
+	return d.nodeHandle(list[index]), nil
+}
+
+func (d *Device) FreeNodes(nodes uintptr) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.nodeLists, nodes)
+	return nil
+}
+
+func (d *Device) ClickNode(node uintptr) error {
+	e, err := d.node(node)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c := e.Center()
+	d.record(Event{Kind: EventClickNode, X: c.X, Y: c.Y, Text: e.Text})
+	d.tap(c)
+	return nil
+}
+
+func (d *Device) LongClickNode(node uintptr) error {
+	e, err := d.node(node)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c := e.Center()
+	d.record(Event{Kind: EventClickNode, X: c.X, Y: c.Y, Text: e.Text, Duration: 1000})
+	return nil
+}
+
+func (d *Device) GetNodeBound(node uintptr) (left, top, right, bottom int, err error) {
+	e, err := d.node(node)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	return e.Bounds.Min.X, e.Bounds.Min.Y, e.Bounds.Max.X, e.Bounds.Max.Y, nil
+}
+
+func (d *Device) GetNodeBoundCenter(node uintptr) (x, y int, err error) {
+	e, err := d.node(node)
+	if err != nil {
+		return 0, 0, err
+	}
+	c := e.Center()
+	return c.X, c.Y, nil
+}
+
+func (d *Device) nodeString(node uintptr, field func(*Element) string) (string, error) {
+	e, err := d.node(node)
+	if err != nil {
+		return "", err
+	}
+	return field(e), nil
+}
+
+func (d *Device) GetNodeText(node uintptr) (string, error) {
+	return d.nodeString(node, func(e *Element) string { return e.Text })
+}
+
+func (d *Device) GetNodeDesc(node uintptr) (string, error) {
+	return d.nodeString(node, func(e *Element) string { return e.ContentDesc })
+}
+
+func (d *Device) GetNodePackage(node uintptr) (string, error) {
+	return d.nodeString(node, func(e *Element) string { return e.Package })
+}
+
+func (d *Device) GetNodeClass(node uintptr) (string, error) {
+	return d.nodeString(node, func(e *Element) string { return e.Class })
+}
+
+func (d *Device) GetNodeId(node uintptr) (string, error) {
+	return d.nodeString(node, func(e *Element) string { return e.ResourceID })
+}
+
+func (d *Device) GetNodeJson(node uintptr) (string, error) {
+	return d.nodeString(node, (*Element).JSON)
+}
+
+func (d *Device) GetNodeParent(node uintptr) (uintptr, error) {
+	e, err := d.node(node)
+	if err != nil {
+		return 0, err
+	}
+	// 虚拟根节点不对外暴露
+	if e.Parent == nil || e.Parent.Parent == nil {
+		return 0, nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.nodeHandle(e.Parent), nil
+}
+
+func (d *Device) GetNodeChildCount(node uintptr) (int, error) {
+	e, err := d.node(node)
+	if err != nil {
+		return 0, err
+	}
+	return len(e.Children), nil
+}
+
+func (d *Device) GetNodeChild(node uintptr, index int) (uintptr, error) {
+	e, err := d.node(node)
+	if err != nil {
+		return 0, err
+	}
+	if index < 0 || index >= len(e.Children) {
+		return 0, nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.nodeHandle(e.Children[index]), nil
+}
+
+// Typed 返回 sendText 输入的全部文本，按顺序拼接
+func (d *Device) Typed() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var sb strings.Builder
+	for _, e := range d.events {
+		if e.Kind == EventSendText {
+			sb.WriteString(e.Text)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,237 @@
+package sim_test
+
+import (
+	"encoding/json"
+	"errors"
+	"image"
+	"strings"
+	"testing"
+	"time"
+
+	"mytrpc/node"
+	"mytrpc/rpc"
+	"mytrpc/sim"
+)
+
+func newSim(t *testing.T) (*sim.Device, uintptr) {
+	t.Helper()
+	fake := sim.New()
+	if err := fake.AddScreenFile("home", "../nodes_ex.xml"); err != nil {
+		t.Fatal(err)
+	}
+	handle, err := fake.OpenDevice("sim", 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake, handle
+}
+
+// query 是选择器中的一个条件
+type query func(fake *sim.Device, selector uintptr) error
+
+func text(q, v string) query {
+	return func(fake *sim.Device, s uintptr) error { return fake.AddStringQuery(s, q, v) }
+}
+
+func flag(q string, v bool) query {
+	return func(fake *sim.Device, s uintptr) error { return fake.AddBoolQuery(s, q, v) }
+}
+
+func index(v int) query {
+	return func(fake *sim.Device, s uintptr) error { return fake.AddIntQuery(s, rpc.QueryIndex, v) }
+}
+
+func bounds(q string, r image.Rectangle) query {
+	return func(fake *sim.Device, s uintptr) error {
+		return fake.AddBoundsQuery(s, q, r.Min.X, r.Min.Y, r.Max.X, r.Max.Y)
+	}
+}
+
+// findTexts 执行选择器，返回匹配节点的文本
+func findTexts(t *testing.T, fake *sim.Device, handle uintptr, maxNode int, queries ...query) ([]string, error) {
+	t.Helper()
+	sel, err := fake.NewSelector(handle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.FreeSelector(sel)
+	for _, q := range queries {
+		if err := q(fake, sel); err != nil {
+			return nil, err
+		}
+	}
+	nodes, err := fake.FindNodes(sel, maxNode, 0)
+	if err != nil || nodes == 0 {
+		return nil, err
+	}
+	defer fake.FreeNodes(nodes)
+	n, err := fake.GetNodesSize(nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for i := 0; i < n; i++ {
+		h, err := fake.GetNodeByIndex(nodes, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		js, err := fake.GetNodeJson(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var e struct{ Text string }
+		if err := json.Unmarshal([]byte(js), &e); err != nil {
+			t.Fatal(err)
+		}
+		texts = append(texts, e.Text)
+	}
+	return texts, nil
+}
+
+func TestSelectors(t *testing.T) {
+	fake, handle := newSim(t)
+	tests := []struct {
+		name    string
+		queries []query
+		max     int
+		want    []string
+		wantErr bool
+	}{
+		{name: "text equal", queries: []query{text(rpc.QueryTextEqual, "Settings")}, want: []string{"Settings"}},
+		{name: "text prefix", queries: []query{text(rpc.QueryTextStartWith, "Pause")}, want: []string{"Pause LIVE"}},
+		{name: "text suffix", queries: []query{text(rpc.QueryTextEndWith, "microphone")}, want: []string{"Mute microphone"}},
+		{name: "text regexp", queries: []query{text(rpc.QueryTextMatchWith, `^(Flip|Mirror) `)}, want: []string{"Flip camera", "Mirror your video"}},
+		{name: "document order", queries: []query{text(rpc.QueryTextContainWith, "LIVE")}, want: []string{"Pause LIVE", "LIVE Gifts", "LIVE Events"}},
+		{name: "max nodes", queries: []query{text(rpc.QueryTextContainWith, "LIVE")}, max: 1, want: []string{"Pause LIVE"}},
+		{name: "resource id", queries: []query{text(rpc.QueryIdEqual, "com.android.systemui:id/clock")}, want: []string{"17:58"}},
+		{
+			name:    "all conditions",
+			queries: []query{text(rpc.QueryClzEqual, "android.widget.TextView"), flag(rpc.QueryClickable, false), text(rpc.QueryTextEqual, "Settings")},
+			want:    []string{"Settings"},
+		},
+		{name: "failing condition", queries: []query{text(rpc.QueryTextEqual, "Settings"), flag(rpc.QueryClickable, true)}},
+		{name: "bounds equal", queries: []query{bounds(rpc.QueryBoundsEqual, image.Rect(112, 775, 229, 813))}, want: []string{"Settings"}},
+		{name: "bounds inside", queries: []query{bounds(rpc.QueryBoundsInside, image.Rect(100, 770, 300, 820))}, want: []string{"Settings"}},
+		{name: "index", queries: []query{index(1), text(rpc.QueryTextContainWith, "LIVE")}, max: 2, want: []string{"Pause LIVE", "LIVE Gifts"}},
+		{name: "no match", queries: []query{text(rpc.QueryTextEqual, "Nothing")}},
+		{name: "unknown query", queries: []query{text("TitleEqual", "x")}, wantErr: true},
+		{name: "bad regexp", queries: []query{text(rpc.QueryTextMatchWith, "(")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findTexts(t, fake, handle, tt.max, tt.queries...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("found %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransitions(t *testing.T) {
+	fake, handle := newSim(t)
+	for _, name := range []string{"menu", "comment"} {
+		if err := fake.AddScreenFile(name, "../nodes_ex.xml"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	more := image.Rect(600, 1150, 720, 1250)
+	fake.OnTap("home", more, "menu")
+	fake.OnTap("menu", image.Rect(0, 900, 400, 1100), "comment")
+	fake.OnKey("", 4, "home")
+
+	steps := []struct {
+		name string
+		do   func() error
+		want string
+	}{
+		{name: "tap outside", do: func() error { return fake.TouchClick(handle, 1, 10, 10) }, want: "home"},
+		{name: "tap on another screen's area", do: func() error { return fake.TouchClick(handle, 1, 200, 1000) }, want: "home"},
+		// touchDown 不切换，touchUp 时按抬起的位置切换
+		{name: "press", do: func() error { return fake.TouchDown(handle, 1, 660, 1200) }, want: "home"},
+		{name: "release", do: func() error { return fake.TouchUp(handle, 1, 660, 1200) }, want: "menu"},
+		{name: "tap", do: func() error { return fake.TouchClick(handle, 1, 200, 1000) }, want: "comment"},
+		{name: "other key", do: func() error { return fake.KeyPress(handle, 66) }, want: "comment"},
+		{name: "back from any screen", do: func() error { return fake.KeyPress(handle, 4) }, want: "home"},
+	}
+	for _, s := range steps {
+		if err := s.do(); err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		if got := fake.Screen(); got != s.want {
+			t.Fatalf("%s: screen = %s, want %s", s.name, got, s.want)
+		}
+	}
+
+	// 事件记录发生时所在的画面
+	var screens []string
+	for _, e := range fake.Events() {
+		screens = append(screens, string(e.Kind)+"@"+e.Screen)
+	}
+	want := "touchClick@home touchClick@home touchDown@home touchUp@home touchClick@menu keyPress@comment keyPress@comment"
+	if got := strings.Join(screens, " "); got != want {
+		t.Fatalf("events = %s\nwant %s", got, want)
+	}
+
+	if err := fake.SetScreen("missing"); !errors.Is(err, rpc.ErrNotFound) {
+		t.Fatalf("SetScreen(missing) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestNodeSelectorAgainstSim(t *testing.T) {
+	fake := sim.New()
+	for _, name := range []string{"home", "settings"} {
+		if err := fake.AddScreenFile(name, "../nodes_ex.xml"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	settings := image.Rect(112, 775, 229, 813)
+	fake.OnTap("home", settings, "settings")
+	client := rpc.NewClientWithBackend(fake)
+	if err := client.Connect("sim", 0); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	sel := node.NewSelector(client)
+	sel.AddTextQuery("Sett")
+	n, err := sel.FindOne(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Release()
+	if n.GetText() != "Settings" {
+		t.Fatalf("text = %q", n.GetText())
+	}
+	b, err := n.GetBounds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image.Rect(b.Left, b.Top, b.Right, b.Bottom) != settings {
+		t.Fatalf("bounds = %+v, want %v", b, settings)
+	}
+	js, err := n.GetJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var attrs struct{ Class, ID string }
+	if err := json.Unmarshal([]byte(js), &attrs); err != nil || attrs.Class != "android.widget.TextView" || attrs.ID != "com.zhiliaoapp.musically:id/r08" {
+		t.Fatalf("json = %s, err %v", js, err)
+	}
+
+	if err := n.Click(); err != nil {
+		t.Fatal(err)
+	}
+	if fake.Screen() != "settings" {
+		t.Fatalf("screen after click = %s, want settings", fake.Screen())
+	}
+
+	// 找不到时在超时后返回 ErrNotFound
+	sel = node.NewSelector(client)
+	sel.AddTextQuery("Nothing here")
+	if _, err := sel.FindOne(30 * time.Millisecond); !errors.Is(err, rpc.ErrNotFound) {
+		t.Fatalf("err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,285 @@
+package sim
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Element 是层级XML中的一个节点
+type Element struct {
+	Index         int
+	Text          string
+	ResourceID    string
+	Class         string
+	Package       string
+	ContentDesc   string
+	Checkable     bool
+	Checked       bool
+	Clickable     bool
+	Enabled       bool
+	Focusable     bool
+	Focused       bool
+	Scrollable    bool
+	LongClickable bool
+	Password      bool
+	Selected      bool
+	Visible       bool
+	Bounds        image.Rectangle
+
+	Parent   *Element
+	Children []*Element
+}
+
+// Hierarchy 是一份解析后的UI层级
+type Hierarchy struct {
+	Rotation int
+	Root     *Element
+
+	raw string
+}
+
+type xmlNode struct {
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Children []xmlNode  `xml:"node"`
+}
+
+type xmlHierarchy struct {
+	Rotation int       `xml:"rotation,attr"`
+	Nodes    []xmlNode `xml:"node"`
+}
+
+// ParseHierarchy 解析 uiautomator 格式的层级XML，如 nodes_ex.xml
+func ParseHierarchy(r io.Reader) (*Hierarchy, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc xmlHierarchy
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse hierarchy xml failed (解析层级XML失败): %w", err)
+	}
+
+	// 统一挂到一个虚拟根节点下，便于多窗口层级的遍历
+	root := &Element{Visible: true, Enabled: true}
+	for i := range doc.Nodes {
+		child, err := convertNode(&doc.Nodes[i], root)
+		if err != nil {
+			return nil, err
+		}
+		root.Children = append(root.Children, child)
+	}
+	for _, child := range root.Children {
+		root.Bounds = root.Bounds.Union(child.Bounds)
+	}
+
+	return &Hierarchy{Rotation: doc.Rotation, Root: root, raw: string(data)}, nil
+}
+
+func convertNode(n *xmlNode, parent *Element) (*Element, error) {
+	e := &Element{Parent: parent}
+	for _, attr := range n.Attrs {
+		v := attr.Value
+		switch attr.Name.Local {
+		case "index":
+			e.Index, _ = strconv.Atoi(v)
+		case "text":
+			e.Text = v
+		case "resource-id":
+			e.ResourceID = v
+		case "class":
+			e.Class = v
+		case "package":
+			e.Package = v
+		case "content-desc":
+			e.ContentDesc = v
+		case "checkable":
+			e.Checkable = v == "true"
+		case "checked":
+			e.Checked = v == "true"
+		case "clickable":
+			e.Clickable = v == "true"
+		case "enabled":
+			e.Enabled = v == "true"
+		case "focusable":
+			e.Focusable = v == "true"
+		case "focused":
+			e.Focused = v == "true"
+		case "scrollable":
+			e.Scrollable = v == "true"
+		case "long-clickable":
+			e.LongClickable = v == "true"
+		case "password":
+			e.Password = v == "true"
+		case "selected":
+			e.Selected = v == "true"
+		case "visible-to-user":
+			e.Visible = v == "true"
+		case "bounds":
+			bounds, err := parseBounds(v)
+			if err != nil {
+				return nil, err
+			}
+			e.Bounds = bounds
+		}
+	}
+
+	for i := range n.Children {
+		child, err := convertNode(&n.Children[i], e)
+		if err != nil {
+			return nil, err
+		}
+		e.Children = append(e.Children, child)
+	}
+	return e, nil
+}
+
+// parseBounds 解析 "[l,t][r,b]" 格式的区域
+func parseBounds(s string) (image.Rectangle, error) {
+	var r image.Rectangle
+	if _, err := fmt.Sscanf(s, "[%d,%d][%d,%d]", &r.Min.X, &r.Min.Y, &r.Max.X, &r.Max.Y); err != nil {
+		return r, fmt.Errorf("invalid bounds (无效的bounds) %q: %w", s, err)
+	}
+	return r, nil
+}
+
+// XML 返回原始层级XML
+func (h *Hierarchy) XML() string {
+	return h.raw
+}
+
+// Walk 按文档顺序遍历所有节点，fn 返回 false 时停止
+func (h *Hierarchy) Walk(fn func(*Element) bool) {
+	var walk func(*Element) bool
+	walk = func(e *Element) bool {
+		for _, child := range e.Children {
+			if !fn(child) || !walk(child) {
+				return false
+			}
+		}
+		return true
+	}
+	walk(h.Root)
+}
+
+// Center 返回节点中心点
+func (e *Element) Center() image.Point {
+	return image.Point{
+		X: (e.Bounds.Min.X + e.Bounds.Max.X) / 2,
+		Y: (e.Bounds.Min.Y + e.Bounds.Max.Y) / 2,
+	}
+}
+
+// JSON 返回节点的JSON表示
+func (e *Element) JSON() string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"index":          e.Index,
+		"text":           e.Text,
+		"id":             e.ResourceID,
+		"class":          e.Class,
+		"package":        e.Package,
+		"desc":           e.ContentDesc,
+		"checkable":      e.Checkable,
+		"checked":        e.Checked,
+		"clickable":      e.Clickable,
+		"enabled":        e.Enabled,
+		"focusable":      e.Focusable,
+		"focused":        e.Focused,
+		"scrollable":     e.Scrollable,
+		"long-clickable": e.LongClickable,
+		"password":       e.Password,
+		"selected":       e.Selected,
+		"visible":        e.Visible,
+		"bounds": map[string]int{
+			"left":   e.Bounds.Min.X,
+			"top":    e.Bounds.Min.Y,
+			"right":  e.Bounds.Max.X,
+			"bottom": e.Bounds.Max.Y,
+		},
+	})
+	return string(data)
+}
+
+// predicate 是选择器中的一个查询条件
+type predicate func(*Element) bool
+
+// stringPredicate 根据查询名称构造字符串匹配条件，如 TextContainWith
+func stringPredicate(query, value string) (predicate, error) {
+	var field func(*Element) string
+	var op string
+	for _, prefix := range []string{"Text", "Id", "Clz", "Desc", "Package"} {
+		if strings.HasPrefix(query, prefix) {
+			op = strings.TrimPrefix(query, prefix)
+			switch prefix {
+			case "Text":
+				field = func(e *Element) string { return e.Text }
+			case "Id":
+				field = func(e *Element) string { return e.ResourceID }
+			case "Clz":
+				field = func(e *Element) string { return e.Class }
+			case "Desc":
+				field = func(e *Element) string { return e.ContentDesc }
+			case "Package":
+				field = func(e *Element) string { return e.Package }
+			}
+			break
+		}
+	}
+	if field == nil {
+		return nil, fmt.Errorf("unknown selector query (未知的查询条件): %s", query)
+	}
+
+	switch op {
+	case "Equal":
+		return func(e *Element) bool { return field(e) == value }, nil
+	case "StartWith":
+		return func(e *Element) bool { return strings.HasPrefix(field(e), value) }, nil
+	case "EndWith":
+		return func(e *Element) bool { return strings.HasSuffix(field(e), value) }, nil
+	case "ContainWith":
+		return func(e *Element) bool { return strings.Contains(field(e), value) }, nil
+	case "MatchWith":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp (无效的正则表达式) %q: %w", value, err)
+		}
+		return func(e *Element) bool { return re.MatchString(field(e)) }, nil
+	}
+	return nil, fmt.Errorf("unknown selector query (未知的查询条件): %s", query)
+}
+
+// boolPredicate 根据查询名称构造布尔属性条件，如 Clickable
+func boolPredicate(query string, value bool) (predicate, error) {
+	var field func(*Element) bool
+	switch query {
+	case "Checkable":
+		field = func(e *Element) bool { return e.Checkable }
+	case "Clickable":
+		field = func(e *Element) bool { return e.Clickable }
+	case "Enable":
+		field = func(e *Element) bool { return e.Enabled }
+	case "Focusable":
+		field = func(e *Element) bool { return e.Focusable }
+	case "Focused":
+		field = func(e *Element) bool { return e.Focused }
+	case "LongClickable":
+		field = func(e *Element) bool { return e.LongClickable }
+	case "Password":
+		field = func(e *Element) bool { return e.Password }
+	case "Scrollable":
+		field = func(e *Element) bool { return e.Scrollable }
+	case "Selected":
+		field = func(e *Element) bool { return e.Selected }
+	case "Visible":
+		field = func(e *Element) bool { return e.Visible }
+	default:
+		return nil, fmt.Errorf("unknown selector query (未知的查询条件): %s", query)
+	}
+	return func(e *Element) bool { return field(e) == value }, nil
+}
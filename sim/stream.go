@@ -0,0 +1,68 @@
+package sim
+
+import "mytrpc/rpc"
+
+// stream 为 startVideoStream 登记的推流设置和回调
+type stream struct {
+	width, height, bitrate int
+	video                  rpc.VideoCallback
+	audio                  rpc.AudioCallback
+}
+
+func (d *Device) StartVideoStream(handle uintptr, width, height, bitrate int, onVideo rpc.VideoCallback, onAudio rpc.AudioCallback) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkHandle(handle); err != nil {
+		return err
+	}
+	d.stream = &stream{width: width, height: height, bitrate: bitrate, video: onVideo, audio: onAudio}
+	return nil
+}
+
+func (d *Device) StopVideoStream(handle uintptr) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stream = nil
+	return nil
+}
+
+// Streaming 返回是否正在推流以及 startVideoStream 传入的宽、高和码率
+func (d *Device) Streaming() (width, height, bitrate int, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stream == nil {
+		return 0, 0, 0, false
+	}
+	return d.stream.width, d.stream.height, d.stream.bitrate, true
+}
+
+// PushVideo 模拟原生库推送一段视频数据，未推流或未接收视频时返回 false。
+// 与原生库一样在调用方的 goroutine 中同步执行回调。
+func (d *Device) PushVideo(rotation int, data []byte) bool {
+	d.mu.Lock()
+	var fn rpc.VideoCallback
+	if d.stream != nil {
+		fn = d.stream.video
+	}
+	d.mu.Unlock()
+	if fn == nil {
+		return false
+	}
+	fn(rotation, append([]byte(nil), data...))
+	return true
+}
+
+// PushAudio 模拟原生库推送一段音频数据，未推流或未接收音频时返回 false
+func (d *Device) PushAudio(data []byte) bool {
+	d.mu.Lock()
+	var fn rpc.AudioCallback
+	if d.stream != nil {
+		fn = d.stream.audio
+	}
+	d.mu.Unlock()
+	if fn == nil {
+		return false
+	}
+	fn(append([]byte(nil), data...))
+	return true
+}